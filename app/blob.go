@@ -0,0 +1,152 @@
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BlobStore keeps binary objects (images, exports, etc.) by key.
+// Keys are slash separated paths like "challenges/12/image.png"
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Remove(key string) error
+	List(prefix string) ([]string, error)
+	// Time of the last Put, zero time if blob does not exist
+	ModTime(key string) (time.Time, error)
+}
+
+func InitBlobStore() (BlobStore, error) {
+	driver := viper.GetString("storage.blobs.driver")
+	switch driver {
+	case "", "local":
+		p := viper.GetString("storage.blobs.path")
+		if p == "" {
+			p = path.Join(viper.GetString("storage.path"), "blobs")
+		}
+		return NewLocalBlobStore(p)
+	default:
+		return nil, errors.Errorf("unknown blob store driver %s", driver)
+	}
+}
+
+// LocalBlobStore stores blobs as files under root directory
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	err := os.MkdirAll(root, os.ModePerm)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot mkdir for blob store")
+	}
+	return &LocalBlobStore{root: root}, nil
+}
+
+func (s *LocalBlobStore) Put(key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "cannot mkdir for blob %s", key)
+	}
+
+	// Write to tmp file first so readers never see partial blobs
+	tmp := p + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot write blob %s", key)
+	}
+	err = os.Rename(tmp, p)
+	if err != nil {
+		return errors.Wrapf(err, "cannot rename blob %s", key)
+	}
+	return nil
+}
+
+func (s *LocalBlobStore) Get(key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read blob %s", key)
+	}
+	return data, nil
+}
+
+func (s *LocalBlobStore) Remove(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "cannot remove blob %s", key)
+	}
+	return nil
+}
+
+func (s *LocalBlobStore) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list blobs")
+	}
+	return keys, nil
+}
+
+func (s *LocalBlobStore) ModTime(key string) (time.Time, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "cannot stat blob %s", key)
+	}
+	return info.ModTime(), nil
+}
+
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.Errorf("incorrect blob key %s", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
@@ -6,9 +6,12 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/pressly/goose"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/spf13/viper"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"microservice/app/tracing"
 	"os"
 	"path"
 )
@@ -49,7 +52,20 @@ func InitDatabase() (*sql.DB, error) {
 		return nil, errors.Wrap(err, "cannot open database connection")
 	}
 
+	// Спан на каждый запрос (и для gorm, который работает поверх этого соединения).
+	// sql.Open нужен только ради драйвера по имени, его пул закрывается
+	if TracingEnabled() {
+		drv := localDB.Driver()
+		err = localDB.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot close database connection")
+		}
+		localDB = sql.OpenDB(tracing.Connector(connectionString(), drv))
+	}
+
 	db = localDB
+	// Статистика пула соединений (sql.DBStats) на момент запроса метрик
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, viper.GetString("db.name")))
 
 	// Test
 	err = db.Ping()
@@ -6,7 +6,11 @@ import (
 	"crypto/x509"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/dig"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -16,8 +20,10 @@ import (
 	"google.golang.org/grpc/status"
 	"io/ioutil"
 	"microservice/app/core"
+	"microservice/app/tracing"
 	"net"
 	"strconv"
+	"time"
 )
 
 var (
@@ -66,13 +72,20 @@ func InitGRPCServer() (*grpc.Server, *runtime.ServeMux, error) {
 
 	// Middleware
 	mv := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(requestTracing),
+		grpc.ChainStreamInterceptor(streamRequestTracing),
+		grpc.ChainUnaryInterceptor(requestMetrics),
+		grpc.ChainStreamInterceptor(streamRequestMetrics),
 		grpc.ChainUnaryInterceptor(errorLogging),
 		grpc.ChainUnaryInterceptor(anyLogging),
+		grpc.ChainStreamInterceptor(streamErrorLogging),
+		grpc.ChainStreamInterceptor(streamAnyLogging),
 	}
 
 	debug := viper.GetBool("app.debug")
 	if !debug {
 		mv = append(mv, grpc.ChainUnaryInterceptor(fromGWOnly))
+		mv = append(mv, grpc.ChainStreamInterceptor(streamFromGWOnly))
 	}
 
 	options = append(options, mv...)
@@ -137,21 +150,30 @@ func InitGRPCService[T any](s func(grpc.ServiceRegistrar, T), src T) {
 // Logging interceptor
 
 func fromGWOnly(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	if isFromGW(ctx) {
+		return handler(ctx, req)
+	} else {
+		return nil, errors.Errorf("DENIED access without AUTH! %s", info.FullMethod)
+	}
+}
+
+func streamFromGWOnly(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isFromGW(ss.Context()) {
+		return handler(srv, ss)
+	} else {
+		return errors.Errorf("DENIED access without AUTH! %s", info.FullMethod)
+	}
+}
 
-	isAuth := false
+func isFromGW(ctx context.Context) bool {
 	m, ok := metadata.FromIncomingContext(ctx)
 	if ok {
 		tokens := m.Get("Authorization")
 		if len(tokens) > 0 && viper.GetString("app.secret") == tokens[0] {
-			isAuth = true
+			return true
 		}
 	}
-
-	if isAuth {
-		return handler(ctx, req)
-	} else {
-		return nil, errors.Errorf("DENIED access without AUTH! %s", info.FullMethod)
-	}
+	return false
 }
 
 func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
@@ -161,6 +183,10 @@ func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerIn
 	// Log if error
 	if err != nil {
 		log.Error("%v", err)
+		// Ошибка с grpc статусом отдается клиенту как есть
+		if _, ok := status.FromError(err); ok {
+			return h, err
+		}
 		return h, status.Error(codes.Internal, err.Error())
 	}
 
@@ -172,6 +198,92 @@ func anyLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo
 	return handler(ctx, req)
 }
 
+// Tracing interceptor (серверный спан, контекст трейса из metadata клиента)
+
+func requestTracing(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, span := startRequestSpan(ctx, info.FullMethod)
+	h, err := handler(ctx, req)
+	endRequestSpan(span, err)
+	return h, err
+}
+
+func streamRequestTracing(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startRequestSpan(ss.Context(), info.FullMethod)
+	err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+	endRequestSpan(span, err)
+	return err
+}
+
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func startRequestSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracing.StartKind(tracing.ExtractGRPC(ctx), method, trace.SpanKindServer,
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.method", method))
+}
+
+func endRequestSpan(span trace.Span, err error) {
+	span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+	tracing.End(span, err)
+}
+
+// Metrics interceptor (первый в цепочке - видит итоговый код ответа)
+
+var (
+	grpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server",
+	}, []string{"method", "code"})
+	grpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Response latency of RPCs handled by the server",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+func requestMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	h, err := handler(ctx, req)
+	observeRequest(info.FullMethod, start, err)
+	return h, err
+}
+
+func streamRequestMetrics(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observeRequest(info.FullMethod, start, err)
+	return err
+}
+
+func observeRequest(method string, start time.Time, err error) {
+	grpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	grpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func streamErrorLogging(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if err != nil {
+		log.Error("%v", err)
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+func streamAnyLogging(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	log.Info("New stream %s", info.FullMethod)
+	return handler(srv, ss)
+}
+
 // Tools
 
 func ExtractRequestUserId(ctx context.Context) (int64, error) {
@@ -188,3 +300,15 @@ func ExtractRequestUserId(ctx context.Context) (int64, error) {
 	}
 	return -1, errors.New("user_id was not found into context")
 }
+
+// Ключ идемпотентности запроса, пустая строка если клиент его не передал
+func ExtractRequestIdempotencyKey(ctx context.Context) string {
+	m, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		keys := m.Get("idempotency-key")
+		if len(keys) > 0 {
+			return keys[0]
+		}
+	}
+	return ""
+}
@@ -5,6 +5,7 @@ import (
 	"go.uber.org/dig"
 	"reflect"
 	"runtime"
+	"time"
 )
 
 func NewJob(job interface{}, scheduleUTC string) {
@@ -27,12 +28,17 @@ func newJob(job interface{}, scheduleUTC string, immediately bool) {
 
 	err = scope.Invoke(func(j Job) {
 
+		label := jobLabel(j)
 		runnable := func() error {
 			log.Debug("Started %s", name)
+			start := time.Now()
 			err := j.Run()
+			runDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
 			if err != nil {
+				runs.WithLabelValues(label, "error").Inc()
 				return err
 			}
+			runs.WithLabelValues(label, "success").Inc()
 			log.Debug("Finished %s", name)
 			return nil
 		}
@@ -2,8 +2,11 @@ package job
 
 import (
 	"github.com/go-co-op/gocron"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/dig"
 	"microservice/app/core"
+	"reflect"
 	"time"
 )
 
@@ -18,6 +21,44 @@ type Job interface {
 	Run() error
 }
 
+var (
+	runs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_runs_total",
+		Help: "Total number of job runs by result (success | error)",
+	}, []string{"job", "result"})
+	runDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_run_duration_seconds",
+		Help:    "Duration of job runs",
+		Buckets: []float64{.1, .5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"job"})
+	processedItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_processed_items_total",
+		Help: "Total number of items processed by job",
+	}, []string{"job"})
+	failedItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_failed_items_total",
+		Help: "Total number of items job failed to process",
+	}, []string{"job"})
+)
+
+// Processed учитывает обработанные джобой элементы (label - имя типа джобы)
+func Processed(j Job, count int) {
+	processedItems.WithLabelValues(jobLabel(j)).Add(float64(count))
+}
+
+// Failed учитывает элементы, которые джоба не смогла обработать
+func Failed(j Job, count int) {
+	failedItems.WithLabelValues(jobLabel(j)).Add(float64(count))
+}
+
+func jobLabel(j Job) string {
+	t := reflect.TypeOf(j)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
 func Init(logger core.Logger, di_ *dig.Container) error {
 	log = logger
 	di = di_
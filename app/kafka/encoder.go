@@ -2,7 +2,10 @@ package kafka
 
 import (
 	"encoding/json"
+	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"reflect"
 )
 
 type Encoder interface {
@@ -10,6 +13,20 @@ type Encoder interface {
 	Decode(interface{}) ([]byte, error)
 }
 
+// Кодировщик, добавляющий заголовки к отправляемому сообщению (например, версию схемы)
+type HeadersEncoder interface {
+	Headers(interface{}) []sarama.RecordHeader
+}
+
+// Заголовки сообщений protobuf кодировщика с версией схемы
+const (
+	HeaderContentType   = "content-type"
+	HeaderSchema        = "schema"
+	HeaderSchemaVersion = "schema-version"
+)
+
+const protobufContentType = "application/x-protobuf"
+
 type jsonEncoder struct{}
 
 func (*jsonEncoder) Encode(in []byte, out interface{}) error {
@@ -65,6 +82,65 @@ func (*byteEncoder) Decode(t interface{}) ([]byte, error) {
 	}
 }
 
+// Бинарный protobuf, T - сгенерированное сообщение (*pb.TaskDoneEvent)
+type protoEncoder struct {
+	version string
+}
+
+func (*protoEncoder) Encode(in []byte, out interface{}) error {
+	msg, ok := out.(proto.Message)
+	if !ok {
+		// out - указатель на поле *pb.Message, сообщение создается при необходимости
+		v := reflect.ValueOf(out)
+		if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Pointer {
+			return errors.Errorf("incorrect type %T for protobuf encoder", out)
+		}
+		if v.Elem().IsNil() {
+			v.Elem().Set(reflect.New(v.Elem().Type().Elem()))
+		}
+		msg, ok = v.Elem().Interface().(proto.Message)
+		if !ok {
+			return errors.Errorf("incorrect type %T for protobuf encoder", out)
+		}
+	}
+
+	err := proto.Unmarshal(in, msg)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (*protoEncoder) Decode(t interface{}) ([]byte, error) {
+	msg, ok := t.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("incorrect type %T for protobuf decoder", t)
+	}
+	return proto.Marshal(msg)
+}
+
+// Заголовки добавляются только при заданной версии схемы
+func (e *protoEncoder) Headers(t interface{}) []sarama.RecordHeader {
+	msg, ok := t.(proto.Message)
+	if !ok || e.version == "" {
+		return nil
+	}
+	return []sarama.RecordHeader{
+		{Key: []byte(HeaderContentType), Value: []byte(protobufContentType)},
+		{Key: []byte(HeaderSchema), Value: []byte(msg.ProtoReflect().Descriptor().FullName())},
+		{Key: []byte(HeaderSchemaVersion), Value: []byte(e.version)},
+	}
+}
+
+// version - версия схемы в заголовке schema-version (необязательно)
+func ProtoEncoder(version ...string) Encoder {
+	e := &protoEncoder{}
+	if len(version) != 0 {
+		e.version = version[0]
+	}
+	return e
+}
+
 func ByteEncoder() Encoder {
 	return &byteEncoder{}
 }
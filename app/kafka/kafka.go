@@ -1,21 +1,55 @@
 package kafka
 
 import (
+	"context"
 	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/spf13/viper"
-	"microservice/app"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 	"microservice/app/core"
-	"path"
+	"microservice/app/tracing"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var k *KafkaService
 var logger core.Logger
 
+var (
+	producedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_produced_messages_total",
+		Help: "Total number of messages produced to kafka by result (success | error)",
+	}, []string{"topic", "result"})
+	consumedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumed_messages_total",
+		Help: "Total number of messages consumed from kafka",
+	}, []string{"topic"})
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Number of messages in partition not yet consumed",
+	}, []string{"topic", "partition"})
+)
+
+// Пауза перед повторным входом в группу после ошибки
+const consumeRetryDelay = 5 * time.Second
+
 type KafkaService struct {
-	producer      sarama.SyncProducer
-	consumer      sarama.Consumer
+	client   sarama.Client
+	producer sarama.SyncProducer
+	group    string
+
+	// Одна группа на все читаемые топики, сообщения передаются обработчику топика
+	mu            sync.Mutex
 	consumerGroup sarama.ConsumerGroup
+	handlers      map[string]claimHandler
+	cancelSession context.CancelFunc
 }
 
 func InitKafka(l core.Logger) error {
@@ -28,49 +62,82 @@ func InitKafka(l core.Logger) error {
 	}
 
 	config := sarama.NewConfig()
-	config.Producer.Partitioner = sarama.NewRandomPartitioner
+	// Сообщения с одним ключом попадают в одну партицию (без ключа - в случайную)
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Return.Successes = true
 	config.Producer.Return.Errors = true
-	config.Consumer.Offsets.AutoCommit.Enable = true
 
-	brokers := viper.GetStringSlice("kafka.brokers")
+	// Офсеты хранит брокер: обработанные сообщения отмечаются (CommitOffset) и коммитятся периодически
+	config.Consumer.Offsets.AutoCommit.Enable = true
+	// Новая группа по умолчанию читает только новые сообщения
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	if viper.GetString("kafka.initial_offset") == "oldest" {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	strategy, err := balanceStrategy(viper.GetString("kafka.rebalance_strategy"))
 	if err != nil {
 		return err
 	}
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{strategy}
+
+	if version := viper.GetString("kafka.version"); version != "" {
+		config.Version, err = sarama.ParseKafkaVersion(version)
+		if err != nil {
+			return errors.Wrap(err, "kafka.version")
+		}
+	}
 
 	group := viper.GetString("kafka.group")
-	consumerGroup, err := sarama.NewConsumerGroup(brokers, group, config)
+	if group == "" {
+		return errors.New("kafka.group is not set")
+	}
+
+	brokers := viper.GetStringSlice("kafka.brokers")
+
+	client, err := sarama.NewClient(brokers, config)
 	if err != nil {
 		return err
 	}
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
 		return err
 	}
 
 	k = &KafkaService{
-		producer:      producer,
-		consumer:      consumer,
-		consumerGroup: consumerGroup,
+		client:   client,
+		producer: producer,
+		group:    group,
+		handlers: make(map[string]claimHandler),
 	}
 
 	return nil
 }
 
+func balanceStrategy(name string) (sarama.BalanceStrategy, error) {
+	switch name {
+	case "", "sticky":
+		return sarama.BalanceStrategySticky, nil
+	case "range":
+		return sarama.BalanceStrategyRange, nil
+	case "roundrobin":
+		return sarama.BalanceStrategyRoundRobin, nil
+	}
+	return nil, errors.Errorf("unknown kafka.rebalance_strategy %s", name)
+}
+
 type KafkaTopic[T any] struct {
-	topic        string
-	encoder      Encoder
-	topicStorage *app.Storage
+	topic   string
+	encoder Encoder
 }
 
 func Topics() ([]string, error) {
-	return k.consumer.Topics()
+	return k.client.Topics()
 }
 
+// Кодировщик по типу T: string, []byte, protobuf сообщение (proto.Message), иначе json
 func Topic[T any](topic string, encoder ...Encoder) (*KafkaTopic[T], error) {
 	var enc Encoder
 	var t interface{}
@@ -83,115 +150,223 @@ func Topic[T any](topic string, encoder ...Encoder) (*KafkaTopic[T], error) {
 	default:
 		enc = JsonEncoder()
 	}
+	var zero T
+	if _, ok := interface{}(zero).(proto.Message); ok {
+		enc = ProtoEncoder()
+	}
 	if len(encoder) != 0 {
 		enc = encoder[0]
 	}
 
-	// Storage for offsets
-	storage, err := app.NewStorage(path.Join("offsets", topic))
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot create storage for kafka topic %s", topic)
-	}
-
 	return &KafkaTopic[T]{
-		topic:        topic,
-		encoder:      enc,
-		topicStorage: storage,
+		topic:   topic,
+		encoder: enc,
 	}, nil
 }
 
-func (t *KafkaTopic[T]) Produce(obj T) error {
+// Сообщение без ключа - в случайную партицию
+func (t *KafkaTopic[T]) Produce(ctx context.Context, obj T) error {
+	return t.ProduceKey(ctx, "", obj)
+}
+
+// ProduceKey отправляет сообщение в партицию по ключу (например, id пользователя):
+// сообщения одного ключа читаются в порядке отправки.
+// Контекст трейса из ctx передается в заголовках сообщения
+func (t *KafkaTopic[T]) ProduceKey(ctx context.Context, key string, obj T) (err error) {
+	ctx, span := tracing.StartKind(ctx, "kafka.produce "+t.topic, trace.SpanKindProducer,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination", t.topic))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
 	msg, err := t.encoder.Decode(obj)
 	if err != nil {
 		return err
 	}
 
-	partition := int32(0) // Partition = 0!
 	message := &sarama.ProducerMessage{
-		Topic:     t.topic,
-		Partition: partition,
-		Value:     sarama.ByteEncoder(msg),
+		Topic: t.topic,
+		Value: sarama.ByteEncoder(msg),
+	}
+	if key != "" {
+		message.Key = sarama.StringEncoder(key)
+		span.SetAttributes(attribute.String("messaging.kafka.message.key", key))
 	}
+	if headersEncoder, ok := t.encoder.(HeadersEncoder); ok {
+		message.Headers = append(message.Headers, headersEncoder.Headers(obj)...)
+	}
+	tracing.InjectKafka(ctx, message)
 
-	_, offset, err := k.producer.SendMessage(message)
+	partition, offset, err := k.producer.SendMessage(message)
 	if err != nil {
+		producedMessages.WithLabelValues(t.topic, "error").Inc()
 		return err
 	}
-	logger.Debug("Kafka message sent to topic %s (offset=%d)", t.topic, offset)
+	producedMessages.WithLabelValues(t.topic, "success").Inc()
+	logger.Debug("Kafka message sent to topic %s (partition=%d, offset=%d)", t.topic, partition, offset)
+	return nil
+}
+
+// StartPolling подписывает топик на группу kafka.group до завершения ctx.
+// Все топики читаются одной группой клиента: при подписке нового топика сессия перезапускается с новым списком.
+// Партиции распределяются между инстансами сервиса, при ребалансе сессия перезапускается.
+// Сообщения одной партиции приходят по порядку, после обработки - CommitOffset
+func (t *KafkaTopic[T]) StartPolling(ctx context.Context) (chan *Message[T], error) {
+	messages := make(chan *Message[T], 1)
+	err := k.subscribe(ctx, t.topic, &topicHandler[T]{topic: t, messages: messages})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot subscribe topic %s", t.topic)
+	}
+	return messages, nil
+}
+
+// Отмечает сообщение обработанным, офсет коммитится в группу
+func (t *KafkaTopic[T]) CommitOffset(msg *Message[T]) error {
+	if msg.session == nil {
+		return errors.Errorf("message of topic %s has no consumer group session", t.topic)
+	}
+	msg.session.MarkMessage(msg.Details, "")
 	return nil
 }
 
-func (t *KafkaTopic[T]) StartPolling() (chan *Message[T], error) {
+// Обработчик партиции топика (по типу сообщений топика)
+type claimHandler interface {
+	consumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error
+}
 
-	//partitionList, err := k.consumer.Partitions(t.topic)
-	//if err != nil {
-	//	return nil, err
-	//}
+// Группа создается при первой подписке и работает до завершения ее ctx
+func (s *KafkaService) subscribe(ctx context.Context, topic string, handler claimHandler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Get last offset from storage
-	initialOffset, err := t.topicStorage.GetInt64(t.topic)
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot get initial offset of topic %s in storage", t.topic)
+	if _, ok := s.handlers[topic]; ok {
+		return errors.New("topic is already polled")
 	}
-	if initialOffset == nil {
-		x := sarama.OffsetOldest
-		initialOffset = &x
+	s.handlers[topic] = handler
+
+	if s.consumerGroup == nil {
+		group, err := sarama.NewConsumerGroupFromClient(s.group, s.client)
+		if err != nil {
+			delete(s.handlers, topic)
+			return errors.Wrap(err, "cannot create consumer group")
+		}
+		s.consumerGroup = group
+		go s.consume(ctx)
+		return nil
 	}
 
-	messages := make(chan *Message[T], 1)
-	partition := int32(0) // Partition 0!
-	pc, err := k.consumer.ConsumePartition(t.topic, partition, *initialOffset)
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot consume broker partition %d", partition)
+	// Текущая сессия читает старый список топиков
+	if s.cancelSession != nil {
+		s.cancelSession()
 	}
+	return nil
+}
 
-	go func(pc sarama.PartitionConsumer) {
-		logger.Info("KAFKA: starting polling messages: Topic <%s>, Offset: <%d>", t.topic, *initialOffset)
-		for message := range pc.Messages() {
-			msg := &Message[T]{
-				Details: message,
-			}
-			err := t.encoder.Encode(message.Value, &msg.Value)
-			if err != nil {
-				//e have incorrect format - skip it
-				logger.ErrorWrap(err, "cannot encode kafka message to receiver type")
-				err := t.CommitOffset(msg)
-				if err != nil {
-					logger.ErrorWrap(err, "cannot commit offset after failed encoding in topic %s", t.topic)
-				}
-				continue
+func (s *KafkaService) consume(ctx context.Context) {
+	defer func() {
+		err := s.consumerGroup.Close()
+		if err != nil {
+			logger.ErrorWrap(err, "cannot close consumer group %s", s.group)
+		}
+	}()
+
+	for {
+		s.mu.Lock()
+		topics := make([]string, 0, len(s.handlers))
+		for topic := range s.handlers {
+			topics = append(topics, topic)
+		}
+		sessionCtx, cancel := context.WithCancel(ctx)
+		s.cancelSession = cancel
+		s.mu.Unlock()
+		sort.Strings(topics)
+
+		logger.Info("KAFKA: starting polling messages: Topics <%s>, Group <%s>", strings.Join(topics, ", "), s.group)
+		// Блокирует на время сессии, завершается при ребалансе и подписке нового топика
+		err := s.consumerGroup.Consume(sessionCtx, topics, &groupHandler{service: s})
+		cancel()
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.ErrorWrap(err, "consumer group %s session failed", s.group)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(consumeRetryDelay):
 			}
-			messages <- msg
 		}
-	}(pc)
+	}
+}
 
-	return messages, nil
+// Передает партиции обработчикам их топиков
+type groupHandler struct {
+	service *KafkaService
 }
 
-func (t *KafkaTopic[T]) CommitOffset(msg *Message[T]) error {
-	lastOffset, err := t.topicStorage.GetInt64(t.topic)
-	if err != nil {
-		return errors.Wrapf(err, "cannot get offset of topic %s in storage", t.topic)
-	}
-	if lastOffset == nil {
-		firstOffset := int64(0)
-		lastOffset = &firstOffset
-	}
-	newOffset := *lastOffset + 1
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	logger.Info("KAFKA: partitions assigned: %v (generation %d)", session.Claims(), session.GenerationID())
+	return nil
+}
 
-	// UserAll last offsets before msg.Offset should be handled
-	// If not then we have problems!
-	if newOffset != msg.Details.Offset+1 {
-		logger.Error("newOffset != msg.Offset+1 in topic %s", t.topic)
-	}
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	logger.Info("KAFKA: partitions revoked: %v", session.Claims())
+	return nil
+}
 
-	// Anyway we commit with message offset+1
-	committedOffset := msg.Details.Offset + 1
-	err = t.topicStorage.PutInt64(t.topic, committedOffset)
-	if err != nil {
-		return errors.Wrapf(err, "cannot put new offset %d of topic %s in storage", committedOffset, t.topic)
+// Вызывается в отдельной горутине на каждую партицию
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	h.service.mu.Lock()
+	handler, ok := h.service.handlers[claim.Topic()]
+	h.service.mu.Unlock()
+	if !ok {
+		return errors.Errorf("no handler for topic %s", claim.Topic())
 	}
+	return handler.consumeClaim(session, claim)
+}
 
-	return nil
+type topicHandler[T any] struct {
+	topic    *KafkaTopic[T]
+	messages chan *Message[T]
+}
+
+// Сообщения партиции передаются в канал StartPolling
+func (h *topicHandler[T]) consumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	partition := strconv.FormatInt(int64(claim.Partition()), 10)
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			consumedMessages.WithLabelValues(h.topic.topic).Inc()
+			// Сообщения партиции, которые еще не получены (HighWaterMarkOffset - следующий offset)
+			consumerLag.WithLabelValues(h.topic.topic, partition).Set(float64(claim.HighWaterMarkOffset() - message.Offset - 1))
+
+			msg := &Message[T]{
+				Details: message,
+				session: session,
+			}
+			err := h.topic.encoder.Encode(message.Value, &msg.Value)
+			if err != nil {
+				// Incorrect format - skip it
+				logger.ErrorWrap(err, "cannot encode kafka message to receiver type")
+				session.MarkMessage(message, "")
+				continue
+			}
+
+			// Сессия может завершиться (ребаланс), пока обработчик занят
+			select {
+			case h.messages <- msg:
+			case <-session.Context().Done():
+				return nil
+			}
+		}
+	}
 }
@@ -1,7 +1,11 @@
 package kafka
 
 import (
+	"context"
 	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"microservice/app/tracing"
 )
 
 type Committable func()
@@ -9,4 +13,31 @@ type Committable func()
 type Message[T any] struct {
 	Value   T
 	Details *sarama.ConsumerMessage
+
+	// Сессия группы, в которой получено сообщение (для CommitOffset)
+	session sarama.ConsumerGroupSession
+}
+
+// StartSpan начинает спан обработки сообщения, продолжая трейс отправителя (заголовки сообщения)
+func (m *Message[T]) StartSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tracing.StartKind(tracing.ExtractKafka(ctx, m.Details), "kafka.consume "+m.Details.Topic, trace.SpanKindConsumer,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.source", m.Details.Topic),
+		attribute.Int64("messaging.kafka.partition", int64(m.Details.Partition)),
+		attribute.Int64("messaging.kafka.message.offset", m.Details.Offset))
+}
+
+// Header - значение заголовка сообщения, "" если его нет
+func (m *Message[T]) Header(key string) string {
+	for _, header := range m.Details.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// SchemaVersion - версия схемы protobuf сообщения (ProtoEncoder с версией), "" если не указана
+func (m *Message[T]) SchemaVersion() string {
+	return m.Header(HeaderSchemaVersion)
 }
@@ -0,0 +1,21 @@
+package app
+
+import (
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
+	"net/http"
+)
+
+// HTTP сервер для Prometheus (GET /metrics), блокирующий
+func RunMetricsServer() {
+	host := viper.GetString("metrics.host")
+	port := viper.GetString("metrics.port")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Info("Metrics server listening at %s:%s", host, port)
+	if err := http.ListenAndServe(host+":"+port, mux); err != nil {
+		log.Fatal("%v", err)
+	}
+}
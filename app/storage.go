@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"time"
 )
 
 func InitStorage() error {
@@ -48,12 +49,23 @@ func (s *Storage) PutString(key, value string) error {
 	return nil
 }
 
+func (s *Storage) PutStringWithTTL(key, value string, ttl time.Duration) error {
+	err := s.db.PutWithTTL([]byte(key), []byte(value), ttl)
+	if err != nil {
+		return errors.Wrapf(err, "cannot put to storage %s", s.name)
+	}
+	return nil
+}
+
 func (s *Storage) GetString(key string) (*string, error) {
 	has := s.db.Has([]byte(key))
 	if !has {
 		return nil, nil
 	}
 	value, err := s.db.Get([]byte(key))
+	if errors.Is(err, bitcask.ErrKeyExpired) || errors.Is(err, bitcask.ErrKeyNotFound) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get value (key=%s) from storage %s", key, s.name)
 	}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"os"
+)
+
+var tracerProvider *sdktrace.TracerProvider
+
+// TRACING EXPORTERS
+const (
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+func TracingEnabled() bool {
+	return viper.GetBool("tracing.enabled")
+}
+
+// InitTracing OpenTelemetry: экспорт в stdout (локально) или OTLP gRPC коллектор
+func InitTracing(ctx context.Context) error {
+	// Контекст трейса принимается и передается всегда, даже при выключенном экспорте
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !TracingEnabled() {
+		return nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch viper.GetString("tracing.exporter") {
+	case TracingExporterOTLP:
+		options := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(viper.GetString("tracing.otlp.endpoint")),
+		}
+		if viper.GetBool("tracing.otlp.insecure") {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, options...)
+	case TracingExporterStdout, "":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return errors.Errorf("unknown tracing exporter %s", viper.GetString("tracing.exporter"))
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot create tracing exporter")
+	}
+
+	serviceName := viper.GetString("tracing.service_name")
+	if serviceName == "" {
+		serviceName = "microservice"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return errors.Wrap(err, "cannot create tracing resource")
+	}
+
+	ratio := 1.0
+	if viper.IsSet("tracing.sample_ratio") {
+		ratio = viper.GetFloat64("tracing.sample_ratio")
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	log.Info("Tracing was initialized (exporter: %s)", viper.GetString("tracing.exporter"))
+	return nil
+}
+
+// ShutdownTracing отправляет накопленные спаны
+func ShutdownTracing(ctx context.Context) error {
+	if tracerProvider == nil {
+		return nil
+	}
+	return tracerProvider.Shutdown(ctx)
+}
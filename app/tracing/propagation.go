@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc/metadata"
+)
+
+// Контекст трейса передается в W3C trace context (traceparent / tracestate)
+// через gRPC metadata и заголовки kafka сообщений
+
+// ExtractGRPC - контекст трейса из входящих gRPC metadata
+func ExtractGRPC(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
+
+// InjectKafka добавляет контекст трейса в заголовки сообщения
+func InjectKafka(ctx context.Context, message *sarama.ProducerMessage) {
+	carrier := &producerCarrier{message: message}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// ExtractKafka - контекст трейса из заголовков полученного сообщения
+func ExtractKafka(ctx context.Context, message *sarama.ConsumerMessage) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, consumerCarrier{message: message})
+}
+
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+type producerCarrier struct {
+	message *sarama.ProducerMessage
+}
+
+func (c *producerCarrier) Get(key string) string {
+	for _, header := range c.message.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c *producerCarrier) Set(key, value string) {
+	for i, header := range c.message.Headers {
+		if string(header.Key) == key {
+			c.message.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.message.Headers = append(c.message.Headers, sarama.RecordHeader{
+		Key:   []byte(key),
+		Value: []byte(value),
+	})
+}
+
+func (c *producerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.message.Headers))
+	for _, header := range c.message.Headers {
+		keys = append(keys, string(header.Key))
+	}
+	return keys
+}
+
+type consumerCarrier struct {
+	message *sarama.ConsumerMessage
+}
+
+func (c consumerCarrier) Get(key string) string {
+	for _, header := range c.message.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Полученное сообщение не меняется
+func (c consumerCarrier) Set(key, value string) {}
+
+func (c consumerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.message.Headers))
+	for _, header := range c.message.Headers {
+		if header != nil {
+			keys = append(keys, string(header.Key))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// Длинные запросы (bulk insert) обрезаются в атрибуте db.statement
+const maxStatementLength = 2048
+
+var dbSystem = attribute.String("db.system", "postgresql")
+
+// Connector оборачивает драйвер БД: каждый QueryContext / ExecContext - отдельный спан.
+// Используется и gorm, так как он работает поверх того же *sql.DB
+func Connector(dsn string, drv driver.Driver) driver.Connector {
+	return &connector{dsn: dsn, drv: drv}
+}
+
+type connector struct {
+	dsn string
+	drv driver.Driver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	_, span := startChild(ctx, "sql.connect")
+	conn, err := c.drv.Open(c.dsn)
+	End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.drv
+}
+
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startStatement(ctx, "sql.query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endStatement(span, err)
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startStatement(ctx, "sql.exec", query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endStatement(span, err)
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	ctx, span := startChild(ctx, "sql.begin")
+	defer span.End()
+
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	// Драйвер без BeginTx
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func startStatement(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	statement := strings.Join(strings.Fields(query), " ")
+	if len(statement) > maxStatementLength {
+		statement = statement[:maxStatementLength]
+	}
+	operation := ""
+	if fields := strings.SplitN(statement, " ", 2); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	return startChild(ctx, name,
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", statement))
+}
+
+// Спаны запросов создаются только внутри трейса: запросы без ctx (r.db.Query, gorm без WithContext)
+// не порождают отдельные трейсы
+func startChild(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return StartKind(ctx, name, trace.SpanKindClient, append([]attribute.KeyValue{dbSystem}, attrs...)...)
+}
+
+// driver.ErrSkip - не ошибка, database/sql выполнит запрос другим способом
+func endStatement(span trace.Span, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		err = nil
+	}
+	End(span, err)
+}
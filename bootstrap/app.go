@@ -1,11 +1,13 @@
 package bootstrap
 
 import (
+	"context"
 	"database/sql"
 	trmsql "github.com/avito-tech/go-transaction-manager/sql"
 	trmcontext "github.com/avito-tech/go-transaction-manager/trm/context"
 	"github.com/avito-tech/go-transaction-manager/trm/manager"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"gorm.io/gorm"
 	"microservice/app"
 	"microservice/app/core"
@@ -15,45 +17,112 @@ import (
 
 func Run(rootPath ...string) error {
 
+	ctx, err := initCore(rootPath...)
+	if err != nil {
+		return err
+	}
+
+	//
+	//
+	// HERE CORE READY FOR WORK...
+	//
+	//
+	err = RunHooks()
+	if err != nil {
+		return errors.Wrap(err, "cannot run hooks")
+	}
+
+	// CRON
+	if err := initJobs(); err != nil {
+		return errors.Wrap(err, "error while init jobs")
+	}
+
+	if err := job.Start(); err != nil {
+		return errors.Wrap(err, "error while start jobs")
+	}
+
+	// Run gRPC and block
+	go app.RunGRPCServer()
+
+	// Prometheus metrics
+	if viper.GetBool("metrics.enabled") {
+		go app.RunMetricsServer()
+	}
+
+	// REST (webhooks)
+	if err := RunRest(); err != nil {
+		return errors.Wrap(err, "error while run rest")
+	}
+
+	// KAFKA consumers
+	if err := RunConsumers(ctx); err != nil {
+		return errors.Wrap(err, "error while run consumers")
+	}
+
+	// End context
+	<-ctx.Done()
+
+	// Отправляем накопленные спаны
+	if err := app.ShutdownTracing(context.Background()); err != nil {
+		return errors.Wrap(err, "cannot shutdown tracing")
+	}
+
+	return nil
+}
+
+// Everything that is needed both for server and for CLI commands
+func initCore(rootPath ...string) (context.Context, error) {
+
 	// ENV, etc
 	ctx, _, err := app.InitApp(rootPath...)
 	if err != nil {
-		return errors.Wrap(err, "error while init app")
+		return nil, errors.Wrap(err, "error while init app")
 	}
 
 	// Logger
 	logger, err := app.InitLogs(rootPath...)
 	if err != nil {
-		return errors.Wrap(err, "error while init logs")
+		return nil, errors.Wrap(err, "error while init logs")
+	}
+
+	// Tracing (до БД - соединение оборачивается для спанов запросов)
+	err = app.InitTracing(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while init tracing")
 	}
 
 	// Storage
 	err = app.InitStorage()
 	if err != nil {
-		return errors.Wrap(err, "error while init storage")
+		return nil, errors.Wrap(err, "error while init storage")
+	}
+
+	blobStore, err := app.InitBlobStore()
+	if err != nil {
+		return nil, errors.Wrap(err, "error while init blob store")
 	}
 
 	// Database
 	db, err := app.InitDatabase()
 	if err != nil {
-		return errors.Wrap(err, "error while init db")
+		return nil, errors.Wrap(err, "error while init db")
 	}
 
 	gormDB, err := app.InitGorm()
 	if err != nil {
-		return errors.Wrap(err, "error while init gorm db")
+		return nil, errors.Wrap(err, "error while init gorm db")
 	}
 
 	// Migrations
 	err = app.RunMigrations(rootPath...)
 	if err != nil {
-		return errors.Wrap(err, "error while making migrations")
+		return nil, errors.Wrap(err, "error while making migrations")
 	}
 
 	// gRPC
 	_, _, err = app.InitGRPCServer()
 	if err != nil {
-		return errors.Wrap(err, "cannot init gRPC")
+		return nil, errors.Wrap(err, "cannot init gRPC")
 	}
 
 	// DI
@@ -62,19 +131,19 @@ func Run(rootPath ...string) error {
 	if err = di.Provide(func() *sql.DB {
 		return db
 	}); err != nil {
-		return errors.Wrap(err, "cannot provide db")
+		return nil, errors.Wrap(err, "cannot provide db")
 	}
 
 	if err = di.Provide(func() *gorm.DB {
 		return gormDB
 	}); err != nil {
-		return errors.Wrap(err, "cannot provide gorm db")
+		return nil, errors.Wrap(err, "cannot provide gorm db")
 	}
 
 	if err = di.Provide(func() *trmsql.CtxGetter {
 		return trmsql.DefaultCtxGetter
 	}); err != nil {
-		return errors.Wrap(err, "cannot provide tx getter")
+		return nil, errors.Wrap(err, "cannot provide tx getter")
 	}
 
 	trManager := manager.Must(
@@ -84,67 +153,37 @@ func Run(rootPath ...string) error {
 	if err = di.Provide(func() *manager.Manager {
 		return trManager
 	}); err != nil {
-		return errors.Wrap(err, "cannot provide tx manager")
+		return nil, errors.Wrap(err, "cannot provide tx manager")
+	}
+
+	if err = di.Provide(func() app.BlobStore {
+		return blobStore
+	}); err != nil {
+		return nil, errors.Wrap(err, "cannot provide blob store")
 	}
 
 	if err = di.Provide(func() core.Logger {
 		return logger
 	}); err != nil {
-		return errors.Wrap(err, "cannot provide logger")
+		return nil, errors.Wrap(err, "cannot provide logger")
 	}
 
 	// CRON
 	err = job.Init(logger, di)
 	if err != nil {
-		return errors.Wrap(err, "cannot init jobs")
+		return nil, errors.Wrap(err, "cannot init jobs")
 	}
 
 	// KAFKA
 	err = kafka.InitKafka(logger)
 	if err != nil {
-		return errors.Wrap(err, "cannot init kafka")
+		return nil, errors.Wrap(err, "cannot init kafka")
 	}
 
 	// CORE
 	if err := initDependencies(di); err != nil {
-		return errors.Wrap(err, "error while init dependencies")
-	}
-
-	//
-	//
-	// HERE CORE READY FOR WORK...
-	//
-	//
-	err = RunHooks()
-	if err != nil {
-		return errors.Wrap(err, "cannot run hooks")
-	}
-
-	// CRON
-	if err := initJobs(); err != nil {
-		return errors.Wrap(err, "error while init jobs")
-	}
-
-	if err := job.Start(); err != nil {
-		return errors.Wrap(err, "error while start jobs")
+		return nil, errors.Wrap(err, "error while init dependencies")
 	}
 
-	// Run gRPC and block
-	go app.RunGRPCServer()
-
-	// Kafka init deps
-	//domain.UserScoreChangedTopic, err = kafka.Topic[*domain.UserScoreChangedEvent]("user_score_changed")
-	//if err != nil {
-	//	return errors.Wrap(err, "cannot initialize kafka")
-	//}
-	//
-	//polling, err := domain.UserScoreChangedTopic.StartPolling()
-	//if err != nil {
-	//	return errors.Wrap(err, "cannot initialize kafka")
-	//}
-
-	// End context
-	<-ctx.Done()
-
-	return nil
+	return ctx, nil
 }
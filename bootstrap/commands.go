@@ -0,0 +1,43 @@
+package bootstrap
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/dig"
+	"microservice/app/core"
+	"microservice/commands"
+)
+
+func initCommands() map[string]interface{} {
+	return map[string]interface{}{
+		"import-tracks": commands.NewImportTracksCommand,
+	}
+}
+
+// RunCommand runs one CLI command instead of server: go run main.go <name> [flags]
+func RunCommand(args []string, rootPath ...string) error {
+
+	if len(args) == 0 {
+		return errors.New("command name is required")
+	}
+
+	name := args[0]
+	constructor, ok := initCommands()[name]
+	if !ok {
+		return errors.Errorf("unknown command %s", name)
+	}
+
+	ctx, err := initCore(rootPath...)
+	if err != nil {
+		return err
+	}
+
+	scope := core.GetDI().Scope(name)
+	err = scope.Provide(constructor, dig.As(new(commands.Command)))
+	if err != nil {
+		return errors.Wrapf(err, "cannot init command %s", name)
+	}
+
+	return scope.Invoke(func(c commands.Command) error {
+		return c.Run(ctx, args[1:])
+	})
+}
@@ -0,0 +1,106 @@
+package bootstrap
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/dig"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"microservice/consumers"
+	"microservice/layers/domain"
+)
+
+func initTopics() error {
+	var err error
+
+	domain.AuthUserDeletedTopic, err = kafka.Topic[*domain.AuthUserDeletedEvent](viper.GetString("kafka.topics.auth_user_deleted"))
+	if err != nil {
+		return errors.Wrap(err, "AuthUserDeletedTopic")
+	}
+
+	domain.ChallengeFinishedTopic, err = kafka.Topic[*domain.ChallengeFinishedEvent](viper.GetString("kafka.topics.challenge_finished"))
+	if err != nil {
+		return errors.Wrap(err, "ChallengeFinishedTopic")
+	}
+
+	domain.ReminderDueTopic, err = kafka.Topic[*domain.ReminderDueEvent](viper.GetString("kafka.topics.reminders"))
+	if err != nil {
+		return errors.Wrap(err, "ReminderDueTopic")
+	}
+
+	domain.WeeklyDigestTopic, err = kafka.Topic[*domain.WeeklyDigest](viper.GetString("kafka.topics.weekly_digest"))
+	if err != nil {
+		return errors.Wrap(err, "WeeklyDigestTopic")
+	}
+
+	domain.UserScoreChangedTopic, err = kafka.Topic[*domain.UserScoreChangedEvent](viper.GetString("kafka.topics.user_score_changed"))
+	if err != nil {
+		return errors.Wrap(err, "UserScoreChangedTopic")
+	}
+
+	domain.TrackMadeTopic, err = kafka.Topic[*domain.TrackMadeEvent](viper.GetString("kafka.topics.track_made"))
+	if err != nil {
+		return errors.Wrap(err, "TrackMadeTopic")
+	}
+
+	domain.SeriesBrokenTopic, err = kafka.Topic[*domain.SeriesBrokenEvent](viper.GetString("kafka.topics.series_broken"))
+	if err != nil {
+		return errors.Wrap(err, "SeriesBrokenTopic")
+	}
+
+	domain.AchievementUnlockedTopic, err = kafka.Topic[*domain.AchievementUnlockedEvent](viper.GetString("kafka.topics.achievement_unlocked"))
+	if err != nil {
+		return errors.Wrap(err, "AchievementUnlockedTopic")
+	}
+
+	domain.SignalsTopic, err = kafka.Topic[*domain.SignalEvent](viper.GetString("kafka.topics.signals"))
+	if err != nil {
+		return errors.Wrap(err, "SignalsTopic")
+	}
+
+	return nil
+}
+
+func initConsumers() map[string]interface{} {
+	return map[string]interface{}{
+		"auth_user_deleted": consumers.NewAuthUserDeletedConsumer,
+		"signals":           consumers.NewSignalsConsumer,
+	}
+}
+
+// Запускает обработчики kafka топиков в фоне (только если kafka включена)
+func RunConsumers(ctx context.Context) error {
+	if !viper.GetBool("kafka.enabled") {
+		return nil
+	}
+
+	if err := initTopics(); err != nil {
+		return errors.Wrap(err, "cannot init kafka topics")
+	}
+
+	di := core.GetDI()
+	for name, constructor := range initConsumers() {
+		name := name
+		scope := di.Scope(name)
+		err := scope.Provide(constructor, dig.As(new(consumers.Consumer)))
+		if err != nil {
+			return errors.Wrapf(err, "cannot init consumer %s", name)
+		}
+
+		err = scope.Invoke(func(log core.Logger, c consumers.Consumer) {
+			go func() {
+				log.Info("Consumer %s started", name)
+				err := c.Run(ctx)
+				if err != nil {
+					log.ErrorWrap(err, "consumer %s stopped", name)
+				}
+			}()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "cannot run consumer %s", name)
+		}
+	}
+
+	return nil
+}
@@ -15,19 +15,41 @@ func initDependencies(di *dig.Container) error {
 	// Repository
 	_ = di.Provide(repos.NewUsersRepo, dig.As(new(domain.UsersRepository)))
 	_ = di.Provide(repos.NewDBCTracksRepo, dig.As(new(domain.DBCTrackRepository)))
+	_ = di.Provide(repos.NewDBCTrackHistoryRepo, dig.As(new(domain.DBCTrackHistoryRepository)))
 	_ = di.Provide(repos.NewDBCCategoriesRepo, dig.As(new(domain.DBCCategoryRepository)))
 	_ = di.Provide(repos.NewDBCUserChallengesRepo, dig.As(new(domain.DBCUserChallengeRepository)))
 	_ = di.Provide(repos.NewDBCChallengesRepo, dig.As(new(domain.DBChallengeInfoRepository)))
+	_ = di.Provide(repos.NewAchievementsRepo, dig.As(new(domain.AchievementsRepository)))
+	_ = di.Provide(repos.NewUserExportsRepo, dig.As(new(domain.UserExportsRepository)))
+	_ = di.Provide(repos.NewDBCSignalRulesRepo, dig.As(new(domain.DBCSignalRulesRepository)))
+	_ = di.Provide(repos.NewDBCRemindersRepo, dig.As(new(domain.DBCRemindersRepository)))
+	_ = di.Provide(repos.NewWeeklyDigestsRepo, dig.As(new(domain.WeeklyDigestsRepository)))
+	_ = di.Provide(repos.NewUserScoreSnapshotsRepo, dig.As(new(domain.UserScoreSnapshotsRepository)))
+	_ = di.Provide(repos.NewOutboxRepo, dig.As(new(domain.OutboxRepository)))
 
 	// Services
 	_ = di.Provide(services.NewPeriodTypeProcessor)
 	_ = di.Provide(services.NewDBCTrackProcessor)
 	_ = di.Provide(services.NewAchievementsProcessor)
+	_ = di.Provide(services.NewImageProcessor)
+	_ = di.Provide(services.NewOutboxWriter)
+	_ = di.Provide(services.NewTracksImportParser)
+	_ = di.Provide(services.NewImportAdapters)
+	_ = di.Provide(services.NewUserDataExporter)
+	_ = di.Provide(services.NewIdempotencyStore)
+	_ = di.Provide(services.NewWeeklyDigestBuilder)
 
 	// Use Cases
 	_ = di.Provide(usecase.NewUsersUseCase, dig.As(new(domain.UsersUseCase)))
 	_ = di.Provide(usecase.NewDBCCategoriesUCase, dig.As(new(domain.DBCCategoryUseCase)))
 	_ = di.Provide(usecase.NewChallengesUseCase, dig.As(new(domain.DBCChallengesUseCase)))
+	_ = di.Provide(usecase.NewDBCImportUCase, dig.As(new(domain.DBCImportUseCase)))
+	_ = di.Provide(usecase.NewUserExportUCase, dig.As(new(domain.UserExportUseCase)))
+	_ = di.Provide(usecase.NewDBCSignalsUCase, dig.As(new(domain.SignalsUseCase)))
+	_ = di.Provide(usecase.NewDBCRemindersUCase, dig.As(new(domain.RemindersUseCase)))
+	_ = di.Provide(usecase.NewDigestsUCase, dig.As(new(domain.DigestsUseCase)))
+	_ = di.Provide(usecase.NewScoreSnapshotsUCase, dig.As(new(domain.ScoreSnapshotsUseCase)))
+	_ = di.Provide(usecase.NewOutboxUCase, dig.As(new(domain.OutboxUseCase)))
 
 	_ = di.Provide(grpc.NewStatusDeliveryService)
 	_ = di.Provide(grpc.NewDBCDeliveryService)
@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
 	"microservice/app/kafka"
 	"reflect"
@@ -40,7 +41,7 @@ func KafkaTest() {
 				Hello: strconv.Itoa(i),
 			}
 
-			err = kafkaTestTopic.Produce(msg)
+			err = kafkaTestTopic.Produce(context.Background(), msg)
 			if err != nil {
 				panic(err)
 			}
@@ -48,7 +49,7 @@ func KafkaTest() {
 		}
 	}()
 
-	messages, err := kafkaTestTopic.StartPolling()
+	messages, err := kafkaTestTopic.StartPolling(context.Background())
 	if err != nil {
 		panic(err)
 	}
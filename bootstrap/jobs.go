@@ -7,5 +7,14 @@ import (
 
 func initJobs() error {
 	job.NewJobWithImmediately(jobs.NewDBCTrackerJob, "0 23 * * *")
+	job.NewJob(jobs.NewDBCImagesCleanerJob, "0 3 * * *")
+	job.NewJob(jobs.NewUserExportsJob, "* * * * *")
+	job.NewJob(jobs.NewUsersDeletionJob, "0 * * * *")
+	job.NewJob(jobs.NewDBCRemindersJob, "* * * * *")
+	job.NewJob(jobs.NewDBCReminderSendsCleanerJob, "40 3 * * *")
+	job.NewJob(jobs.NewWeeklyDigestJob, "0 2 * * 1")
+	job.NewJob(jobs.NewWeeklyDigestRefreshJob, "30 2 * * *")
+	job.NewJob(jobs.NewOutboxRelayJob, "* * * * *")
+	job.NewJob(jobs.NewSignalEventsCleanerJob, "30 3 * * *")
 	return nil
 }
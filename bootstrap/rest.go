@@ -0,0 +1,33 @@
+package bootstrap
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/rest"
+	restDelivery "microservice/layers/delivery/rest"
+)
+
+func initRestDeliveries() error {
+	if err := rest.InitDelivery("/webhooks", restDelivery.NewSignalsDeliveryService); err != nil {
+		return errors.Wrap(err, "SignalsDeliveryService")
+	}
+	return nil
+}
+
+// Запускает REST сервер в фоне (webhooks), только если он включен
+func RunRest() error {
+	if !viper.GetBool("rest.enabled") {
+		return nil
+	}
+
+	if err := rest.Init(); err != nil {
+		return errors.Wrap(err, "cannot init rest")
+	}
+
+	if err := initRestDeliveries(); err != nil {
+		return errors.Wrap(err, "cannot init rest deliveries")
+	}
+
+	go rest.RunServer()
+	return nil
+}
@@ -0,0 +1,8 @@
+package commands
+
+import "context"
+
+// Command is one-shot CLI action: go run main.go <name> [flags]
+type Command interface {
+	Run(ctx context.Context, args []string) error
+}
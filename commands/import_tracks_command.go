@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"os"
+	"path"
+	"strings"
+)
+
+// Usage: import-tracks -user 1 -file history.csv [-format csv|json|loop]
+type ImportTracksCommand struct {
+	log         core.Logger
+	importUCase domain.DBCImportUseCase
+}
+
+func NewImportTracksCommand(log core.Logger,
+	importUCase domain.DBCImportUseCase) *ImportTracksCommand {
+	return &ImportTracksCommand{
+		log:         log,
+		importUCase: importUCase,
+	}
+}
+
+func (c *ImportTracksCommand) Run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import-tracks", flag.ContinueOnError)
+	userId := flags.Int64("user", 0, "user id")
+	file := flags.String("file", "", "path to csv, json, zip or db (loop) file")
+	format := flags.String("format", "", "csv, json or loop (by file extension if empty)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *userId <= 0 || *file == "" {
+		flags.Usage()
+		return errors.New("user and file are required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(path.Ext(*file), ".")
+		// Loop Habit Tracker экспортирует csv в zip архиве, полный бэкап - в sqlite базе
+		if *format == "zip" || *format == "db" {
+			*format = services.ImportFormatLoopHabits
+		}
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return errors.Wrap(err, "cannot read import file")
+	}
+
+	res, err := c.importUCase.ImportBackup(ctx, *userId, *format, data)
+	if err != nil {
+		return errors.Wrap(err, "ImportBackup")
+	}
+
+	fmt.Printf("Status: %s\n", res.StatusCode)
+	fmt.Printf("Challenges created: %d\n", res.ChallengesCreated)
+	fmt.Printf("Tracks imported: %d\n", res.TracksImported)
+	fmt.Printf("Rows rejected: %d\n", len(res.Rejected))
+	for _, row := range res.Rejected {
+		fmt.Printf("  line %d (%s): %s\n", row.Line, row.Challenge, row.Reason)
+	}
+
+	return nil
+}
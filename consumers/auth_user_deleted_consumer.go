@@ -0,0 +1,86 @@
+package consumers
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"time"
+)
+
+// Повторы удаления до успеха: 5s, 10s, 20s ... не чаще раза в 5 минут
+const (
+	authUserDeletedRetryDelay    = 5 * time.Second
+	authUserDeletedMaxRetryDelay = 5 * time.Minute
+)
+
+// Удаляет аккаунт, когда он удален в сервисе авторизации (без grace period)
+type AuthUserDeletedConsumer struct {
+	log        core.Logger
+	usersUCase domain.UsersUseCase
+}
+
+func NewAuthUserDeletedConsumer(log core.Logger,
+	usersUCase domain.UsersUseCase) *AuthUserDeletedConsumer {
+	return &AuthUserDeletedConsumer{
+		log:        log,
+		usersUCase: usersUCase,
+	}
+}
+
+func (c *AuthUserDeletedConsumer) Run(ctx context.Context) error {
+	messages, err := domain.AuthUserDeletedTopic.StartPolling(ctx)
+	if err != nil {
+		return errors.Wrap(err, "StartPolling")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-messages:
+			msgCtx, span := msg.StartSpan(ctx)
+			var removeErr error
+			if msg.Value == nil || msg.Value.UserId <= 0 {
+				c.log.Warn("incorrect auth user deleted event (partition=%d, offset=%d)", msg.Details.Partition, msg.Details.Offset)
+			} else {
+				removeErr = c.remove(msgCtx, msg.Value.UserId)
+			}
+			tracing.End(span, removeErr)
+
+			// Offset не сохраняем: после перезапуска сообщение придет снова и аккаунт будет удален
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			err = domain.AuthUserDeletedTopic.CommitOffset(msg)
+			if err != nil {
+				c.log.ErrorWrap(err, "cannot commit auth user deleted offset (partition=%d, offset=%d)", msg.Details.Partition, msg.Details.Offset)
+			}
+		}
+	}
+}
+
+// Повторяет удаление, пока оно не пройдет: сообщение нельзя пропустить, а следующие ждут его (ctx.Err() - остановка)
+func (c *AuthUserDeletedConsumer) remove(ctx context.Context, userId int64) error {
+	delay := authUserDeletedRetryDelay
+	for attempt := 1; ; attempt++ {
+		res, err := c.usersUCase.Remove(ctx, userId)
+		if err == nil {
+			c.log.Info("User %d removed by auth event: %s", userId, res.StatusCode)
+			return nil
+		}
+		c.log.ErrorWrap(err, "cannot remove user %d (attempt %d)", userId, attempt)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > authUserDeletedMaxRetryDelay {
+			delay = authUserDeletedMaxRetryDelay
+		}
+	}
+}
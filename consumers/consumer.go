@@ -0,0 +1,8 @@
+package consumers
+
+import "context"
+
+// Обработчик сообщений kafka топика (блокирует до завершения ctx)
+type Consumer interface {
+	Run(ctx context.Context) error
+}
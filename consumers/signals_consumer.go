@@ -0,0 +1,93 @@
+package consumers
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"time"
+)
+
+// Повторы ingest до успеха: 5s, 10s, 20s ... не чаще раза в 5 минут
+const (
+	signalsRetryDelay    = 5 * time.Second
+	signalsMaxRetryDelay = 5 * time.Minute
+)
+
+// Отмечает дни челленджей по событиям внешних сервисов (правила DBCSignalRule)
+type SignalsConsumer struct {
+	log          core.Logger
+	signalsUCase domain.SignalsUseCase
+}
+
+func NewSignalsConsumer(log core.Logger,
+	signalsUCase domain.SignalsUseCase) *SignalsConsumer {
+	return &SignalsConsumer{
+		log:          log,
+		signalsUCase: signalsUCase,
+	}
+}
+
+func (c *SignalsConsumer) Run(ctx context.Context) error {
+	messages, err := domain.SignalsTopic.StartPolling(ctx)
+	if err != nil {
+		return errors.Wrap(err, "StartPolling")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-messages:
+			// Ошибка ingest временная (БД): повторяем, повторная доставка безопасна (dbc_signal_events)
+			delay := signalsRetryDelay
+			for attempt := 1; ; attempt++ {
+				err = c.handle(ctx, msg)
+				if err == nil {
+					break
+				}
+				c.log.ErrorWrap(err, "cannot ingest signal event (partition=%d, offset=%d, attempt %d)", msg.Details.Partition, msg.Details.Offset, attempt)
+
+				select {
+				case <-ctx.Done():
+					// Offset не сохраняем: после перезапуска сообщение придет снова
+					return nil
+				case <-time.After(delay):
+				}
+				delay *= 2
+				if delay > signalsMaxRetryDelay {
+					delay = signalsMaxRetryDelay
+				}
+			}
+
+			err = domain.SignalsTopic.CommitOffset(msg)
+			if err != nil {
+				c.log.ErrorWrap(err, "cannot commit signal offset (partition=%d, offset=%d)", msg.Details.Partition, msg.Details.Offset)
+			}
+		}
+	}
+}
+
+// nil - событие обработано или отклонено (повторять бессмысленно)
+func (c *SignalsConsumer) handle(ctx context.Context, msg *kafka.Message[*domain.SignalEvent]) (err error) {
+	ctx, span := msg.StartSpan(ctx)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if msg.Value == nil {
+		c.log.Warn("empty signal event (partition=%d, offset=%d)", msg.Details.Partition, msg.Details.Offset)
+		return nil
+	}
+
+	res, err := c.signalsUCase.Ingest(ctx, msg.Value)
+	if err != nil {
+		return errors.Wrap(err, "Ingest")
+	}
+	if res.StatusCode != domain.Success {
+		c.log.Warn("signal event rejected: %s (partition=%d, offset=%d)", res.StatusCode, msg.Details.Partition, msg.Details.Offset)
+	}
+	return nil
+}
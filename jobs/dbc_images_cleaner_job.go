@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"time"
+)
+
+// Загрузка пишет файлы до сохранения ключа в БД: более новые файлы еще могут быть не сохранены
+const orphanImageGracePeriod = time.Hour
+
+// Удаляет изображения челленджей, на которые больше никто не ссылается
+// (челлендж удален или изображение было заменено)
+type DBCImagesCleanerJob struct {
+	log       core.Logger
+	blobStore app.BlobStore
+
+	challengesRepo domain.DBChallengeInfoRepository
+}
+
+func NewDBCImagesCleanerJob(log core.Logger,
+	blobStore app.BlobStore,
+	challengesRepo domain.DBChallengeInfoRepository) *DBCImagesCleanerJob {
+	return &DBCImagesCleanerJob{
+		log:            log,
+		blobStore:      blobStore,
+		challengesRepo: challengesRepo,
+	}
+}
+
+func (job *DBCImagesCleanerJob) Run() error {
+
+	ctx := context.Background()
+
+	// Файлы читаются до БД: загруженное после List изображение в список не попадет
+	keys, err := job.blobStore.List("challenges/")
+	if err != nil {
+		return errors.Wrap(err, "List")
+	}
+
+	images, err := job.challengesRepo.FetchAllImages(ctx)
+	if err != nil {
+		return errors.Wrap(err, "FetchAllImages")
+	}
+
+	used := make(map[string]bool, len(images)*2)
+	for _, image := range images {
+		used[image] = true
+		used[services.ThumbnailKey(image)] = true
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if used[key] {
+			continue
+		}
+		modTime, err := job.blobStore.ModTime(key)
+		if err != nil {
+			job.log.ErrorWrap(err, "cannot get modification time of image %s", key)
+			continue
+		}
+		if modTime.IsZero() || time.Since(modTime) < orphanImageGracePeriod {
+			continue
+		}
+		err = job.blobStore.Remove(key)
+		if err != nil {
+			job.log.ErrorWrap(err, "cannot remove orphan image %s", key)
+			continue
+		}
+		removed++
+	}
+
+	job.log.Info("Orphan challenge images removed: %d", removed)
+	return nil
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+// Удаляет старые отметки об отправке напоминаний
+type DBCReminderSendsCleanerJob struct {
+	log            core.Logger
+	remindersUCase domain.RemindersUseCase
+}
+
+func NewDBCReminderSendsCleanerJob(log core.Logger,
+	remindersUCase domain.RemindersUseCase) *DBCReminderSendsCleanerJob {
+	return &DBCReminderSendsCleanerJob{
+		log:            log,
+		remindersUCase: remindersUCase,
+	}
+}
+
+func (job *DBCReminderSendsCleanerJob) Run() error {
+	deleted, err := job.remindersUCase.PruneSends(context.Background(), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "PruneSends")
+	}
+	if deleted > 0 {
+		job.log.Info("Reminder sends removed: %d", deleted)
+	}
+	return nil
+}
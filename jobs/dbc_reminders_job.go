@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+// Отправляет наступившие напоминания об отметке челленджей
+type DBCRemindersJob struct {
+	log            core.Logger
+	remindersUCase domain.RemindersUseCase
+}
+
+func NewDBCRemindersJob(log core.Logger,
+	remindersUCase domain.RemindersUseCase) *DBCRemindersJob {
+	return &DBCRemindersJob{
+		log:            log,
+		remindersUCase: remindersUCase,
+	}
+}
+
+func (job *DBCRemindersJob) Run() error {
+	err := job.remindersUCase.ProcessDue(context.Background(), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "ProcessDue")
+	}
+	return nil
+}
@@ -5,8 +5,10 @@ import (
 	"github.com/avito-tech/go-transaction-manager/trm/manager"
 	"github.com/pkg/errors"
 	"microservice/app/core"
+	appjob "microservice/app/job"
 	"microservice/layers/domain"
 	"microservice/layers/services"
+	"time"
 )
 
 type DBCTrackerJob struct {
@@ -19,6 +21,8 @@ type DBCTrackerJob struct {
 	challengesRepo domain.DBCUserChallengeRepository
 	tracksRepo     domain.DBCTrackRepository
 	usersRepo      domain.UsersRepository
+
+	snapshotsUCase domain.ScoreSnapshotsUseCase
 }
 
 func NewDBCTrackerJob(log core.Logger,
@@ -27,7 +31,8 @@ func NewDBCTrackerJob(log core.Logger,
 	challengesRepo domain.DBCUserChallengeRepository,
 	tracksRepo domain.DBCTrackRepository,
 	usersRepo domain.UsersRepository,
-	trackProc *services.DBCProcessor) *DBCTrackerJob {
+	trackProc *services.DBCProcessor,
+	snapshotsUCase domain.ScoreSnapshotsUseCase) *DBCTrackerJob {
 	return &DBCTrackerJob{
 		log:            log,
 		trxManager:     trxManager,
@@ -36,6 +41,7 @@ func NewDBCTrackerJob(log core.Logger,
 		challengesRepo: challengesRepo,
 		tracksRepo:     tracksRepo,
 		dbcProc:        trackProc,
+		snapshotsUCase: snapshotsUCase,
 	}
 }
 
@@ -59,6 +65,7 @@ func (job *DBCTrackerJob) Run() error {
 	//Делим на чанки по 1000 и обрабатываем
 	chunkSize := int64(1000)
 	offset := int64(0)
+	processed := 0
 	for {
 		items, err := job.challengesRepo.FetchAll(chunkSize, offset)
 		if err != nil {
@@ -75,6 +82,7 @@ func (job *DBCTrackerJob) Run() error {
 			// ToDo: ошибка для одного пользователя прерывает все?
 			// Also check what we should do with error list
 			// ETK Stack ?
+
 			if item.ChallengeInfo.IsAutoTrack {
 				err := job.dbcProc.ProcessAutoChallengeTracks(ctx, item)
 				if err != nil {
@@ -88,7 +96,24 @@ func (job *DBCTrackerJob) Run() error {
 					continue
 				}
 			}
+
+			// Челлендж на срок завершается, когда его последний день обработан
+			_, err := job.dbcProc.FinishIfEnded(ctx, item)
+			if err != nil {
+				errorList = append(errorList, errors.Wrap(err, "FinishIfEnded"))
+				continue
+			}
+			processed++
 		}
+
+		appjob.Failed(job, len(errorList))
+	}
+	appjob.Processed(job, processed)
+
+	// Снимок очков на конец дня (после фиксации очков за обработанные треки)
+	err := job.snapshotsUCase.SnapshotDaily(ctx, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "SnapshotDaily")
 	}
 
 	return nil
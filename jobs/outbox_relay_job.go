@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	appjob "microservice/app/job"
+	"microservice/layers/domain"
+)
+
+// Отправляет события из outbox в kafka
+type OutboxRelayJob struct {
+	log         core.Logger
+	outboxUCase domain.OutboxUseCase
+}
+
+func NewOutboxRelayJob(log core.Logger,
+	outboxUCase domain.OutboxUseCase) *OutboxRelayJob {
+	return &OutboxRelayJob{
+		log:         log,
+		outboxUCase: outboxUCase,
+	}
+}
+
+func (job *OutboxRelayJob) Run() error {
+	published, err := job.outboxUCase.Relay(context.Background())
+	appjob.Processed(job, published)
+	if err != nil {
+		return errors.Wrap(err, "Relay")
+	}
+	return nil
+}
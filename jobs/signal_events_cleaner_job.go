@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+)
+
+// Удаляет старые id обработанных внешних событий (защита от повторной доставки)
+type SignalEventsCleanerJob struct {
+	log          core.Logger
+	signalsUCase domain.SignalsUseCase
+}
+
+func NewSignalEventsCleanerJob(log core.Logger,
+	signalsUCase domain.SignalsUseCase) *SignalEventsCleanerJob {
+	return &SignalEventsCleanerJob{
+		log:          log,
+		signalsUCase: signalsUCase,
+	}
+}
+
+func (job *SignalEventsCleanerJob) Run() error {
+	deleted, err := job.signalsUCase.PruneEvents(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "PruneEvents")
+	}
+	if deleted > 0 {
+		job.log.Info("Processed signal events removed: %d", deleted)
+	}
+	return nil
+}
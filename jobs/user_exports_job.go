@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+)
+
+// Собирает отложенные выгрузки данных пользователей (большие аккаунты)
+type UserExportsJob struct {
+	log         core.Logger
+	exportUCase domain.UserExportUseCase
+}
+
+func NewUserExportsJob(log core.Logger,
+	exportUCase domain.UserExportUseCase) *UserExportsJob {
+	return &UserExportsJob{
+		log:         log,
+		exportUCase: exportUCase,
+	}
+}
+
+func (job *UserExportsJob) Run() error {
+	err := job.exportUCase.BuildPending(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "BuildPending")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+)
+
+// Удаляет аккаунты, у которых истек grace period после запроса на удаление
+type UsersDeletionJob struct {
+	log        core.Logger
+	usersUCase domain.UsersUseCase
+}
+
+func NewUsersDeletionJob(log core.Logger,
+	usersUCase domain.UsersUseCase) *UsersDeletionJob {
+	return &UsersDeletionJob{
+		log:        log,
+		usersUCase: usersUCase,
+	}
+}
+
+func (job *UsersDeletionJob) Run() error {
+	err := job.usersUCase.DeleteScheduled(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "DeleteScheduled")
+	}
+	return nil
+}
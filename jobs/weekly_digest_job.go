@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/tools"
+	"time"
+)
+
+// Собирает итоги прошедшей недели пользователей
+type WeeklyDigestJob struct {
+	log          core.Logger
+	digestsUCase domain.DigestsUseCase
+}
+
+func NewWeeklyDigestJob(log core.Logger,
+	digestsUCase domain.DigestsUseCase) *WeeklyDigestJob {
+	return &WeeklyDigestJob{
+		log:          log,
+		digestsUCase: digestsUCase,
+	}
+}
+
+func (job *WeeklyDigestJob) Run() error {
+	weekStart := tools.RoundDateTimeToWeek(time.Now()).AddDate(0, 0, -7)
+
+	err := job.digestsUCase.GenerateWeekly(context.Background(), weekStart)
+	if err != nil {
+		return errors.Wrap(err, "GenerateWeekly")
+	}
+	return nil
+}
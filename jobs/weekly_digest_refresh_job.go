@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+// Сутки с запасом: изменения между запусками не теряются
+const weeklyDigestRefreshLookback = 25 * time.Hour
+
+// Пересобирает дайджесты прошедших недель, треки которых изменили после сборки
+type WeeklyDigestRefreshJob struct {
+	log          core.Logger
+	digestsUCase domain.DigestsUseCase
+}
+
+func NewWeeklyDigestRefreshJob(log core.Logger,
+	digestsUCase domain.DigestsUseCase) *WeeklyDigestRefreshJob {
+	return &WeeklyDigestRefreshJob{
+		log:          log,
+		digestsUCase: digestsUCase,
+	}
+}
+
+func (job *WeeklyDigestRefreshJob) Run() error {
+	err := job.digestsUCase.RefreshChanged(context.Background(), time.Now().Add(-weeklyDigestRefreshLookback))
+	if err != nil {
+		return errors.Wrap(err, "RefreshChanged")
+	}
+	return nil
+}
@@ -2,14 +2,19 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
 	"microservice/app"
 	"microservice/app/conv"
 	"microservice/app/core"
 	"microservice/layers/domain"
 	pb "microservice/pkg/pb/api"
 	"microservice/tools"
+	"strconv"
 )
 
 type DBCDeliveryService struct {
@@ -18,17 +23,26 @@ type DBCDeliveryService struct {
 	usersUCase         domain.UsersUseCase
 	dbcCategoriesUCase domain.DBCCategoryUseCase
 	dbcChallengesUCase domain.DBCChallengesUseCase
+	dbcImportUCase     domain.DBCImportUseCase
+	signalsUCase       domain.SignalsUseCase
+	remindersUCase     domain.RemindersUseCase
 }
 
 func NewDBCDeliveryService(log core.Logger,
 	usersUCase domain.UsersUseCase,
 	dbcCategoriesUCase domain.DBCCategoryUseCase,
-	dbcChallengesUCase domain.DBCChallengesUseCase) *DBCDeliveryService {
+	dbcChallengesUCase domain.DBCChallengesUseCase,
+	dbcImportUCase domain.DBCImportUseCase,
+	signalsUCase domain.SignalsUseCase,
+	remindersUCase domain.RemindersUseCase) *DBCDeliveryService {
 	return &DBCDeliveryService{
 		log:                log,
 		usersUCase:         usersUCase,
 		dbcCategoriesUCase: dbcCategoriesUCase,
 		dbcChallengesUCase: dbcChallengesUCase,
+		dbcImportUCase:     dbcImportUCase,
+		signalsUCase:       signalsUCase,
+		remindersUCase:     remindersUCase,
 	}
 }
 
@@ -43,13 +57,31 @@ func (d *DBCDeliveryService) CreateChallenge(ctx context.Context, r *pb.CreateCh
 		return nil, errors.Wrap(err, "cannot extract user_id from context")
 	}
 
-	uCaseRes, err := d.dbcChallengesUCase.UserCreate(&domain.CreateDBCChallengeForm{
+	form := &domain.CreateDBCChallengeForm{
 		UserId:       userId,
 		Name:         r.Name,
 		CategoryName: r.CategoryName,
 		Desc:         r.Desc,
 		IsAutoTrack:  r.IsAutoTrack,
-	})
+		EditWindow:   r.EditWindow,
+		DurationDays: r.DurationDays,
+	}
+	if r.StartDateISO != nil {
+		startDate, err := tools.ParseISO(*r.StartDateISO)
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseISO")
+		}
+		form.StartDate = &startDate
+	}
+	if r.EndDateISO != nil {
+		endDate, err := tools.ParseISO(*r.EndDateISO)
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseISO")
+		}
+		form.EndDate = &endDate
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.UserCreate(ctx, form)
 	if err != nil {
 		return nil, errors.Wrap(err, "CreateChallenge")
 	}
@@ -69,6 +101,67 @@ func (d *DBCDeliveryService) CreateChallenge(ctx context.Context, r *pb.CreateCh
 	return response, nil
 }
 
+func (d *DBCDeliveryService) SetChallengeEditWindow(ctx context.Context, r *pb.SetChallengeEditWindowRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.SetEditWindow(ctx, &domain.SetChallengeEditWindowForm{
+		UserId:      userId,
+		ChallengeId: r.ChallengeId,
+		EditWindow:  r.EditWindow,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "SetEditWindow")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) ReorderChallenges(ctx context.Context, r *pb.ReorderChallengesRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.Reorder(ctx, userId, r.Ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "Reorder")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) SetChallengePinned(ctx context.Context, r *pb.SetChallengePinnedRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.SetPinned(ctx, userId, r.ChallengeId, r.Pinned)
+	if err != nil {
+		return nil, errors.Wrap(err, "SetPinned")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
 func (d *DBCDeliveryService) UpdateCategory(ctx context.Context, r *pb.UpdateCategoriesRequest) (*pb.StatusResponse, error) {
 
 	userId, err := app.ExtractRequestUserId(ctx)
@@ -107,6 +200,81 @@ func (d *DBCDeliveryService) GetChallenges(ctx context.Context, r *pb.GetChallen
 		return nil, errors.Wrap(err, "GetChallenges")
 	}
 
+	return d.userChallengesResponse(uCaseRes), nil
+}
+
+func (d *DBCDeliveryService) GetArchivedChallenges(ctx context.Context, r *pb.EmptyMessage) (*pb.GetUserChallengesResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.UserArchived(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserArchived")
+	}
+
+	return d.userChallengesResponse(uCaseRes), nil
+}
+
+func (d *DBCDeliveryService) RemoveChallenge(ctx context.Context, r *pb.IdRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.Remove(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Remove")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) RestoreChallenge(ctx context.Context, r *pb.IdRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.Restore(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Restore")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) DeleteChallenge(ctx context.Context, r *pb.IdRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.Delete(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Delete")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) userChallengesResponse(uCaseRes domain.UserChallengesListResponse) *pb.GetUserChallengesResponse {
 	response := &pb.GetUserChallengesResponse{
 		Status: &pb.Status{
 			Code:    uCaseRes.StatusCode,
@@ -125,10 +293,22 @@ func (d *DBCDeliveryService) GetChallenges(ctx context.Context, r *pb.GetChallen
 				Image:       pItem.ChallengeInfo.Image,
 				Desc:        pItem.ChallengeInfo.Desc,
 				LastSeries:  pItem.LastSeries,
+				EditWindow:  pItem.ChallengeInfo.EditWindow,
+				StartDate:   conv.NullableTime(pItem.StartDate),
+				EndDate:     conv.NullableTime(pItem.EndDate),
+				Status:      pItem.Status,
+				FinishedAt:  conv.NullableTime(pItem.FinishedAt),
+				Position:    pItem.Position,
+				Pinned:      pItem.Pinned,
+				BestSeries:  pItem.BestSeries,
+				Milestones:  pItem.Milestones,
 				CreatedAt:   timestamppb.New(pItem.CreatedAt),
 				DeletedAt:   conv.NullableTime(pItem.DeletedAt),
 				UpdatedAt:   timestamppb.New(pItem.UpdatedAt),
 				LastTracks:  []*pb.DBTrack{},
+
+				BestSeriesStart: conv.NullableTime(pItem.BestSeriesStart),
+				BestSeriesEnd:   conv.NullableTime(pItem.BestSeriesEnd),
 			}
 			if pItem.ChallengeInfo.Category != nil {
 				p.CategoryId = &pItem.ChallengeInfo.Category.Id
@@ -147,7 +327,7 @@ func (d *DBCDeliveryService) GetChallenges(ctx context.Context, r *pb.GetChallen
 		}
 	}
 
-	return response, nil
+	return response
 }
 
 func (d *DBCDeliveryService) SearchChallenges(ctx context.Context, r *pb.SearchChallengesRequest) (*pb.GetChallengesResponse, error) {
@@ -167,17 +347,21 @@ func (d *DBCDeliveryService) SearchChallenges(ctx context.Context, r *pb.SearchC
 	if uCaseRes.StatusCode == domain.Success {
 		for _, pItem := range uCaseRes.Challenges {
 			p := &pb.DBCChallenge{
-				Id:          pItem.Id,
-				OwnerId:     pItem.OwnerId,
-				IsAutoTrack: pItem.IsAutoTrack,
-				CategoryId:  pItem.CategoryId,
-				Name:        pItem.Name,
-				Image:       pItem.Image,
-				Desc:        pItem.Desc,
-				CreatedAt:   timestamppb.New(pItem.CreatedAt),
-				DeletedAt:   conv.NullableTime(pItem.DeletedAt),
-				UpdatedAt:   timestamppb.New(pItem.UpdatedAt),
-				LastTracks:  []*pb.DBTrack{},
+				Id:           pItem.Id,
+				OwnerId:      pItem.OwnerId,
+				IsAutoTrack:  pItem.IsAutoTrack,
+				CategoryId:   pItem.CategoryId,
+				Name:         pItem.Name,
+				Image:        pItem.Image,
+				Desc:         pItem.Desc,
+				EditWindow:   pItem.EditWindow,
+				StartDate:    conv.NullableTime(pItem.StartDate),
+				EndDate:      conv.NullableTime(pItem.EndDate),
+				DurationDays: pItem.DurationDays,
+				CreatedAt:    timestamppb.New(pItem.CreatedAt),
+				DeletedAt:    conv.NullableTime(pItem.DeletedAt),
+				UpdatedAt:    timestamppb.New(pItem.UpdatedAt),
+				LastTracks:   []*pb.DBTrack{},
 			}
 			if pItem.CategoryId != nil {
 				p.CategoryName = &pItem.Category.Name
@@ -243,7 +427,9 @@ func (d *DBCDeliveryService) TrackDay(ctx context.Context, r *pb.TrackDayRequest
 		ChallengeId: r.ChallengeId,
 		Date:        date,
 		Done:        r.Done,
-	})
+		Note:        r.Note,
+		Mood:        r.Mood,
+	}, app.ExtractRequestIdempotencyKey(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "TrackDay")
 	}
@@ -287,17 +473,364 @@ func (d *DBCDeliveryService) GetMonthTracks(ctx context.Context, r *pb.GetMonthT
 	}
 
 	if uCaseRes.StatusCode == domain.Success {
-		response.Tracks = []*pb.DBTrack{}
-		for _, pTrack := range uCaseRes.Tracks {
-			t := &pb.DBTrack{
-				Date:       timestamppb.New(pTrack.Date),
-				DateString: pTrack.Date.Format("02-01-2006"),
-				Done:       pTrack.Done,
-				LastSeries: pTrack.LastSeries,
-				Score:      pTrack.Score,
-				ScoreDaily: pTrack.ScoreDaily,
-			}
-			response.Tracks = append(response.Tracks, t)
+		response.Tracks = d.tracks(uCaseRes.Tracks)
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) GetTracksRange(ctx context.Context, r *pb.GetTracksRangeRequest) (*pb.GetMonthTracksResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	from, err := tools.ParseISO(r.FromDateISO)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseISO")
+	}
+	to, err := tools.ParseISO(r.ToDateISO)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseISO")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.GetTracksRange(ctx, userId, r.ChallengeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetTracksRange")
+	}
+
+	response := &pb.GetMonthTracksResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		response.Tracks = d.tracks(uCaseRes.Tracks)
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) GetTracksHeatmap(ctx context.Context, r *pb.GetTracksHeatmapRequest) (*pb.GetTracksHeatmapResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.GetHeatmap(ctx, userId, r.ChallengeId, int(r.Year))
+	if err != nil {
+		return nil, errors.Wrap(err, "GetHeatmap")
+	}
+
+	return &pb.GetTracksHeatmapResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Year: int32(uCaseRes.Year),
+		Days: uCaseRes.Days,
+	}, nil
+}
+
+func (d *DBCDeliveryService) tracks(tracks []*domain.DBCTrack) []*pb.DBTrack {
+	result := []*pb.DBTrack{}
+	for _, pTrack := range tracks {
+		t := &pb.DBTrack{
+			Date:       timestamppb.New(pTrack.Date),
+			DateString: pTrack.Date.Format("02-01-2006"),
+			Done:       pTrack.Done,
+			LastSeries: pTrack.LastSeries,
+			Score:      pTrack.Score,
+			ScoreDaily: pTrack.ScoreDaily,
+			Note:       pTrack.Note,
+			Mood:       pTrack.Mood,
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func (d *DBCDeliveryService) UndoLastTrack(ctx context.Context, r *pb.IdRequest) (*pb.UndoLastTrackResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.UndoLastTrack(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "UndoLastTrack")
+	}
+
+	response := &pb.UndoLastTrackResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		response.Date = timestamppb.New(uCaseRes.Date)
+		response.Done = uCaseRes.Done
+		response.LastSeries = uCaseRes.LastSeries
+		response.ScoreDaily = uCaseRes.ScoreDaily
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) GetTrackHistory(ctx context.Context, r *pb.GetTrackHistoryRequest) (*pb.GetTrackHistoryResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.GetTrackHistory(ctx, userId, r.ChallengeId, r.Limit, r.Offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetTrackHistory")
+	}
+
+	response := &pb.GetTrackHistoryResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		History: []*pb.DBCTrackHistory{},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		for _, item := range uCaseRes.History {
+			response.History = append(response.History, &pb.DBCTrackHistory{
+				Id:         item.Id,
+				Date:       timestamppb.New(item.Date),
+				DateString: item.Date.Format("02-01-2006"),
+				OldDone:    item.OldDone,
+				NewDone:    item.NewDone,
+				Source:     item.Source,
+				UndoneAt:   conv.NullableTime(item.UndoneAt),
+				CreatedAt:  timestamppb.New(item.CreatedAt),
+			})
+		}
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) CreateSignalRule(ctx context.Context, r *pb.CreateSignalRuleRequest) (*pb.IdResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.signalsUCase.CreateRule(ctx, &domain.CreateSignalRuleForm{
+		UserId:      userId,
+		ChallengeId: r.ChallengeId,
+		Source:      r.Source,
+		EventType:   r.EventType,
+		Threshold:   r.Threshold,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "CreateRule")
+	}
+
+	return &pb.IdResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Id: uCaseRes.Id,
+	}, nil
+}
+
+func (d *DBCDeliveryService) GetSignalRules(ctx context.Context, r *pb.IdRequest) (*pb.GetSignalRulesResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.signalsUCase.GetRules(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetRules")
+	}
+
+	response := &pb.GetSignalRulesResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Rules: []*pb.DBCSignalRule{},
+	}
+
+	for _, rule := range uCaseRes.Rules {
+		response.Rules = append(response.Rules, &pb.DBCSignalRule{
+			Id:          rule.Id,
+			ChallengeId: rule.ChallengeUserId,
+			Source:      rule.Source,
+			EventType:   rule.EventType,
+			Threshold:   rule.Threshold,
+			CreatedAt:   timestamppb.New(rule.CreatedAt),
+		})
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) RemoveSignalRule(ctx context.Context, r *pb.IdRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.signalsUCase.RemoveRule(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "RemoveRule")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) CreateReminder(ctx context.Context, r *pb.CreateReminderRequest) (*pb.IdResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.remindersUCase.CreateReminder(ctx, &domain.CreateReminderForm{
+		UserId:      userId,
+		ChallengeId: r.ChallengeId,
+		Time:        r.Time,
+		Timezone:    r.Timezone,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "CreateReminder")
+	}
+
+	return &pb.IdResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Id: uCaseRes.Id,
+	}, nil
+}
+
+func (d *DBCDeliveryService) GetReminders(ctx context.Context, r *pb.IdRequest) (*pb.GetRemindersResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.remindersUCase.GetReminders(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetReminders")
+	}
+
+	response := &pb.GetRemindersResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Reminders: []*pb.DBCReminder{},
+	}
+
+	for _, reminder := range uCaseRes.Reminders {
+		response.Reminders = append(response.Reminders, &pb.DBCReminder{
+			Id:          reminder.Id,
+			ChallengeId: reminder.ChallengeUserId,
+			Time:        fmt.Sprintf("%02d:%02d", reminder.Minute/60, reminder.Minute%60),
+			Timezone:    reminder.Timezone,
+			CreatedAt:   timestamppb.New(reminder.CreatedAt),
+		})
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) RemoveReminder(ctx context.Context, r *pb.IdRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.remindersUCase.RemoveReminder(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "RemoveReminder")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) UpdateTrackNote(ctx context.Context, r *pb.UpdateTrackNoteRequest) (*pb.StatusResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	date, err := tools.ParseISO(r.DateISO)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseISO")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.UpdateTrackNote(ctx, &domain.UpdateTrackNoteForm{
+		UserId:      userId,
+		ChallengeId: r.ChallengeId,
+		Date:        date,
+		Note:        r.Note,
+		Mood:        r.Mood,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "UpdateTrackNote")
+	}
+
+	return &pb.StatusResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
+
+func (d *DBCDeliveryService) SearchTrackNotes(ctx context.Context, r *pb.SearchTrackNotesRequest) (*pb.SearchTrackNotesResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.SearchTrackNotes(ctx, userId, r.Search, r.Limit, r.Offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "SearchTrackNotes")
+	}
+
+	response := &pb.SearchTrackNotesResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Notes: []*pb.DBCTrackNote{},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		for _, note := range uCaseRes.Notes {
+			response.Notes = append(response.Notes, &pb.DBCTrackNote{
+				ChallengeId:   note.ChallengeUserId,
+				ChallengeName: note.ChallengeName,
+				Date:          timestamppb.New(note.Date),
+				DateString:    note.Date.Format("02-01-2006"),
+				Done:          note.Done,
+				Note:          note.Note,
+				Mood:          note.Mood,
+			})
 		}
 	}
 
@@ -327,15 +860,19 @@ func (d *DBCDeliveryService) GetChallengeInfo(ctx context.Context, r *pb.IdReque
 	if uCaseRes.StatusCode == domain.Success {
 		response.IsMember = uCaseRes.IsMember
 		response.Challenge = &pb.DBCChallenge{
-			Id:          uCaseRes.Challenge.Id,
-			OwnerId:     uCaseRes.Challenge.OwnerId,
-			CategoryId:  uCaseRes.Challenge.CategoryId,
-			IsAutoTrack: uCaseRes.Challenge.IsAutoTrack,
-			Name:        uCaseRes.Challenge.Name,
-			Desc:        uCaseRes.Challenge.Desc,
-			Image:       uCaseRes.Challenge.Image,
-			CreatedAt:   timestamppb.New(uCaseRes.Challenge.CreatedAt),
-			UpdatedAt:   timestamppb.New(uCaseRes.Challenge.UpdatedAt),
+			Id:           uCaseRes.Challenge.Id,
+			OwnerId:      uCaseRes.Challenge.OwnerId,
+			CategoryId:   uCaseRes.Challenge.CategoryId,
+			IsAutoTrack:  uCaseRes.Challenge.IsAutoTrack,
+			Name:         uCaseRes.Challenge.Name,
+			Desc:         uCaseRes.Challenge.Desc,
+			Image:        uCaseRes.Challenge.Image,
+			EditWindow:   uCaseRes.Challenge.EditWindow,
+			StartDate:    conv.NullableTime(uCaseRes.Challenge.StartDate),
+			EndDate:      conv.NullableTime(uCaseRes.Challenge.EndDate),
+			DurationDays: uCaseRes.Challenge.DurationDays,
+			CreatedAt:    timestamppb.New(uCaseRes.Challenge.CreatedAt),
+			UpdatedAt:    timestamppb.New(uCaseRes.Challenge.UpdatedAt),
 		}
 
 		if uCaseRes.Challenge.Category != nil {
@@ -348,3 +885,236 @@ func (d *DBCDeliveryService) GetChallengeInfo(ctx context.Context, r *pb.IdReque
 
 	return response, nil
 }
+
+func (d *DBCDeliveryService) GetChallengeStats(ctx context.Context, r *pb.IdRequest) (*pb.GetChallengeStatsResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.GetStats(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetStats")
+	}
+
+	response := &pb.GetChallengeStatsResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.Stats != nil {
+		stats := &pb.DBCChallengeStats{
+			CurrentSeries: uCaseRes.Stats.CurrentSeries,
+			BestSeries:    uCaseRes.Stats.BestSeries,
+			TotalDone:     uCaseRes.Stats.TotalDone,
+			Score:         uCaseRes.Stats.Score,
+			Completion:    []*pb.DBCCompletionRate{},
+			Weekdays:      []*pb.DBCWeekdayRate{},
+		}
+		for _, item := range uCaseRes.Stats.Completion {
+			stats.Completion = append(stats.Completion, &pb.DBCCompletionRate{
+				Days:  item.Days,
+				Done:  item.Done,
+				Total: item.Total,
+				Rate:  item.Rate,
+			})
+		}
+		for _, item := range uCaseRes.Stats.Weekdays {
+			stats.Weekdays = append(stats.Weekdays, &pb.DBCWeekdayRate{
+				Weekday: item.Weekday,
+				Done:    item.Done,
+				Total:   item.Total,
+				Rate:    item.Rate,
+			})
+		}
+		response.Stats = stats
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) GetCategoryStats(ctx context.Context, r *pb.GetCategoryStatsRequest) (*pb.GetCategoryStatsResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.GetCategoryStats(ctx, userId, r.Days)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetCategoryStats")
+	}
+
+	response := &pb.GetCategoryStatsResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Categories: []*pb.DBCCategoryStats{},
+	}
+
+	for _, item := range uCaseRes.Categories {
+		stats := &pb.DBCCategoryStats{
+			CategoryId:   item.CategoryId,
+			CategoryName: item.CategoryName,
+			Challenges:   item.Challenges,
+			ActiveSeries: item.ActiveSeries,
+			Windows:      []*pb.DBCCategoryWindowStats{},
+		}
+		for _, window := range item.Windows {
+			stats.Windows = append(stats.Windows, &pb.DBCCategoryWindowStats{
+				Days:       window.Days,
+				Done:       window.Done,
+				Total:      window.Total,
+				Rate:       window.Rate,
+				Score:      window.Score,
+				ScoreShare: window.ScoreShare,
+			})
+		}
+		response.Categories = append(response.Categories, stats)
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) UploadChallengeImage(ctx context.Context, r *pb.UploadChallengeImageRequest) (*pb.UploadChallengeImageResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.UploadImage(ctx, &domain.UploadChallengeImageForm{
+		UserId:      userId,
+		ChallengeId: r.ChallengeId,
+		Data:        r.Data,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "UploadImage")
+	}
+
+	response := &pb.UploadChallengeImageResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		response.Image = uCaseRes.Image
+		response.Thumbnail = uCaseRes.Thumbnail
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) GetChallengeImage(ctx context.Context, r *pb.GetChallengeImageRequest) (*pb.GetChallengeImageResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcChallengesUCase.GetImage(ctx, userId, r.Image, r.Thumbnail)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetImage")
+	}
+
+	response := &pb.GetChallengeImageResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		response.ContentType = uCaseRes.ContentType
+		response.Data = uCaseRes.Data
+	}
+
+	return response, nil
+}
+
+func (d *DBCDeliveryService) ImportTracks(stream pb.DBCService_ImportTracksServer) error {
+	ctx := stream.Context()
+
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	maxRows := int64(d.dbcImportUCase.MaxRows())
+
+	var rows []*domain.ImportTrackRow
+	line := int64(0)
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "Recv")
+		}
+		line++
+
+		// Не копим в памяти поток больше лимита
+		if line > maxRows {
+			return status.Errorf(codes.ResourceExhausted, "import is limited to %d rows", maxRows)
+		}
+
+		row := &domain.ImportTrackRow{
+			Line:      line,
+			Challenge: r.Challenge,
+			Date:      r.Date,
+			Value:     strconv.FormatBool(r.Done),
+		}
+		if r.Value != nil {
+			row.Value = strconv.FormatFloat(*r.Value, 'f', -1, 64)
+		}
+		rows = append(rows, row)
+	}
+
+	uCaseRes, err := d.dbcImportUCase.ImportTracks(ctx, userId, rows)
+	if err != nil {
+		return errors.Wrap(err, "ImportTracks")
+	}
+
+	return stream.SendAndClose(d.importTracksResponse(uCaseRes))
+}
+
+func (d *DBCDeliveryService) ImportBackup(ctx context.Context, r *pb.ImportBackupRequest) (*pb.ImportTracksResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExtractRequestUserId")
+	}
+
+	uCaseRes, err := d.dbcImportUCase.ImportBackup(ctx, userId, r.Format, r.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "ImportBackup")
+	}
+
+	return d.importTracksResponse(uCaseRes), nil
+}
+
+func (d *DBCDeliveryService) importTracksResponse(uCaseRes domain.ImportTracksResponse) *pb.ImportTracksResponse {
+	response := &pb.ImportTracksResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Rejected: []*pb.ImportRejectedRow{},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		response.ChallengesCreated = uCaseRes.ChallengesCreated
+		response.TracksImported = uCaseRes.TracksImported
+		for _, row := range uCaseRes.Rejected {
+			response.Rejected = append(response.Rejected, &pb.ImportRejectedRow{
+				Line:      row.Line,
+				Challenge: row.Challenge,
+				Reason:    row.Reason,
+			})
+		}
+	}
+
+	return response
+}
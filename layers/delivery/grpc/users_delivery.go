@@ -9,19 +9,30 @@ import (
 	"microservice/app/core"
 	"microservice/layers/domain"
 	pb "microservice/pkg/pb/api"
+	"microservice/tools"
+	"time"
 )
 
 type UsersDeliveryService struct {
 	pb.UsersServiceServer
-	log        core.Logger
-	usersUCase domain.UsersUseCase
+	log            core.Logger
+	usersUCase     domain.UsersUseCase
+	exportUCase    domain.UserExportUseCase
+	digestsUCase   domain.DigestsUseCase
+	snapshotsUCase domain.ScoreSnapshotsUseCase
 }
 
 func NewUsersDeliveryService(log core.Logger,
-	usersUCase domain.UsersUseCase) *UsersDeliveryService {
+	usersUCase domain.UsersUseCase,
+	exportUCase domain.UserExportUseCase,
+	digestsUCase domain.DigestsUseCase,
+	snapshotsUCase domain.ScoreSnapshotsUseCase) *UsersDeliveryService {
 	return &UsersDeliveryService{
-		log:        log,
-		usersUCase: usersUCase,
+		log:            log,
+		usersUCase:     usersUCase,
+		exportUCase:    exportUCase,
+		digestsUCase:   digestsUCase,
+		snapshotsUCase: snapshotsUCase,
 	}
 }
 
@@ -52,6 +63,8 @@ func (d *UsersDeliveryService) Info(ctx context.Context, r *pb.IdRequest) (*pb.G
 			CreatedAt:  timestamppb.New(uCaseRes.User.CreatedAt),
 			UpdatedAt:  timestamppb.New(uCaseRes.User.UpdatedAt),
 			DeletedAt:  conv.NullableTime(uCaseRes.User.DeletedAt),
+
+			DeletionScheduledAt: conv.NullableTime(uCaseRes.User.DeletionScheduledAt),
 		}
 	}
 
@@ -85,8 +98,224 @@ func (d *UsersDeliveryService) MyInfo(ctx context.Context, r *pb.EmptyMessage) (
 			CreatedAt:  timestamppb.New(uCaseRes.User.CreatedAt),
 			UpdatedAt:  timestamppb.New(uCaseRes.User.UpdatedAt),
 			DeletedAt:  conv.NullableTime(uCaseRes.User.DeletedAt),
+
+			DeletionScheduledAt: conv.NullableTime(uCaseRes.User.DeletionScheduledAt),
 		}
 	}
 
 	return response, nil
 }
+
+func (d *UsersDeliveryService) ExportMyData(ctx context.Context, r *pb.ExportMyDataRequest) (*pb.ExportMyDataResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot extract user_id from context")
+	}
+
+	uCaseRes, err := d.exportUCase.ExportMyData(ctx, userId, r.Format)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExportMyData")
+	}
+
+	response := &pb.ExportMyDataResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.StatusCode == domain.Success {
+		response.Export = d.userExport(uCaseRes.Export)
+	}
+
+	return response, nil
+}
+
+func (d *UsersDeliveryService) GetMyDataExport(ctx context.Context, r *pb.IdRequest) (*pb.GetMyDataExportResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot extract user_id from context")
+	}
+
+	uCaseRes, err := d.exportUCase.GetMyDataExport(ctx, userId, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetMyDataExport")
+	}
+
+	response := &pb.GetMyDataExportResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+
+	if uCaseRes.Export != nil {
+		response.Export = d.userExport(uCaseRes.Export)
+	}
+	if uCaseRes.StatusCode == domain.Success {
+		response.ContentType = uCaseRes.ContentType
+		response.FileName = uCaseRes.FileName
+		response.Data = uCaseRes.Data
+	}
+
+	return response, nil
+}
+
+func (d *UsersDeliveryService) userExport(export *domain.UserExport) *pb.UserExport {
+	return &pb.UserExport{
+		Id:        export.Id,
+		Format:    export.Format,
+		Status:    export.Status,
+		CreatedAt: timestamppb.New(export.CreatedAt),
+		UpdatedAt: timestamppb.New(export.UpdatedAt),
+	}
+}
+
+func (d *UsersDeliveryService) GetDigest(ctx context.Context, r *pb.GetDigestRequest) (*pb.GetDigestResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot extract user_id from context")
+	}
+
+	var date *time.Time
+	if r.DateISO != nil {
+		x, err := tools.ParseISO(*r.DateISO)
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseISO")
+		}
+		date = &x
+	}
+
+	uCaseRes, err := d.digestsUCase.GetDigest(ctx, userId, date)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetDigest")
+	}
+
+	response := &pb.GetDigestResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}
+	if uCaseRes.Digest != nil {
+		response.Digest = d.weeklyDigest(uCaseRes.Digest)
+	}
+
+	return response, nil
+}
+
+func (d *UsersDeliveryService) weeklyDigest(digest *domain.WeeklyDigest) *pb.WeeklyDigest {
+	result := &pb.WeeklyDigest{
+		UserId:       digest.UserId,
+		WeekStart:    timestamppb.New(digest.WeekStart),
+		WeekEnd:      timestamppb.New(digest.WeekEnd),
+		ScoreDelta:   digest.ScoreDelta,
+		Challenges:   []*pb.WeeklyDigestChallenge{},
+		Achievements: []*pb.WeeklyDigestAchievement{},
+		CreatedAt:    timestamppb.New(digest.CreatedAt),
+	}
+	for _, item := range digest.Challenges {
+		result.Challenges = append(result.Challenges, &pb.WeeklyDigestChallenge{
+			ChallengeId:    item.ChallengeId,
+			Name:           item.Name,
+			DoneCount:      item.DoneCount,
+			TotalCount:     item.TotalCount,
+			CompletionRate: item.CompletionRate,
+			SeriesStart:    item.SeriesStart,
+			SeriesEnd:      item.SeriesEnd,
+		})
+	}
+	for _, item := range digest.Achievements {
+		result.Achievements = append(result.Achievements, &pb.WeeklyDigestAchievement{
+			Id:    item.Id,
+			Title: item.Title,
+			Desc:  item.Desc,
+		})
+	}
+	if digest.BestDay != nil {
+		result.BestDay = &pb.WeeklyDigestDay{
+			Date:      timestamppb.New(digest.BestDay.Date),
+			DoneCount: digest.BestDay.DoneCount,
+		}
+	}
+	return result
+}
+
+func (d *UsersDeliveryService) GetScoreTimeline(ctx context.Context, r *pb.GetScoreTimelineRequest) (*pb.GetScoreTimelineResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot extract user_id from context")
+	}
+
+	from, err := tools.ParseISO(r.FromDateISO)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseISO")
+	}
+	to, err := tools.ParseISO(r.ToDateISO)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseISO")
+	}
+
+	uCaseRes, err := d.snapshotsUCase.GetScoreTimeline(ctx, userId, r.Granularity, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetScoreTimeline")
+	}
+
+	response := &pb.GetScoreTimelineResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		Points: []*pb.ScoreTimelinePoint{},
+	}
+	for _, item := range uCaseRes.Points {
+		response.Points = append(response.Points, &pb.ScoreTimelinePoint{
+			Date:             timestamppb.New(item.Date),
+			Score:            item.Score,
+			ScoreDaily:       item.ScoreDaily,
+			Delta:            item.Delta,
+			ActiveChallenges: item.ActiveChallenges,
+		})
+	}
+
+	return response, nil
+}
+
+func (d *UsersDeliveryService) DeleteMyAccount(ctx context.Context, r *pb.EmptyMessage) (*pb.AccountDeletionResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot extract user_id from context")
+	}
+
+	uCaseRes, err := d.usersUCase.RequestDeletion(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "RequestDeletion")
+	}
+
+	return &pb.AccountDeletionResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		DeletionScheduledAt: conv.NullableTime(uCaseRes.DeletionScheduledAt),
+	}, nil
+}
+
+func (d *UsersDeliveryService) CancelAccountDeletion(ctx context.Context, r *pb.EmptyMessage) (*pb.AccountDeletionResponse, error) {
+	userId, err := app.ExtractRequestUserId(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot extract user_id from context")
+	}
+
+	uCaseRes, err := d.usersUCase.CancelDeletion(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "CancelDeletion")
+	}
+
+	return &pb.AccountDeletionResponse{
+		Status: &pb.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+	}, nil
+}
@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io"
+	"microservice/app/core"
+	"microservice/app/rest"
+	"microservice/layers/domain"
+	"net/http"
+	"strings"
+)
+
+const signatureHeader = "X-Signature"
+const maxSignalBodySize = 64 << 10
+
+// Webhook для событий внешних сервисов.
+// Тело подписывается HMAC-SHA256 общим секретом: X-Signature: sha256=<hex>
+type SignalsDeliveryService struct {
+	log          core.Logger
+	signalsUCase domain.SignalsUseCase
+}
+
+func NewSignalsDeliveryService(log core.Logger, signalsUCase domain.SignalsUseCase) *SignalsDeliveryService {
+	return &SignalsDeliveryService{
+		log:          log,
+		signalsUCase: signalsUCase,
+	}
+}
+
+func (d *SignalsDeliveryService) Route(r *gin.RouterGroup) error {
+	r.Use(rest.GeneralMW, rest.ErrorMW)
+	r.POST("/signals", d.ingest)
+	return nil
+}
+
+func (d *SignalsDeliveryService) ingest(ctx *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxSignalBodySize))
+	if err != nil {
+		_ = ctx.Error(errors.Wrap(err, "ReadAll"))
+		return
+	}
+
+	if !isValidSignature(body, ctx.GetHeader(signatureHeader)) {
+		ctx.JSON(http.StatusUnauthorized, rest.UnauthorizedError())
+		return
+	}
+
+	event := &domain.SignalEvent{}
+	err = json.Unmarshal(body, event)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, rest.ValidationError("incorrect signal event"))
+		return
+	}
+
+	uCaseRes, err := d.signalsUCase.Ingest(ctx.Request.Context(), event)
+	if err != nil {
+		_ = ctx.Error(errors.Wrap(err, "Ingest"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": core.Status{
+			Code:    uCaseRes.StatusCode,
+			Message: uCaseRes.StatusCode,
+		},
+		"tracked": uCaseRes.Tracked,
+	})
+}
+
+// Без настроенного секрета webhook отклоняет все запросы
+func isValidSignature(body []byte, signature string) bool {
+	secret := viper.GetString("signals.webhook_secret")
+	if secret == "" {
+		return false
+	}
+
+	signature = strings.TrimPrefix(signature, "sha256=")
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -2,6 +2,7 @@ package do
 
 import (
 	"errors"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 	"microservice/layers/domain"
 	"time"
@@ -52,6 +53,12 @@ type DBCChallenge struct {
 	Desc           *string
 	IsAutoTrack    bool
 	VisibilityType string
+	PeriodType     string
+	PeriodData     pq.Int64Array `gorm:"type:integer[]"`
+	EditWindow     *int64
+	StartDate      *time.Time
+	EndDate        *time.Time
+	DurationDays   *int64
 }
 
 func NewDBCChallenge(from *domain.DBCChallengeInfo) (*DBCChallenge, error) {
@@ -65,6 +72,14 @@ func NewDBCChallenge(from *domain.DBCChallengeInfo) (*DBCChallenge, error) {
 		Desc:           from.Desc,
 		IsAutoTrack:    from.IsAutoTrack,
 		VisibilityType: from.VisibilityType,
+		PeriodType:     from.Period.Type,
+		EditWindow:     from.EditWindow,
+		StartDate:      from.StartDate,
+		EndDate:        from.EndDate,
+		DurationDays:   from.DurationDays,
+	}
+	for _, x := range from.Period.Data {
+		doItem.PeriodData = append(doItem.PeriodData, int64(x))
 	}
 	if from.Category != nil {
 		doItem.CategoryID = from.Category.Id
@@ -86,9 +101,19 @@ func (m *DBCChallenge) DTO() *domain.DBCChallengeInfo {
 		Image:          m.Image,
 		IsAutoTrack:    m.IsAutoTrack,
 		VisibilityType: m.VisibilityType,
-		UpdatedAt:      m.UpdatedAt,
-		CreatedAt:      m.CreatedAt,
-		DeletedAt:      nil,
+		EditWindow:     m.EditWindow,
+		StartDate:      m.StartDate,
+		EndDate:        m.EndDate,
+		DurationDays:   m.DurationDays,
+		Period: domain.GenerationPeriod{
+			Type: m.PeriodType,
+		},
+		UpdatedAt: m.UpdatedAt,
+		CreatedAt: m.CreatedAt,
+		DeletedAt: nil,
+	}
+	for _, x := range m.PeriodData {
+		obj.Period.Data = append(obj.Period.Data, int(x))
 	}
 	if m.DeletedAt.Valid {
 		obj.DeletedAt = new(time.Time)
@@ -111,6 +136,19 @@ type DBCChallengesUsers struct {
 	Challenge   *DBCChallenge
 
 	LastSeries int64
+
+	BestSeries      int64
+	BestSeriesStart *time.Time
+	BestSeriesEnd   *time.Time
+	Milestones      pq.Int64Array `gorm:"type:integer[]"`
+
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Status     string
+	FinishedAt *time.Time
+
+	Position int64
+	Pinned   bool
 }
 
 func (m *DBCChallengesUsers) DTO() *domain.DBCUserChallenge {
@@ -119,12 +157,23 @@ func (m *DBCChallengesUsers) DTO() *domain.DBCUserChallenge {
 	}
 
 	obj := &domain.DBCUserChallenge{
-		Id:         int64(m.ID),
-		UserId:     m.UserID,
-		LastSeries: m.LastSeries,
-		UpdatedAt:  m.UpdatedAt,
-		CreatedAt:  m.CreatedAt,
-		DeletedAt:  nil,
+		Id:              int64(m.ID),
+		ChallengeInfoId: m.ChallengeID,
+		UserId:          m.UserID,
+		LastSeries:      m.LastSeries,
+		BestSeries:      m.BestSeries,
+		BestSeriesStart: m.BestSeriesStart,
+		BestSeriesEnd:   m.BestSeriesEnd,
+		Milestones:      m.Milestones,
+		StartDate:       m.StartDate,
+		EndDate:         m.EndDate,
+		Status:          m.Status,
+		FinishedAt:      m.FinishedAt,
+		Position:        m.Position,
+		Pinned:          m.Pinned,
+		UpdatedAt:       m.UpdatedAt,
+		CreatedAt:       m.CreatedAt,
+		DeletedAt:       nil,
 	}
 	if m.DeletedAt.Valid {
 		obj.DeletedAt = new(time.Time)
@@ -11,6 +11,7 @@ import (
 // - After archive some points in user profile
 type Achievement struct {
 	Id          int64
+	UserId      int64
 	Title       string
 	Desc        string
 	TriggerName string
@@ -22,4 +23,5 @@ type Achievement struct {
 
 type AchievementsRepository interface {
 	FetchAll(ctx context.Context) ([]*Achievement, error)
+	UserFetchAll(ctx context.Context, userId int64) ([]*Achievement, error)
 }
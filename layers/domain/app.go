@@ -6,6 +6,12 @@ const (
 	ValidationError string = "validation_error"
 	NotFound        string = "not_found"
 	AlreadyExists   string = "already_exists"
+	AccessDenied    string = "access_denied"
+	FileTooLarge    string = "file_too_large"
+	UnsupportedFile string = "unsupported_file"
+	InProgress      string = "in_progress"
+	OutOfWindow     string = "out_of_window"
+	OutOfRange      string = "out_of_range"
 	UserLogicError         = "user_error"
 	ServerError            = "server_error"
 )
@@ -27,6 +27,14 @@ type DBCChallengeInfo struct {
 
 	IsAutoTrack    bool
 	VisibilityType string
+	Period         GenerationPeriod
+	// Сколько точек периода назад можно менять треки (nil - значение по умолчанию)
+	EditWindow *int64
+
+	// Челлендж на срок: фиксированные даты или длительность от даты вступления (nil - бессрочный)
+	StartDate    *time.Time
+	EndDate      *time.Time
+	DurationDays *int64
 
 	Name  string
 	Desc  *string
@@ -47,6 +55,22 @@ type DBCUserChallenge struct {
 	LastSeries int64
 	LastTracks []*DBCTrack
 
+	// Рекордная серия (сохраняется после обрыва) и достигнутые рубежи SeriesMilestones
+	BestSeries      int64
+	BestSeriesStart *time.Time
+	BestSeriesEnd   *time.Time
+	Milestones      []int64
+
+	// Даты челленджа для пользователя (nil - бессрочный) и его итог
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Status     string
+	FinishedAt *time.Time
+
+	// Порядок в списке пользователя: закрепленные сверху, далее по Position
+	Position int64
+	Pinned   bool
+
 	UpdatedAt time.Time
 	CreatedAt time.Time
 	DeletedAt *time.Time
@@ -65,14 +89,137 @@ type DBCTrack struct {
 	LastSeries int64
 	Score      int64
 	ScoreDaily int64
+
+	// Заметка и настроение (1..5) пользователя за день
+	Note *string
+	Mood *int64
+}
+
+// Рубежи серии челленджа
+var SeriesMilestones = []int64{7, 30, 100, 365}
+
+// USER CHALLENGE STATUSES
+const (
+	ChallengeStatusActive    = "active"
+	ChallengeStatusCompleted = "completed"
+	ChallengeStatusFailed    = "failed"
+)
+
+// TRACK SOURCES (кто изменил трек)
+const (
+	TrackSourceUser     = "user"
+	TrackSourceAutoFill = "auto_fill"
+	TrackSourceImport   = "import"
+	TrackSourceUndo     = "undo"
+	TrackSourceSignal   = "signal"
+)
+
+// HEATMAP DAY STATES
+const (
+	HeatmapDayNotScheduled = '0' // день не входит в период челленджа
+	HeatmapDayMissed       = '1'
+	HeatmapDayDone         = '2'
+	HeatmapDayPaused       = '3' // челлендж не активен: до вступления, вне срока, в архиве или день еще не прошел
+)
+
+// Версия трека (каждое изменение done)
+type DBCTrackHistory struct {
+	Id              int64
+	UserId          int64
+	ChallengeUserId int64
+
+	Date    time.Time
+	OldDone *bool // nil - трека до изменения не было
+	NewDone bool
+	Source  string
+
+	UndoneAt  *time.Time
+	CreatedAt time.Time
+}
+
+// Заметка к треку (поиск по заметкам пользователя)
+type DBCTrackNote struct {
+	ChallengeUserId int64
+	ChallengeName   string
+
+	Date time.Time
+	Done bool
+	Note string
+	Mood *int64
+}
+
+// Агрегаты по трекам челленджа (треки есть только в точках периода)
+type DBCTrackStats struct {
+	CurrentSeries int64
+	BestSeries    int64
+	TotalDone     int64
+	Score         int64
+	// Первый трек (импорт может добавить треки раньше вступления в челлендж)
+	FirstDate *time.Time
+
+	// Даты выполненных треков за последние 90 дней и число выполненных по дням недели (1 - пн, 7 - вс)
+	DoneDates     []time.Time
+	DoneByWeekday map[int]int64
+}
+
+// Статистика челленджа пользователя
+type DBCChallengeStats struct {
+	CurrentSeries int64
+	BestSeries    int64
+	TotalDone     int64
+	// Очки, полученные за треки челленджа
+	Score int64
+
+	// За 7 / 30 / 90 дней и за все время (Days = 0)
+	Completion []*DBCCompletionRate
+	// Доля выполненных дней по дням недели (только дни, входящие в период челленджа)
+	Weekdays []*DBCWeekdayRate
+}
+
+type DBCCompletionRate struct {
+	Days  int64
+	Done  int64
+	Total int64
+	Rate  float64
+}
+
+type DBCWeekdayRate struct {
+	Weekday int64 // 1 - пн, 7 - вс
+	Done    int64
+	Total   int64
+	Rate    float64
+}
+
+// Статистика категории пользователя по окнам (последние Days дней)
+type DBCCategoryStats struct {
+	// nil - челленджи без категории
+	CategoryId   *int64
+	CategoryName string
+	Challenges   int64
+	// Челленджи с непрерывной текущей серией (LastSeries > 0)
+	ActiveSeries int64
+	Windows      []*DBCCategoryWindowStats
+}
+
+type DBCCategoryWindowStats struct {
+	Days  int64
+	Done  int64
+	Total int64
+	Rate  float64
+	// Очки за треки окна (с учетом штрафов) и доля заработанных очков категории
+	// от заработанных пользователем за окно (штрафы не учитываются, 0 - очков нет)
+	Score      int64
+	ScoreShare float64
 }
 
 // REPOSITORIES
 type DBCCategoryRepository interface {
 	FetchNotEmptyByUserId(int64) ([]*DBCCategory, error)
-	FetchByName(int64, string) (*DBCCategory, error)
+	FetchAllByUserId(int64) ([]*DBCCategory, error)
+	// Читают и пишут в транзакции из ctx (trxManager.Do)
+	FetchByName(ctx context.Context, userId int64, name string) (*DBCCategory, error)
 	FetchById(int64) (*DBCCategory, error)
-	Insert(*DBCCategory) error
+	Insert(ctx context.Context, item *DBCCategory) error
 	Update(*DBCCategory) error
 	Remove(int64, int64) error
 }
@@ -80,7 +227,18 @@ type DBCCategoryRepository interface {
 type DBChallengeInfoRepository interface {
 	// No scope
 	FetchById(int64) (*DBCChallengeInfo, error)
-	Insert(item *DBCChallengeInfo) error
+	// Пишет в транзакции из ctx (trxManager.Do)
+	Insert(ctx context.Context, item *DBCChallengeInfo) error
+	UpdateImage(ctx context.Context, id int64, image *string) error
+	UpdateEditWindow(ctx context.Context, id int64, editWindow *int64) error
+	// Удаляет челлендж, если в нем не осталось участников
+	DeleteIfNoMembers(ctx context.Context, id int64) (bool, error)
+	// Изображения неудаленных челленджей, остальные считаются осиротевшими
+	FetchAllImages(ctx context.Context) ([]string, error)
+	// Неудаленный челлендж публичный или пользователь в нем участвует
+	UserCanView(ctx context.Context, userId, id int64) (bool, error)
+	// Публичные челленджи, в которых есть другие участники, остаются без владельца
+	AnonymizeOwnedShared(ctx context.Context, ownerId int64) (int64, error)
 
 	// Public scope
 	PublicFetchLike(search string, categoryId *int64, limit, offset int64) ([]*DBCChallengeInfo, error)
@@ -90,27 +248,62 @@ type DBCUserChallengeRepository interface {
 	// No scope
 	FetchAll(limit, offset int64) ([]*DBCUserChallenge, error)
 	FetchById(context.Context, int64) (*DBCUserChallenge, error)
-	Insert(*DBCUserChallenge) error
+	// Пишет в транзакции из ctx (trxManager.Do)
+	Insert(ctx context.Context, item *DBCUserChallenge) error
 	Update(*DBCUserChallenge) error
+	// Серии и рубежи после обработки треков (в транзакции)
+	UpdateProgress(ctx context.Context, item *DBCUserChallenge) error
+	// Архивирует челлендж пользователя (deleted_at), треки сохраняются
 	Remove(int64) error
+	Restore(ctx context.Context, id int64) error
+	// Позиция = индекс id в списке (с 1), чужие и архивные челленджи не меняются
+	Reorder(ctx context.Context, userId int64, ids []int64) error
+	SetPinned(ctx context.Context, id int64, pinned bool) error
+	// Удаляет челлендж пользователя навсегда вместе с треками
+	Delete(ctx context.Context, id int64) error
+	// Завершает челлендж на срок (status = completed | failed)
+	Finish(ctx context.Context, id int64, status string) error
 
 	// User scope
 	UserFetchAll(userId int64) ([]*DBCUserChallenge, error)
+	UserFetchArchived(ctx context.Context, userId int64) ([]*DBCUserChallenge, error)
+	UserCountActive(ctx context.Context, userId int64) (int64, error)
 	UserFetchByName(int64, string) (*DBCUserChallenge, error)
 	UserExistsByChallengeId(int64, int64) (bool, error)
 }
 
+type DBCTrackHistoryRepository interface {
+	InsertBulk(ctx context.Context, items []*DBCTrackHistory) error
+	SetUndone(ctx context.Context, id int64) error
+
+	// Challenge scope
+	ChallengeFetchLastNotUndone(ctx context.Context, challengeUserId int64, source string) (*DBCTrackHistory, error)
+	ChallengeFetch(ctx context.Context, challengeUserId int64, limit, offset int64) ([]*DBCTrackHistory, error)
+}
+
 type DBCTrackRepository interface {
 	// No scope
 	SetProcessed(ctx context.Context, trackIds []int64) error
 	InsertOrUpdateBulk(context.Context, []*DBCTrack) error
 
+	// User scope
+	UserFetchAll(ctx context.Context, userId int64) ([]*DBCTrack, error)
+	UserCount(ctx context.Context, userId int64) (int64, error)
+	UserSearchNotes(ctx context.Context, userId int64, search string, limit, offset int64) ([]*DBCTrackNote, error)
+	UserFetchBetween(ctx context.Context, userId int64, from, to time.Time) ([]*DBCTrack, error)
+	// Серии считаются по подряд идущим трекам, т.е. по точкам периода
+	ChallengeStats(ctx context.Context, challengeUserId int64, since time.Time) (*DBCTrackStats, error)
+
 	// Challenge scope
+	// Отсутствующие треки возвращаются с Id = 0 и Done = false
 	ChallengeFetchByDates(challengeId int64, list []time.Time) ([]*DBCTrack, error)
 	ChallengeFetchLastBefore(ctx context.Context, challengeId int64, date time.Time) (*DBCTrack, error)
 	ChallengeFetchLast(ctx context.Context, challengeId int64) (*DBCTrack, error)
 	ChallengeFetchAfter(ctx context.Context, challengeId int64, date time.Time) ([]*DBCTrack, error)
 	ChallengeFetchBetween(ctx context.Context, challengeId int64, from, to time.Time) ([]*DBCTrack, error)
+	ChallengeFetchDoneBetween(ctx context.Context, challengeId int64, from, to time.Time) ([]time.Time, error)
+	// note/mood = nil - не менять, "" / 0 - очистить. false если трека нет
+	ChallengeUpdateNote(ctx context.Context, challengeId int64, date time.Time, note *string, mood *int64) (bool, error)
 
 	// Challenge Not processed scope
 	NotProcessedChallengeFetchAllBefore(ctx context.Context, challengeId int64, date time.Time) ([]*DBCTrack, error)
@@ -132,15 +325,37 @@ type DBCChallengesUseCase interface {
 
 	// User scope
 	UserAll(userId int64) (UserChallengesListResponse, error)
-	UserCreate(form *CreateDBCChallengeForm) (CreateChallengeResponse, error)
+	UserCreate(ctx context.Context, form *CreateDBCChallengeForm) (CreateChallengeResponse, error)
+	UserArchived(ctx context.Context, userId int64) (UserChallengesListResponse, error)
 
 	//
 	Info(userId int64, id int64) (ChallengeInfoResponse, error)
 	Update(ctx context.Context, task *DBCUserChallenge) (StatusResponse, error)
-	Remove(userId, taskId int64) (StatusResponse, error)
-
-	TrackDay(ctx context.Context, form *DBCTrack) (UserGamifyResponse, error)
+	SetEditWindow(ctx context.Context, form *SetChallengeEditWindowForm) (StatusResponse, error)
+	// ids - полный список активных челленджей пользователя в новом порядке
+	Reorder(ctx context.Context, userId int64, ids []int64) (StatusResponse, error)
+	SetPinned(ctx context.Context, userId, challengeId int64, pinned bool) (StatusResponse, error)
+	// Архивирование: челлендж скрывается из списка и не обрабатывается джобой, треки сохраняются
+	Remove(ctx context.Context, userId, challengeId int64) (StatusResponse, error)
+	Restore(ctx context.Context, userId, challengeId int64) (StatusResponse, error)
+	// Удаление навсегда вместе с треками
+	Delete(ctx context.Context, userId, challengeId int64) (StatusResponse, error)
+
+	TrackDay(ctx context.Context, form *DBCTrack, idempotencyKey string) (UserGamifyResponse, error)
 	GetMonthTracks(ctx context.Context, date time.Time, challengeId, userId int64) (*ChallengeMonthTracksResponse, error)
+	GetTracksRange(ctx context.Context, userId, challengeId int64, from, to time.Time) (*ChallengeMonthTracksResponse, error)
+	GetHeatmap(ctx context.Context, userId, challengeId int64, year int) (ChallengeHeatmapResponse, error)
+	UpdateTrackNote(ctx context.Context, form *UpdateTrackNoteForm) (StatusResponse, error)
+	UndoLastTrack(ctx context.Context, userId, challengeId int64) (UndoTrackResponse, error)
+	GetTrackHistory(ctx context.Context, userId, challengeId int64, limit, offset int64) (TrackHistoryResponse, error)
+	SearchTrackNotes(ctx context.Context, userId int64, search string, limit, offset int64) (TrackNotesResponse, error)
+	GetStats(ctx context.Context, userId, challengeId int64) (ChallengeStatsResponse, error)
+	// windows - окна в днях (пусто - 7, 30, 90)
+	GetCategoryStats(ctx context.Context, userId int64, windows []int64) (CategoryStatsResponse, error)
+
+	UploadImage(ctx context.Context, form *UploadChallengeImageForm) (UploadChallengeImageResponse, error)
+	// Изображение доступно участникам челленджа, изображение публичного челленджа - всем
+	GetImage(ctx context.Context, userId int64, image string, thumbnail bool) (ChallengeImageResponse, error)
 }
 
 // IO FORMS (FORMS)
@@ -151,6 +366,33 @@ type CreateDBCChallengeForm struct {
 	Desc         *string
 	CategoryName *string
 	IsAutoTrack  bool
+	EditWindow   *int64
+	// Срок челленджа: EndDate или DurationDays (от StartDate, по умолчанию - сегодня)
+	StartDate    *time.Time
+	EndDate      *time.Time
+	DurationDays *int64
+}
+
+// EditWindow = nil - сбросить на значение по умолчанию
+type SetChallengeEditWindowForm struct {
+	UserId      int64
+	ChallengeId int64
+	EditWindow  *int64
+}
+
+// Note / Mood = nil - не менять, "" / 0 - очистить
+type UpdateTrackNoteForm struct {
+	UserId      int64
+	ChallengeId int64
+	Date        time.Time
+	Note        *string
+	Mood        *int64
+}
+
+type UploadChallengeImageForm struct {
+	UserId      int64
+	ChallengeId int64
+	Data        []byte
 }
 
 // IO FORMS (RESPONSES)
@@ -186,3 +428,50 @@ type ChallengeMonthTracksResponse struct {
 	StatusCode string
 	Tracks     []*DBCTrack
 }
+
+// Тепловая карта года: символ на каждый день (HeatmapDay*)
+type ChallengeHeatmapResponse struct {
+	StatusCode string
+	Year       int
+	Days       string
+}
+
+type UndoTrackResponse struct {
+	StatusCode string
+	Date       time.Time
+	Done       bool
+	LastSeries int64
+	ScoreDaily int64
+}
+
+type TrackHistoryResponse struct {
+	StatusCode string
+	History    []*DBCTrackHistory
+}
+
+type ChallengeStatsResponse struct {
+	StatusCode string
+	Stats      *DBCChallengeStats
+}
+
+type CategoryStatsResponse struct {
+	StatusCode string
+	Categories []*DBCCategoryStats
+}
+
+type TrackNotesResponse struct {
+	StatusCode string
+	Notes      []*DBCTrackNote
+}
+
+type UploadChallengeImageResponse struct {
+	StatusCode string
+	Image      string
+	Thumbnail  string
+}
+
+type ChallengeImageResponse struct {
+	StatusCode  string
+	ContentType string
+	Data        []byte
+}
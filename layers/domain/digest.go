@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+//
+// MODELS
+//
+
+// Итоги недели пользователя (для email / push сервисов)
+type WeeklyDigest struct {
+	Id        int64     `json:"-"`
+	UserId    int64     `json:"user_id"`
+	WeekStart time.Time `json:"week_start"`
+	WeekEnd   time.Time `json:"week_end"`
+
+	// Сумма очков за треки недели
+	ScoreDelta   int64                      `json:"score_delta"`
+	Challenges   []*WeeklyDigestChallenge   `json:"challenges"`
+	Achievements []*WeeklyDigestAchievement `json:"achievements"`
+	// День с наибольшим количеством выполненных челленджей (nil - ничего не выполнено)
+	BestDay *WeeklyDigestDay `json:"best_day"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WeeklyDigestChallenge struct {
+	ChallengeId int64  `json:"challenge_id"`
+	Name        string `json:"name"`
+
+	// Выполнено дней из точек периода челленджа за неделю
+	DoneCount      int64   `json:"done_count"`
+	TotalCount     int64   `json:"total_count"`
+	CompletionRate float64 `json:"completion_rate"`
+
+	// Серия до и после недели (рост - серия набрана, 0 - потеряна)
+	SeriesStart int64 `json:"series_start"`
+	SeriesEnd   int64 `json:"series_end"`
+}
+
+type WeeklyDigestAchievement struct {
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+	Desc  string `json:"desc"`
+}
+
+type WeeklyDigestDay struct {
+	Date      time.Time `json:"date"`
+	DoneCount int64     `json:"done_count"`
+}
+
+//
+// REPOSITORIES
+//
+
+type WeeklyDigestsRepository interface {
+	// Создает или обновляет дайджест недели. false - сохраненный дайджест не изменился
+	Upsert(ctx context.Context, item *WeeklyDigest) (bool, error)
+	UserFetchByWeek(ctx context.Context, userId int64, weekStart time.Time) (*WeeklyDigest, error)
+	// Сохраненные дайджесты недель, которые затронули изменения треков после since (по порядку id)
+	FetchAffectedSince(ctx context.Context, since time.Time, afterId, limit int64) ([]*WeeklyDigest, error)
+}
+
+//
+// USE CASES
+//
+
+type DigestsUseCase interface {
+	// Собирает, сохраняет и публикует дайджесты всех пользователей за неделю weekStart
+	GenerateWeekly(ctx context.Context, weekStart time.Time) error
+	// Пересобирает дайджесты недель, треки которых менялись после since (окно редактирования),
+	// измененные публикуются повторно
+	RefreshChanged(ctx context.Context, since time.Time) error
+	// Дайджест недели, в которую входит date (nil - прошлая неделя)
+	GetDigest(ctx context.Context, userId int64, date *time.Time) (DigestResponse, error)
+}
+
+//
+// IO FORMS (RESPONSES)
+//
+
+type DigestResponse struct {
+	StatusCode string
+	Digest     *WeeklyDigest
+}
@@ -1,7 +1,99 @@
 package domain
 
-import "microservice/app/kafka"
+import (
+	"microservice/app/kafka"
+	"time"
+)
 
+// События ниже пишутся в outbox в транзакции изменения и отправляются OutboxRelayJob'ом
+
+// Изменился счет пользователя (фиксация очков обработанных треков)
 var UserScoreChangedTopic *kafka.KafkaTopic[*UserScoreChangedEvent]
 
-type UserScoreChangedEvent struct{}
+type UserScoreChangedEvent struct {
+	UserId      int64     `json:"user_id"`
+	ChallengeId int64     `json:"challenge_id"`
+	Delta       int64     `json:"delta"`
+	Score       int64     `json:"score"` // users.score после изменения
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// Трек челленджа создан или изменен (любой источник, см. domain.TrackSource*)
+var TrackMadeTopic *kafka.KafkaTopic[*TrackMadeEvent]
+
+type TrackMadeEvent struct {
+	UserId      int64     `json:"user_id"`
+	ChallengeId int64     `json:"challenge_id"`
+	Date        string    `json:"date"` // 2006-01-02
+	Done        bool      `json:"done"`
+	OldDone     *bool     `json:"old_done"` // nil - трека не было
+	Source      string    `json:"source"`
+	MadeAt      time.Time `json:"made_at"`
+}
+
+// Серия челленджа прервалась. Отправляется при обработке треков, когда день уже нельзя изменить
+var SeriesBrokenTopic *kafka.KafkaTopic[*SeriesBrokenEvent]
+
+type SeriesBrokenEvent struct {
+	UserId      int64  `json:"user_id"`
+	ChallengeId int64  `json:"challenge_id"`
+	Date        string `json:"date"`   // день невыполненного трека, 2006-01-02
+	Length      int64  `json:"length"` // длина прерванной серии
+	BestSeries  int64  `json:"best_series"`
+}
+
+// Пользователь получил достижение
+var AchievementUnlockedTopic *kafka.KafkaTopic[*AchievementUnlockedEvent]
+
+type AchievementUnlockedEvent struct {
+	UserId      int64     `json:"user_id"`
+	Kind        string    `json:"kind"` // domain.AchievementKind*
+	ChallengeId int64     `json:"challenge_id"`
+	Value       int64     `json:"value"` // для series_milestone - длина серии
+	UnlockedAt  time.Time `json:"unlocked_at"`
+}
+
+// ACHIEVEMENT KINDS
+const (
+	AchievementKindSeriesMilestone = "series_milestone"
+)
+
+// Аккаунт удален в сервисе авторизации
+var AuthUserDeletedTopic *kafka.KafkaTopic[*AuthUserDeletedEvent]
+
+type AuthUserDeletedEvent struct {
+	UserId int64 `json:"user_id"`
+}
+
+// События внешних сервисов для авто-трекинга челленджей
+var SignalsTopic *kafka.KafkaTopic[*SignalEvent]
+
+// Челлендж на срок завершен (пользователь выполнил или провалил его)
+var ChallengeFinishedTopic *kafka.KafkaTopic[*ChallengeFinishedEvent]
+
+type ChallengeFinishedEvent struct {
+	UserId          int64     `json:"user_id"`
+	ChallengeId     int64     `json:"challenge_id"`
+	ChallengeInfoId int64     `json:"challenge_info_id"`
+	Status          string    `json:"status"`
+	DoneCount       int64     `json:"done_count"`
+	TotalCount      int64     `json:"total_count"`
+	StartDate       time.Time `json:"start_date"`
+	EndDate         time.Time `json:"end_date"`
+}
+
+// Пора отметить день челленджа (для сервиса уведомлений)
+var ReminderDueTopic *kafka.KafkaTopic[*ReminderDueEvent]
+
+type ReminderDueEvent struct {
+	ReminderId    int64     `json:"reminder_id"`
+	UserId        int64     `json:"user_id"`
+	ChallengeId   int64     `json:"challenge_id"`
+	ChallengeName string    `json:"challenge_name"`
+	Date          string    `json:"date"` // день челленджа в часовом поясе напоминания, 2006-01-02
+	Timezone      string    `json:"timezone"`
+	RemindAt      time.Time `json:"remind_at"`
+}
+
+// Итоги недели пользователя
+var WeeklyDigestTopic *kafka.KafkaTopic[*WeeklyDigest]
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Версия формата выгрузки (увеличивать при несовместимых изменениях структуры)
+const UserDataExportVersion = 2
+
+// EXPORT FORMATS
+const (
+	ExportFormatJSON = "json"
+	ExportFormatCSV  = "csv"
+)
+
+// EXPORT STATUSES
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+//
+// MODELS
+//
+
+// Запрос пользователя на выгрузку его данных
+type UserExport struct {
+	Id     int64
+	UserId int64
+
+	Format string
+	Status string
+	File   *string
+	Error  *string
+
+	UpdatedAt time.Time
+	CreatedAt time.Time
+}
+
+// Все, что хранится о пользователе
+type UserDataExport struct {
+	Version     int64
+	GeneratedAt time.Time
+
+	User         *User
+	Categories   []*DBCCategory
+	Challenges   []*DBCUserChallenge
+	Tracks       []*DBCTrack
+	Achievements []*Achievement
+	// Настройки челленджей
+	Reminders   []*DBCReminder
+	SignalRules []*DBCSignalRule
+}
+
+//
+// REPOSITORIES
+//
+
+type UserExportsRepository interface {
+	FetchById(ctx context.Context, id int64) (*UserExport, error)
+	// Блокирует выгрузки до конца транзакции из ctx, занятые другим инстансом пропускает
+	FetchPending(ctx context.Context, limit int64) ([]*UserExport, error)
+	Insert(ctx context.Context, item *UserExport) error
+	Update(ctx context.Context, item *UserExport) error
+}
+
+//
+// USE CASES
+//
+
+type UserExportUseCase interface {
+	ExportMyData(ctx context.Context, userId int64, format string) (ExportMyDataResponse, error)
+	GetMyDataExport(ctx context.Context, userId, exportId int64) (UserDataExportFileResponse, error)
+
+	// Сборка отложенных выгрузок (job)
+	BuildPending(ctx context.Context) error
+}
+
+// IO FORMS (RESPONSES)
+
+type ExportMyDataResponse struct {
+	StatusCode string
+	Export     *UserExport
+}
+
+type UserDataExportFileResponse struct {
+	StatusCode  string
+	Export      *UserExport
+	ContentType string
+	FileName    string
+	Data        []byte
+}
@@ -0,0 +1,66 @@
+package domain
+
+import "context"
+
+// REJECT REASONS
+const (
+	ImportRejectEmptyChallenge = "empty_challenge"
+	ImportRejectInvalidDate    = "invalid_date"
+	ImportRejectInvalidValue   = "invalid_value"
+	ImportRejectFutureDate     = "future_date"
+	ImportRejectNotInPeriod    = "not_in_period"
+	ImportRejectTooManyRows    = "too_many_rows"
+	ImportRejectArchived       = "archived_challenge"
+	ImportRejectOutOfRange     = "out_of_challenge_dates"
+)
+
+//
+// MODELS
+//
+
+// Строка импорта в "сыром" виде (как пришла из файла / стрима)
+type ImportTrackRow struct {
+	Line      int64
+	Challenge string
+	Date      string
+	Value     string
+}
+
+// Описание челленджа из бэкапа другого трекера (период, описание и т.д.)
+type ImportChallenge struct {
+	Name   string
+	Desc   *string
+	Period GenerationPeriod
+}
+
+// Результат разбора файла импорта (адаптером конкретного формата)
+type ImportBundle struct {
+	Challenges []*ImportChallenge
+	Rows       []*ImportTrackRow
+}
+
+type ImportRejectedRow struct {
+	Line      int64
+	Challenge string
+	Reason    string
+}
+
+//
+// USE CASES
+//
+
+type DBCImportUseCase interface {
+	ImportTracks(ctx context.Context, userId int64, rows []*ImportTrackRow) (ImportTracksResponse, error)
+	ImportBackup(ctx context.Context, userId int64, format string, data []byte) (ImportTracksResponse, error)
+	// Максимум строк в одном импорте (import.max_rows)
+	MaxRows() int
+}
+
+// IO FORMS (RESPONSES)
+
+type ImportTracksResponse struct {
+	StatusCode        string
+	ChallengesCreated int64
+	TracksImported    int64
+	Rejected          []*ImportRejectedRow
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+//
+// MODELS
+//
+
+// Событие для kafka, записанное в той же транзакции, что и изменение данных.
+// Отправляется в топик OutboxRelayJob'ом после коммита
+type OutboxEvent struct {
+	Id        int64
+	EventType string
+	Key       string
+	Payload   []byte // json события
+
+	Status        string
+	Attempts      int64
+	LastError     *string
+	NextAttemptAt time.Time
+
+	CreatedAt   time.Time
+	PublishedAt *time.Time
+}
+
+// OUTBOX EVENT STATUS
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+	OutboxStatusFailed    = "failed"
+)
+
+// OUTBOX EVENT TYPES
+const (
+	OutboxEventUserScoreChanged    = "user_score_changed"
+	OutboxEventTrackMade           = "track_made"
+	OutboxEventSeriesBroken        = "series_broken"
+	OutboxEventAchievementUnlocked = "achievement_unlocked"
+	OutboxEventChallengeFinished   = "challenge_finished"
+	OutboxEventReminderDue         = "reminder_due"
+	OutboxEventWeeklyDigest        = "weekly_digest"
+)
+
+//
+// REPOSITORIES
+//
+
+type OutboxRepository interface {
+	// Пишет в транзакции из ctx (trxManager.Do)
+	Insert(ctx context.Context, item *OutboxEvent) error
+	// Блокировка на время транзакции: события отправляет только один инстанс
+	TryLock(ctx context.Context) (bool, error)
+	// Захватывает на lease ожидающие отправки события по порядку id,
+	// пропуская ключи, у которых более раннее событие ждет повтора
+	ClaimDue(ctx context.Context, limit int64, lease time.Duration) ([]*OutboxEvent, error)
+	MarkPublished(ctx context.Context, id int64) error
+	// Следующая попытка через delay
+	MarkRetry(ctx context.Context, id int64, lastError string, delay time.Duration) error
+	MarkFailed(ctx context.Context, id int64, lastError string) error
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+//
+// USE CASES
+//
+
+type OutboxUseCase interface {
+	// Отправляет накопленные события в kafka, возвращает количество отправленных
+	Relay(ctx context.Context) (int, error)
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Напоминание об отметке дня челленджа
+type DBCReminder struct {
+	Id              int64
+	UserId          int64
+	ChallengeUserId int64
+
+	// Минута дня (0..1439) в часовом поясе Timezone
+	Minute   int64
+	Timezone string
+
+	// Ближайшее срабатывание (UTC), DBCRemindersJob выбирает только наступившие
+	NextFireAt time.Time
+
+	CreatedAt time.Time
+}
+
+type DBCRemindersRepository interface {
+	Insert(ctx context.Context, item *DBCReminder) error
+	FetchById(ctx context.Context, id int64) (*DBCReminder, error)
+	Remove(ctx context.Context, id int64) error
+	ChallengeFetchAll(ctx context.Context, challengeUserId int64) ([]*DBCReminder, error)
+	UserFetchAll(ctx context.Context, userId int64) ([]*DBCReminder, error)
+	// Наступившие к now напоминания с id больше afterId (по порядку id)
+	FetchDue(ctx context.Context, now time.Time, afterId, limit int64) ([]*DBCReminder, error)
+	SetNextFireAt(ctx context.Context, id int64, at time.Time) error
+
+	// false - напоминание за этот день уже отправлено
+	InsertSendIfNotExists(ctx context.Context, reminderId int64, date time.Time) (bool, error)
+	DeleteSendsBefore(ctx context.Context, date time.Time) (int64, error)
+}
+
+type RemindersUseCase interface {
+	CreateReminder(ctx context.Context, form *CreateReminderForm) (IdResponse, error)
+	GetReminders(ctx context.Context, userId, challengeId int64) (RemindersResponse, error)
+	RemoveReminder(ctx context.Context, userId, reminderId int64) (StatusResponse, error)
+
+	// Отправляет наступившие напоминания в ReminderDueTopic
+	ProcessDue(ctx context.Context, now time.Time) error
+	// Удаляет старые отметки об отправке, возвращает количество удаленных
+	PruneSends(ctx context.Context, now time.Time) (int64, error)
+}
+
+// IO FORMS (REQUESTS)
+
+type CreateReminderForm struct {
+	UserId      int64
+	ChallengeId int64
+	Time        string // HH:MM
+	Timezone    string // по умолчанию - UTC
+}
+
+// IO FORMS (RESPONSES)
+
+type RemindersResponse struct {
+	StatusCode string
+	Reminders  []*DBCReminder
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+//
+// MODELS
+//
+
+// Снимок очков пользователя на конец дня (DBCTrackerJob)
+type UserScoreSnapshot struct {
+	Id     int64
+	UserId int64
+	Date   time.Time
+
+	Score      int64
+	ScoreDaily int64
+	// Изменение Score относительно предыдущего снимка
+	Delta            int64
+	ActiveChallenges int64
+
+	CreatedAt time.Time
+}
+
+// Точка графика: Date - начало дня / недели (пн) / месяца,
+// Score, ScoreDaily и ActiveChallenges - на последний снимок периода, Delta - сумма за период
+type ScoreTimelinePoint struct {
+	Date             time.Time
+	Score            int64
+	ScoreDaily       int64
+	Delta            int64
+	ActiveChallenges int64
+}
+
+// SCORE TIMELINE GRANULARITY
+const (
+	ScoreGranularityDay   = "day"
+	ScoreGranularityWeek  = "week"
+	ScoreGranularityMonth = "month"
+)
+
+//
+// REPOSITORIES
+//
+
+type UserScoreSnapshotsRepository interface {
+	// Повторный снимок за ту же дату перезаписывается
+	Upsert(ctx context.Context, item *UserScoreSnapshot) error
+	UserFetchTimeline(ctx context.Context, userId int64, granularity string, from, to time.Time) ([]*ScoreTimelinePoint, error)
+}
+
+//
+// USE CASES
+//
+
+type ScoreSnapshotsUseCase interface {
+	// Снимки всех пользователей на дату date
+	SnapshotDaily(ctx context.Context, date time.Time) error
+	GetScoreTimeline(ctx context.Context, userId int64, granularity string, from, to time.Time) (ScoreTimelineResponse, error)
+}
+
+//
+// IO FORMS (RESPONSES)
+//
+
+type ScoreTimelineResponse struct {
+	StatusCode string
+	Points     []*ScoreTimelinePoint
+}
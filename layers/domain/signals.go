@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Событие внешнего сервиса (шаги из фитнес приложения, страницы из читалки, ...)
+type SignalEvent struct {
+	// Id события в источнике, для защиты от повторной доставки (необязательный)
+	Id     string     `json:"id"`
+	UserId int64      `json:"user_id"`
+	Source string     `json:"source"`
+	Type   string     `json:"type"`
+	Value  float64    `json:"value"`
+	Date   *time.Time `json:"date"` // по умолчанию - текущий день (UTC)
+}
+
+// Правило: события source/type пользователя отмечают день челленджа,
+// когда сумма значений за день достигает Threshold
+type DBCSignalRule struct {
+	Id              int64
+	UserId          int64
+	ChallengeUserId int64
+
+	Source    string
+	EventType string
+	Threshold float64
+
+	CreatedAt time.Time
+}
+
+type DBCSignalRulesRepository interface {
+	Insert(ctx context.Context, item *DBCSignalRule) error
+	FetchById(ctx context.Context, id int64) (*DBCSignalRule, error)
+	Remove(ctx context.Context, id int64) error
+	ChallengeFetchAll(ctx context.Context, challengeUserId int64) ([]*DBCSignalRule, error)
+	UserFetchAll(ctx context.Context, userId int64) ([]*DBCSignalRule, error)
+	ChallengeHasRules(ctx context.Context, challengeUserId int64) (bool, error)
+	UserFetchMatching(ctx context.Context, userId int64, source, eventType string) ([]*DBCSignalRule, error)
+
+	// Прибавляет значение к сумме правила за день, возвращает новую сумму
+	AddDayValue(ctx context.Context, ruleId int64, date time.Time, value float64) (float64, error)
+	// false - событие уже было обработано
+	InsertEventIfNotExists(ctx context.Context, source, eventId string) (bool, error)
+	DeleteEventsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SignalsUseCase interface {
+	Ingest(ctx context.Context, event *SignalEvent) (SignalIngestResponse, error)
+	// Удаляет старые id обработанных событий, возвращает количество удаленных
+	PruneEvents(ctx context.Context) (int64, error)
+
+	CreateRule(ctx context.Context, form *CreateSignalRuleForm) (IdResponse, error)
+	GetRules(ctx context.Context, userId, challengeId int64) (SignalRulesResponse, error)
+	RemoveRule(ctx context.Context, userId, ruleId int64) (StatusResponse, error)
+}
+
+// IO FORMS (REQUESTS)
+
+type CreateSignalRuleForm struct {
+	UserId      int64
+	ChallengeId int64
+	Source      string
+	EventType   string
+	Threshold   float64
+}
+
+// IO FORMS (RESPONSES)
+
+type SignalIngestResponse struct {
+	StatusCode string
+	// Сколько дней челленджей отмечено выполненными этим событием
+	Tracked int64
+}
+
+type SignalRulesResponse struct {
+	StatusCode string
+	Rules      []*DBCSignalRule
+}
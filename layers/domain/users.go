@@ -12,6 +12,9 @@ type User struct {
 	Score      int64
 	ScoreDaily int64
 
+	// Аккаунт будет удален после этой даты (если удаление не отменено)
+	DeletionScheduledAt *time.Time
+
 	UpdatedAt time.Time
 	CreatedAt time.Time
 	DeletedAt *time.Time
@@ -19,17 +22,29 @@ type User struct {
 
 type UsersRepository interface {
 	FetchById(int64) (*User, error)
-	Exist(int64) (bool, error)
+	Exist(ctx context.Context, id int64) (bool, error)
 	InsertIfNotExists(*User) error
 	Remove(int64) error
 	Update(*User) error
-	AddScore(ctx context.Context, userId, score int64) error
+	// Возвращает users.score после изменения
+	AddScore(ctx context.Context, userId, score int64) (int64, error)
+
+	// Deletion
+	ScheduleDeletion(ctx context.Context, userId int64, at *time.Time) error
+	FetchScheduledForDeletion(ctx context.Context, before time.Time, limit int64) ([]int64, error)
+	FetchIds(ctx context.Context, limit, offset int64) ([]int64, error)
+	HardDelete(ctx context.Context, userId int64) error
 }
 
 type UsersUseCase interface {
 	Info(context.Context, int64) (GetUserResponse, error)
 	CreateIfNotExists(*User) (CreateUserResponse, error)
-	Remove(int64) (RemoveUserResponse, error)
+	Remove(ctx context.Context, userId int64) (RemoveUserResponse, error)
+
+	// Удаление аккаунта с grace period
+	RequestDeletion(ctx context.Context, userId int64) (AccountDeletionResponse, error)
+	CancelDeletion(ctx context.Context, userId int64) (AccountDeletionResponse, error)
+	DeleteScheduled(ctx context.Context) error
 }
 
 type GetUserResponse struct {
@@ -46,3 +61,8 @@ type RemoveUserResponse struct {
 	StatusCode string
 	Id         int64
 }
+
+type AccountDeletionResponse struct {
+	StatusCode          string
+	DeletionScheduledAt *time.Time
+}
@@ -1,9 +1,12 @@
 package repos
 
 import (
+	"context"
 	"database/sql"
 	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
 	"microservice/app/core"
+	"microservice/layers/domain"
 )
 
 type AchievementsRepo struct {
@@ -15,3 +18,64 @@ type AchievementsRepo struct {
 func NewAchievementsRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *AchievementsRepo {
 	return &AchievementsRepo{log: log, db: db, getter: getter}
 }
+
+func (r *AchievementsRepo) FetchAll(ctx context.Context) ([]*domain.Achievement, error) {
+	query := `select
+    				id,
+    				user_id,
+    				title,
+    				"desc",
+    				trigger_name,
+    				created_at,
+    				updated_at
+				from achievements
+				order by id`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchAll")
+	}
+	return r.scan(rows)
+}
+
+func (r *AchievementsRepo) UserFetchAll(ctx context.Context, userId int64) ([]*domain.Achievement, error) {
+	query := `select
+    				id,
+    				user_id,
+    				title,
+    				"desc",
+    				trigger_name,
+    				created_at,
+    				updated_at
+				from achievements
+				where user_id=$1
+				order by id`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll")
+	}
+	return r.scan(rows)
+}
+
+func (r *AchievementsRepo) scan(rows *sql.Rows) ([]*domain.Achievement, error) {
+	defer rows.Close()
+
+	var result []*domain.Achievement
+	for rows.Next() {
+		item := &domain.Achievement{}
+		err := rows.Scan(
+			&item.Id,
+			&item.UserId,
+			&item.Title,
+			&item.Desc,
+			&item.TriggerName,
+			&item.CreatedAt,
+			&item.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
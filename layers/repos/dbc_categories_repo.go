@@ -1,18 +1,21 @@
 package repos
 
 import (
+	"context"
 	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
 	"microservice/app/core"
 	"microservice/layers/domain"
 )
 
 type DBCCategoriesRepo struct {
-	log core.Logger
-	db  *sql.DB
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
 }
 
-func NewDBCCategoriesRepo(log core.Logger, db *sql.DB) *DBCCategoriesRepo {
-	return &DBCCategoriesRepo{log: log, db: db}
+func NewDBCCategoriesRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *DBCCategoriesRepo {
+	return &DBCCategoriesRepo{log: log, db: db, getter: getter}
 }
 
 func (r *DBCCategoriesRepo) FetchNotEmptyByUserId(userId int64) ([]*domain.DBCCategory, error) {
@@ -24,11 +27,16 @@ func (r *DBCCategoriesRepo) FetchNotEmptyByUserId(userId int64) ([]*domain.DBCCa
 				c.deleted_at
 					from dbc_challenge_categories c
 							 left join dbc_challenges dc on c.id = dc.category_id
+							 left join dbc_challenges_users cu on cu.challenge_id = dc.id and
+																  cu.user_id = c.user_id and
+																  cu.deleted_at is null
 						where c.user_id=$1 and
 							  c.deleted_at is null and
 							  dc.deleted_at is null
 					group by (c.id, c.name, c.created_at, c.updated_at, c.deleted_at)
-					having count(dc.id) > 0`
+					having count(dc.id) > 0
+					-- В порядке челленджей пользователя (см. UserFetchAll)
+					order by bool_or(coalesce(cu.pinned, false)) desc, min(cu.position) nulls last, c.id`
 	rows, err := r.db.Query(query, userId)
 	if err != nil {
 		return nil, err
@@ -83,7 +91,7 @@ func (r *DBCCategoriesRepo) FetchById(id int64) (*domain.DBCCategory, error) {
 	}
 }
 
-func (r *DBCCategoriesRepo) FetchByName(userId int64, name string) (*domain.DBCCategory, error) {
+func (r *DBCCategoriesRepo) FetchByName(ctx context.Context, userId int64, name string) (*domain.DBCCategory, error) {
 	var item = &domain.DBCCategory{
 		UserId: userId,
 		Name:   name,
@@ -97,7 +105,7 @@ func (r *DBCCategoriesRepo) FetchByName(userId int64, name string) (*domain.DBCC
 			where user_id=$1 and name=$2
 			limit 1`
 
-	err := r.db.QueryRow(query, userId, name).Scan(
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, userId, name).Scan(
 		&item.Id,
 		&item.CreatedAt,
 		&item.UpdatedAt,
@@ -112,9 +120,9 @@ func (r *DBCCategoriesRepo) FetchByName(userId int64, name string) (*domain.DBCC
 	}
 }
 
-func (r *DBCCategoriesRepo) Insert(item *domain.DBCCategory) error {
+func (r *DBCCategoriesRepo) Insert(ctx context.Context, item *domain.DBCCategory) error {
 	query := `INSERT INTO dbc_challenge_categories (user_id, name) VALUES ($1, $2) returning id;`
-	err := r.db.QueryRow(query, item.UserId, item.Name).Scan(&item.Id)
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, item.UserId, item.Name).Scan(&item.Id)
 	if err != nil {
 		return err
 	}
@@ -143,3 +151,39 @@ func (r *DBCCategoriesRepo) Remove(userId, id int64) error {
 	}
 	return nil
 }
+
+// Все категории пользователя, включая пустые и удаленные (для выгрузки данных)
+func (r *DBCCategoriesRepo) FetchAllByUserId(userId int64) ([]*domain.DBCCategory, error) {
+	query := `select 
+				id,
+				name,
+				created_at,
+				updated_at,
+				deleted_at
+					from dbc_challenge_categories
+						where user_id=$1
+					order by id`
+	rows, err := r.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCCategory
+	for rows.Next() {
+		item := &domain.DBCCategory{
+			UserId: userId,
+		}
+		err := rows.Scan(&item.Id,
+			&item.Name,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+			&item.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
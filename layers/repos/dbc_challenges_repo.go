@@ -1,8 +1,11 @@
 package repos
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 	"microservice/app/core"
 	"microservice/layers/domain"
@@ -12,10 +15,11 @@ type DBCChallengesRepo struct {
 	log    core.Logger
 	db     *sql.DB
 	gormDB *gorm.DB
+	getter *trmsql.CtxGetter
 }
 
-func NewDBCChallengesRepo(log core.Logger, db *sql.DB, gormDB *gorm.DB) *DBCChallengesRepo {
-	return &DBCChallengesRepo{log: log, db: db, gormDB: gormDB}
+func NewDBCChallengesRepo(log core.Logger, db *sql.DB, gormDB *gorm.DB, getter *trmsql.CtxGetter) *DBCChallengesRepo {
+	return &DBCChallengesRepo{log: log, db: db, gormDB: gormDB, getter: getter}
 }
 
 func (r *DBCChallengesRepo) PublicFetchLike(search string, categoryId *int64, limit, offset int64) ([]*domain.DBCChallengeInfo, error) {
@@ -31,6 +35,12 @@ func (r *DBCChallengesRepo) PublicFetchLike(search string, categoryId *int64, li
       				c.category_id,
       				dcc.name,
 					c.is_auto_track,
+					c.period_type,
+					c.period_data,
+					c.edit_window,
+					c.start_date,
+					c.end_date,
+					c.duration_days,
 					c.name,
 					c.image,
 					c."desc",
@@ -55,12 +65,19 @@ func (r *DBCChallengesRepo) PublicFetchLike(search string, categoryId *int64, li
 		}
 
 		var categoryName *string
+		var periodData pq.Int64Array
 		err := rows.Scan(
 			&item.Id,
 			&item.OwnerId,
 			&item.CategoryId,
 			&categoryName,
 			&item.IsAutoTrack,
+			&item.Period.Type,
+			&periodData,
+			&item.EditWindow,
+			&item.StartDate,
+			&item.EndDate,
+			&item.DurationDays,
 			&item.Name,
 			&item.Image,
 			&item.Desc,
@@ -71,6 +88,7 @@ func (r *DBCChallengesRepo) PublicFetchLike(search string, categoryId *int64, li
 		if err != nil {
 			return nil, err
 		}
+		item.Period.Data = periodDataFromDB(periodData)
 		if categoryName != nil {
 			item.Category = &domain.DBCCategory{
 				Id:   *categoryId,
@@ -84,7 +102,7 @@ func (r *DBCChallengesRepo) PublicFetchLike(search string, categoryId *int64, li
 	return items, nil
 }
 
-func (r *DBCChallengesRepo) Insert(item *domain.DBCChallengeInfo) error {
+func (r *DBCChallengesRepo) Insert(ctx context.Context, item *domain.DBCChallengeInfo) error {
 
 	query := `INSERT INTO dbc_challenges (
                             owner_id, 
@@ -92,15 +110,32 @@ func (r *DBCChallengesRepo) Insert(item *domain.DBCChallengeInfo) error {
                             name, 
                             "desc",
                             is_auto_track,
-                            visibility_type) VALUES ($1, $2, $3, $4, $5, $6) 
+                            visibility_type,
+                            period_type,
+                            period_data,
+                            edit_window,
+                            start_date,
+                            end_date,
+                            duration_days) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) 
                                              RETURNING id`
-	err := r.db.QueryRow(query,
+
+	if item.Period.Type == "" {
+		item.Period.Type = domain.PeriodTypeEveryDay
+	}
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
 		item.OwnerId,
 		item.CategoryId,
 		item.Name,
 		item.Desc,
 		item.IsAutoTrack,
-		item.VisibilityType).Scan(&item.Id)
+		item.VisibilityType,
+		item.Period.Type,
+		periodDataToDB(item.Period.Data),
+		item.EditWindow,
+		item.StartDate,
+		item.EndDate,
+		item.DurationDays).Scan(&item.Id)
 	if err != nil {
 		return err
 	}
@@ -117,6 +152,12 @@ func (r *DBCChallengesRepo) FetchById(id int64) (*domain.DBCChallengeInfo, error
 		dcc.name,
 		c.visibility_type,
 		c.is_auto_track,
+		c.period_type,
+		c.period_data,
+		c.edit_window,
+		c.start_date,
+		c.end_date,
+		c.duration_days,
 		c.owner_id,
 		c.created_at,
 		c.updated_at,
@@ -130,6 +171,7 @@ func (r *DBCChallengesRepo) FetchById(id int64) (*domain.DBCChallengeInfo, error
 	}
 
 	var categoryName *string
+	var periodData pq.Int64Array
 	err := r.db.QueryRow(query, id).Scan(
 		&item.Id,
 		&item.Name,
@@ -139,6 +181,12 @@ func (r *DBCChallengesRepo) FetchById(id int64) (*domain.DBCChallengeInfo, error
 		&categoryName,
 		&item.VisibilityType,
 		&item.IsAutoTrack,
+		&item.Period.Type,
+		&periodData,
+		&item.EditWindow,
+		&item.StartDate,
+		&item.EndDate,
+		&item.DurationDays,
 		&item.OwnerId,
 		&item.CreatedAt,
 		&item.UpdatedAt,
@@ -147,6 +195,7 @@ func (r *DBCChallengesRepo) FetchById(id int64) (*domain.DBCChallengeInfo, error
 	if err != nil {
 		return nil, err
 	}
+	item.Period.Data = periodDataFromDB(periodData)
 	if categoryName != nil && item.CategoryId != nil {
 		item.Category = &domain.DBCCategory{
 			Id:   *item.CategoryId,
@@ -156,3 +205,95 @@ func (r *DBCChallengesRepo) FetchById(id int64) (*domain.DBCChallengeInfo, error
 
 	return item, nil
 }
+
+func (r *DBCChallengesRepo) UpdateImage(ctx context.Context, id int64, image *string) error {
+	query := `UPDATE dbc_challenges 
+				SET image=$2, updated_at=now()
+				WHERE id=$1`
+	_, err := r.db.ExecContext(ctx, query, id, image)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCChallengesRepo) UpdateEditWindow(ctx context.Context, id int64, editWindow *int64) error {
+	query := `UPDATE dbc_challenges 
+				SET edit_window=$2, updated_at=now()
+				WHERE id=$1`
+	_, err := r.db.ExecContext(ctx, query, id, editWindow)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCChallengesRepo) DeleteIfNoMembers(ctx context.Context, id int64) (bool, error) {
+	query := `delete from dbc_challenges c
+				where c.id=$1 and
+				      not exists(select 1 from dbc_challenges_users cu where cu.challenge_id = c.id)`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Все изображения, на которые ссылаются неудаленные челленджи
+func (r *DBCChallengesRepo) FetchAllImages(ctx context.Context) ([]string, error) {
+	query := `select image from dbc_challenges where image is not null and deleted_at is null`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var image string
+		err := rows.Scan(&image)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, image)
+	}
+
+	return result, nil
+}
+
+func (r *DBCChallengesRepo) UserCanView(ctx context.Context, userId, id int64) (bool, error) {
+	query := `select exists(select 1 from dbc_challenges c
+				where c.id = $1 and c.deleted_at is null and
+				      (c.visibility_type = 'public' or
+				       exists(select 1 from dbc_challenges_users cu
+				                      where cu.challenge_id = c.id and cu.user_id = $2)))`
+
+	var ok bool
+	err := r.db.QueryRowContext(ctx, query, id, userId).Scan(&ok)
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func (r *DBCChallengesRepo) AnonymizeOwnedShared(ctx context.Context, ownerId int64) (int64, error) {
+	// Категория принадлежит владельцу и удаляется вместе с ним (on delete cascade)
+	query := `update dbc_challenges c
+				set owner_id=null, category_id=null, updated_at=now()
+				where c.owner_id=$1 and
+				      c.visibility_type='public' and
+				      exists(select 1 from dbc_challenges_users cu
+				                     where cu.challenge_id = c.id and cu.user_id <> $1)`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, ownerId)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -3,6 +3,8 @@ package repos
 import (
 	"context"
 	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 	"microservice/app/core"
 	"microservice/layers/do"
@@ -13,10 +15,11 @@ type DBCUserChallengesRepo struct {
 	log    core.Logger
 	db     *sql.DB
 	gormDB *gorm.DB
+	getter *trmsql.CtxGetter
 }
 
-func NewDBCUserChallengesRepo(log core.Logger, db *sql.DB, gormDB *gorm.DB) *DBCUserChallengesRepo {
-	return &DBCUserChallengesRepo{log: log, db: db, gormDB: gormDB}
+func NewDBCUserChallengesRepo(log core.Logger, db *sql.DB, gormDB *gorm.DB, getter *trmsql.CtxGetter) *DBCUserChallengesRepo {
+	return &DBCUserChallengesRepo{log: log, db: db, gormDB: gormDB, getter: getter}
 }
 
 func (r *DBCUserChallengesRepo) FetchAll(limit, offset int64) ([]*domain.DBCUserChallenge, error) {
@@ -48,7 +51,32 @@ func (r *DBCUserChallengesRepo) UserFetchAll(userId int64) ([]*domain.DBCUserCha
 		Preload("Challenge").
 		Preload("Challenge.Category").
 		Where(&do.DBCChallengeCategory{UserID: userId}).
-		Order("created_at desc").
+		Order("pinned desc, position, created_at desc").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*domain.DBCUserChallenge
+	for _, item := range items {
+		result = append(result, item.DTO())
+	}
+
+	return result, nil
+}
+
+// Архивные челленджи (gorm по умолчанию исключает записи с deleted_at)
+func (r *DBCUserChallengesRepo) UserFetchArchived(ctx context.Context, userId int64) ([]*domain.DBCUserChallenge, error) {
+
+	var items []*do.DBCChallengesUsers
+	err := r.gormDB.
+		WithContext(ctx).
+		Unscoped().
+		Table("dbc_challenges_users").
+		Preload("Challenge").
+		Preload("Challenge.Category").
+		Where("user_id = ? and deleted_at is not null", userId).
+		Order("deleted_at desc").
 		Find(&items).Error
 	if err != nil {
 		return nil, err
@@ -73,8 +101,27 @@ func (r *DBCUserChallengesRepo) FetchById(ctx context.Context, id int64) (*domai
     			c.id,
     			c.user_id,
     			c.challenge_id,
+    			coalesce(ci.owner_id, 0),
+    			ci.name,
+    			ci.image,
     			ci.is_auto_track,
+    			ci.period_type,
+    			ci.period_data,
+    			ci.edit_window,
+    			ci.start_date,
+    			ci.end_date,
+    			ci.duration_days,
     			ci."desc", 
+    			c.start_date,
+    			c.end_date,
+    			c.status,
+    			c.finished_at,
+    			c.position,
+    			c.pinned,
+    			c.best_series,
+    			c.best_series_start,
+    			c.best_series_end,
+    			c.milestones,
     			c.created_at, 
     			c.updated_at,
     			c.deleted_at
@@ -85,16 +132,38 @@ func (r *DBCUserChallengesRepo) FetchById(ctx context.Context, id int64) (*domai
 
 	var categoryId *int64
 	var categoryName *string
+	var periodData pq.Int64Array
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&item.Id,
 		&item.UserId,
 		&item.ChallengeInfoId,
+		&item.ChallengeInfo.OwnerId,
+		&item.ChallengeInfo.Name,
+		&item.ChallengeInfo.Image,
 		&item.ChallengeInfo.IsAutoTrack,
+		&item.ChallengeInfo.Period.Type,
+		&periodData,
+		&item.ChallengeInfo.EditWindow,
+		&item.ChallengeInfo.StartDate,
+		&item.ChallengeInfo.EndDate,
+		&item.ChallengeInfo.DurationDays,
 		&item.ChallengeInfo.Desc,
+		&item.StartDate,
+		&item.EndDate,
+		&item.Status,
+		&item.FinishedAt,
+		&item.Position,
+		&item.Pinned,
+		&item.BestSeries,
+		&item.BestSeriesStart,
+		&item.BestSeriesEnd,
+		(*pq.Int64Array)(&item.Milestones),
 		&item.CreatedAt,
 		&item.UpdatedAt,
 		&item.DeletedAt)
+	item.ChallengeInfo.Id = item.ChallengeInfoId
+	item.ChallengeInfo.Period.Data = periodDataFromDB(periodData)
 
 	if err == nil && categoryId != nil && categoryName != nil {
 		category := &domain.DBCCategory{
@@ -127,8 +196,25 @@ func (r *DBCUserChallengesRepo) UserFetchByName(userId int64, name string) (*dom
     			c.id,
     			ci.category_id, 
     			cat.name as category_name,
+    			c.challenge_id,
     			ci.is_auto_track,
+    			ci.period_type,
+    			ci.period_data,
+    			ci.edit_window,
+    			ci.start_date,
+    			ci.end_date,
+    			ci.duration_days,
     			ci."desc", 
+    			c.start_date,
+    			c.end_date,
+    			c.status,
+    			c.finished_at,
+    			c.position,
+    			c.pinned,
+    			c.best_series,
+    			c.best_series_start,
+    			c.best_series_end,
+    			c.milestones,
     			c.created_at, 
     			c.updated_at,
     			c.deleted_at
@@ -137,17 +223,48 @@ func (r *DBCUserChallengesRepo) UserFetchByName(userId int64, name string) (*dom
 					left join dbc_challenge_categories cat on ci.category_id = cat.id
 			where c.user_id=$1 and ci.name=$2
 			limit 1`
+
+	var categoryId *int64
+	var categoryName *string
+	var periodData pq.Int64Array
+
 	err := r.db.QueryRow(query, userId, name).Scan(
 		&item.Id,
-		&item.ChallengeInfo.Category.Id,
-		&item.ChallengeInfo.Category.Name,
+		&categoryId,
+		&categoryName,
+		&item.ChallengeInfoId,
 		&item.ChallengeInfo.IsAutoTrack,
+		&item.ChallengeInfo.Period.Type,
+		&periodData,
+		&item.ChallengeInfo.EditWindow,
+		&item.ChallengeInfo.StartDate,
+		&item.ChallengeInfo.EndDate,
+		&item.ChallengeInfo.DurationDays,
 		&item.ChallengeInfo.Desc,
+		&item.StartDate,
+		&item.EndDate,
+		&item.Status,
+		&item.FinishedAt,
+		&item.Position,
+		&item.Pinned,
+		&item.BestSeries,
+		&item.BestSeriesStart,
+		&item.BestSeriesEnd,
+		(*pq.Int64Array)(&item.Milestones),
 		&item.CreatedAt,
 		&item.UpdatedAt,
 		&item.DeletedAt)
 	switch err {
 	case nil:
+		item.ChallengeInfo.Id = item.ChallengeInfoId
+		item.ChallengeInfo.Period.Data = periodDataFromDB(periodData)
+		if categoryId != nil && categoryName != nil {
+			item.ChallengeInfo.CategoryId = categoryId
+			item.ChallengeInfo.Category.Id = *categoryId
+			item.ChallengeInfo.Category.Name = *categoryName
+		} else {
+			item.ChallengeInfo.Category = nil
+		}
 		return item, nil
 	case sql.ErrNoRows:
 		return nil, nil
@@ -156,19 +273,25 @@ func (r *DBCUserChallengesRepo) UserFetchByName(userId int64, name string) (*dom
 	}
 }
 
-func (r *DBCUserChallengesRepo) Insert(item *domain.DBCUserChallenge) error {
+func (r *DBCUserChallengesRepo) Insert(ctx context.Context, item *domain.DBCUserChallenge) error {
 
-	doItem := &do.DBCChallengesUsers{
-		UserID:      item.UserId,
-		ChallengeID: item.ChallengeInfo.Id,
-		LastSeries:  0,
+	if item.Status == "" {
+		item.Status = domain.ChallengeStatusActive
 	}
-	err := r.gormDB.Table("dbc_challenges_users").Create(doItem).Error
+
+	query := `insert into dbc_challenges_users (user_id, challenge_id, start_date, end_date, status)
+				values ($1, $2, $3, $4, $5)
+				returning id`
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.UserId,
+		item.ChallengeInfo.Id,
+		item.StartDate,
+		item.EndDate,
+		item.Status).Scan(&item.Id)
 	if err != nil {
 		return err
 	}
-
-	item.Id = int64(doItem.ID)
 	return nil
 }
 
@@ -185,10 +308,48 @@ func (r *DBCUserChallengesRepo) Update(item *domain.DBCUserChallenge) error {
 	return nil
 }
 
-func (r *DBCUserChallengesRepo) Remove(id int64) error {
-	query := `UPDATE dbc_challenges 
-				SET deleted_at=now()
+func (r *DBCUserChallengesRepo) UpdateProgress(ctx context.Context, item *domain.DBCUserChallenge) error {
+	milestones := pq.Int64Array{}
+	if item.Milestones != nil {
+		milestones = item.Milestones
+	}
+
+	query := `UPDATE dbc_challenges_users 
+				SET last_series=$2, 
+				    best_series=$3, 
+				    best_series_start=$4, 
+				    best_series_end=$5, 
+				    milestones=$6, 
+				    updated_at=now()
+				WHERE id=$1`
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query,
+		item.Id,
+		item.LastSeries,
+		item.BestSeries,
+		item.BestSeriesStart,
+		item.BestSeriesEnd,
+		milestones)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCUserChallengesRepo) Finish(ctx context.Context, id int64, status string) error {
+	query := `UPDATE dbc_challenges_users 
+				SET status=$2, finished_at=now(), updated_at=now()
 				WHERE id=$1`
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id, status)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCUserChallengesRepo) Remove(id int64) error {
+	query := `UPDATE dbc_challenges_users 
+				SET deleted_at=now(), updated_at=now()
+				WHERE id=$1 and deleted_at is null`
 	_, err := r.db.Exec(query, id)
 	if err != nil {
 		return err
@@ -196,6 +357,63 @@ func (r *DBCUserChallengesRepo) Remove(id int64) error {
 	return nil
 }
 
+func (r *DBCUserChallengesRepo) Restore(ctx context.Context, id int64) error {
+	query := `UPDATE dbc_challenges_users 
+				SET deleted_at=null, updated_at=now()
+				WHERE id=$1`
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCUserChallengesRepo) Reorder(ctx context.Context, userId int64, ids []int64) error {
+	query := `UPDATE dbc_challenges_users c
+				SET position=x.ord, updated_at=now()
+				FROM unnest($2::bigint[]) WITH ORDINALITY AS x(id, ord)
+				WHERE c.id=x.id and c.user_id=$1 and c.deleted_at is null`
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, userId, pq.Int64Array(ids))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCUserChallengesRepo) SetPinned(ctx context.Context, id int64, pinned bool) error {
+	query := `UPDATE dbc_challenges_users 
+				SET pinned=$2, updated_at=now()
+				WHERE id=$1`
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id, pinned)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *DBCUserChallengesRepo) Delete(ctx context.Context, id int64) error {
+	// Треки, история и правила сигналов удаляются каскадно
+	query := `DELETE FROM dbc_challenges_users WHERE id=$1`
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Активные (не завершенные и не архивные) челленджи пользователя
+func (r *DBCUserChallengesRepo) UserCountActive(ctx context.Context, userId int64) (int64, error) {
+	query := `select count(*) from dbc_challenges_users 
+				where user_id=$1 and status=$2 and deleted_at is null`
+
+	var count int64
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, userId, domain.ChallengeStatusActive).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *DBCUserChallengesRepo) UserExistsByChallengeId(userId, challengeId int64) (bool, error) {
 	query := `select count(id) from dbc_challenges_users 
                  where user_id = $1 and challenge_id = $2`
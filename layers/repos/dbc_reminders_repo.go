@@ -0,0 +1,171 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+type DBCRemindersRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewDBCRemindersRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *DBCRemindersRepo {
+	return &DBCRemindersRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+func (r *DBCRemindersRepo) Insert(ctx context.Context, item *domain.DBCReminder) error {
+	query := `insert into dbc_reminders (user_id, challenge_user_id, minute, timezone, next_fire_at)
+				values ($1, $2, $3, $4, $5)
+				returning id, created_at`
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.UserId,
+		item.ChallengeUserId,
+		item.Minute,
+		item.Timezone,
+		item.NextFireAt.UTC()).Scan(&item.Id, &item.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	return nil
+}
+
+func (r *DBCRemindersRepo) FetchById(ctx context.Context, id int64) (*domain.DBCReminder, error) {
+	query := `select id, user_id, challenge_user_id, minute, timezone, next_fire_at, created_at
+				from dbc_reminders
+				where id=$1`
+
+	item := &domain.DBCReminder{}
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&item.Id,
+		&item.UserId,
+		&item.ChallengeUserId,
+		&item.Minute,
+		&item.Timezone,
+		&item.NextFireAt,
+		&item.CreatedAt)
+	switch err {
+	case nil:
+		return item, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "FetchById")
+	}
+}
+
+func (r *DBCRemindersRepo) Remove(ctx context.Context, id int64) error {
+	query := `delete from dbc_reminders where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "Remove")
+	}
+	return nil
+}
+
+func (r *DBCRemindersRepo) ChallengeFetchAll(ctx context.Context, challengeUserId int64) ([]*domain.DBCReminder, error) {
+	query := `select id, user_id, challenge_user_id, minute, timezone, next_fire_at, created_at
+				from dbc_reminders
+				where challenge_user_id=$1
+				order by minute`
+
+	return r.fetch(ctx, query, challengeUserId)
+}
+
+func (r *DBCRemindersRepo) UserFetchAll(ctx context.Context, userId int64) ([]*domain.DBCReminder, error) {
+	query := `select id, user_id, challenge_user_id, minute, timezone, next_fire_at, created_at
+				from dbc_reminders
+				where user_id=$1
+				order by id`
+
+	return r.fetch(ctx, query, userId)
+}
+
+func (r *DBCRemindersRepo) FetchDue(ctx context.Context, now time.Time, afterId, limit int64) ([]*domain.DBCReminder, error) {
+	query := `select id, user_id, challenge_user_id, minute, timezone, next_fire_at, created_at
+				from dbc_reminders
+				where next_fire_at <= $1 and id > $2
+				order by id
+				limit $3`
+
+	return r.fetch(ctx, query, now.UTC(), afterId, limit)
+}
+
+func (r *DBCRemindersRepo) SetNextFireAt(ctx context.Context, id int64, at time.Time) error {
+	query := `update dbc_reminders set next_fire_at=$2 where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id, at.UTC())
+	if err != nil {
+		return errors.Wrap(err, "SetNextFireAt")
+	}
+	return nil
+}
+
+func (r *DBCRemindersRepo) InsertSendIfNotExists(ctx context.Context, reminderId int64, date time.Time) (bool, error) {
+	query := `insert into dbc_reminder_sends (reminder_id, "date")
+				values ($1, $2)
+				on conflict do nothing`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, reminderId, date.Format("2006-01-02"))
+	if err != nil {
+		return false, errors.Wrap(err, "InsertSendIfNotExists")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "RowsAffected")
+	}
+	return affected > 0, nil
+}
+
+func (r *DBCRemindersRepo) DeleteSendsBefore(ctx context.Context, date time.Time) (int64, error) {
+	query := `delete from dbc_reminder_sends where "date" < $1`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, date.Format("2006-01-02"))
+	if err != nil {
+		return 0, errors.Wrap(err, "DeleteSendsBefore")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "RowsAffected")
+	}
+	return affected, nil
+}
+
+func (r *DBCRemindersRepo) fetch(ctx context.Context, query string, args ...interface{}) ([]*domain.DBCReminder, error) {
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCReminder
+	for rows.Next() {
+		item := &domain.DBCReminder{}
+		err := rows.Scan(
+			&item.Id,
+			&item.UserId,
+			&item.ChallengeUserId,
+			&item.Minute,
+			&item.Timezone,
+			&item.NextFireAt,
+			&item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
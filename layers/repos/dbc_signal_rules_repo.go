@@ -0,0 +1,186 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+type DBCSignalRulesRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewDBCSignalRulesRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *DBCSignalRulesRepo {
+	return &DBCSignalRulesRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+func (r *DBCSignalRulesRepo) Insert(ctx context.Context, item *domain.DBCSignalRule) error {
+	query := `insert into dbc_signal_rules (user_id, challenge_user_id, source, event_type, threshold)
+				values ($1, $2, $3, $4, $5)
+				returning id, created_at`
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.UserId,
+		item.ChallengeUserId,
+		item.Source,
+		item.EventType,
+		item.Threshold).Scan(&item.Id, &item.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	return nil
+}
+
+func (r *DBCSignalRulesRepo) FetchById(ctx context.Context, id int64) (*domain.DBCSignalRule, error) {
+	query := `select id, user_id, challenge_user_id, source, event_type, threshold, created_at
+				from dbc_signal_rules
+				where id=$1`
+
+	item := &domain.DBCSignalRule{}
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&item.Id,
+		&item.UserId,
+		&item.ChallengeUserId,
+		&item.Source,
+		&item.EventType,
+		&item.Threshold,
+		&item.CreatedAt)
+	switch err {
+	case nil:
+		return item, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "FetchById")
+	}
+}
+
+func (r *DBCSignalRulesRepo) Remove(ctx context.Context, id int64) error {
+	query := `delete from dbc_signal_rules where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "Remove")
+	}
+	return nil
+}
+
+func (r *DBCSignalRulesRepo) ChallengeFetchAll(ctx context.Context, challengeUserId int64) ([]*domain.DBCSignalRule, error) {
+	query := `select id, user_id, challenge_user_id, source, event_type, threshold, created_at
+				from dbc_signal_rules
+				where challenge_user_id=$1
+				order by id`
+
+	return r.fetch(ctx, query, challengeUserId)
+}
+
+func (r *DBCSignalRulesRepo) UserFetchAll(ctx context.Context, userId int64) ([]*domain.DBCSignalRule, error) {
+	query := `select id, user_id, challenge_user_id, source, event_type, threshold, created_at
+				from dbc_signal_rules
+				where user_id=$1
+				order by id`
+
+	return r.fetch(ctx, query, userId)
+}
+
+func (r *DBCSignalRulesRepo) ChallengeHasRules(ctx context.Context, challengeUserId int64) (bool, error) {
+	query := `select exists(select 1 from dbc_signal_rules where challenge_user_id=$1)`
+
+	var exists bool
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, challengeUserId).Scan(&exists)
+	if err != nil {
+		return false, errors.Wrap(err, "ChallengeHasRules")
+	}
+	return exists, nil
+}
+
+func (r *DBCSignalRulesRepo) UserFetchMatching(ctx context.Context, userId int64, source, eventType string) ([]*domain.DBCSignalRule, error) {
+	query := `select id, user_id, challenge_user_id, source, event_type, threshold, created_at
+				from dbc_signal_rules
+				where user_id=$1 and source=$2 and event_type=$3
+				order by id`
+
+	return r.fetch(ctx, query, userId, source, eventType)
+}
+
+func (r *DBCSignalRulesRepo) AddDayValue(ctx context.Context, ruleId int64, date time.Time, value float64) (float64, error) {
+	query := `insert into dbc_signal_rule_days (rule_id, "date", value)
+				values ($1, $2, $3)
+				on conflict (rule_id, "date") do update
+					set value=dbc_signal_rule_days.value + excluded.value, updated_at=now()
+				returning value`
+
+	var total float64
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, ruleId, date.Format("2006-01-02"), value).Scan(&total)
+	if err != nil {
+		return 0, errors.Wrap(err, "AddDayValue")
+	}
+	return total, nil
+}
+
+func (r *DBCSignalRulesRepo) InsertEventIfNotExists(ctx context.Context, source, eventId string) (bool, error) {
+	query := `insert into dbc_signal_events (source, event_id)
+				values ($1, $2)
+				on conflict do nothing`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, source, eventId)
+	if err != nil {
+		return false, errors.Wrap(err, "InsertEventIfNotExists")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "RowsAffected")
+	}
+	return affected > 0, nil
+}
+
+func (r *DBCSignalRulesRepo) DeleteEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `delete from dbc_signal_events where created_at < $1`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, errors.Wrap(err, "DeleteEventsBefore")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "RowsAffected")
+	}
+	return affected, nil
+}
+
+func (r *DBCSignalRulesRepo) fetch(ctx context.Context, query string, args ...interface{}) ([]*domain.DBCSignalRule, error) {
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCSignalRule
+	for rows.Next() {
+		item := &domain.DBCSignalRule{}
+		err := rows.Scan(
+			&item.Id,
+			&item.UserId,
+			&item.ChallengeUserId,
+			&item.Source,
+			&item.EventType,
+			&item.Threshold,
+			&item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
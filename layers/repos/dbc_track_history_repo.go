@@ -0,0 +1,146 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"strings"
+)
+
+type DBCTrackHistoryRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewDBCTrackHistoryRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *DBCTrackHistoryRepo {
+	return &DBCTrackHistoryRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+func (r *DBCTrackHistoryRepo) InsertBulk(ctx context.Context, items []*domain.DBCTrackHistory) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	var values []string
+	var args []interface{}
+	for _, item := range items {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+		args = append(args,
+			item.UserId,
+			item.ChallengeUserId,
+			item.Date.Format("2006-01-02"),
+			item.OldDone,
+			item.NewDone,
+			item.Source)
+	}
+
+	query := `insert into dbc_challenge_track_history (user_id, challenge_user_id, "date", old_done, new_done, source)
+				values ` + strings.Join(values, ",")
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "InsertBulk")
+	}
+	return nil
+}
+
+func (r *DBCTrackHistoryRepo) SetUndone(ctx context.Context, id int64) error {
+	query := `update dbc_challenge_track_history set undone_at=now() where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "SetUndone")
+	}
+	return nil
+}
+
+func (r *DBCTrackHistoryRepo) ChallengeFetchLastNotUndone(ctx context.Context, challengeUserId int64, source string) (*domain.DBCTrackHistory, error) {
+	query := `select
+    				id,
+    				user_id,
+    				challenge_user_id,
+    				"date",
+    				old_done,
+    				new_done,
+    				source,
+    				undone_at,
+    				created_at
+				from dbc_challenge_track_history
+				where challenge_user_id=$1 and source=$2 and undone_at is null
+				order by id desc
+				limit 1`
+
+	item := &domain.DBCTrackHistory{}
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, challengeUserId, source).Scan(
+		&item.Id,
+		&item.UserId,
+		&item.ChallengeUserId,
+		&item.Date,
+		&item.OldDone,
+		&item.NewDone,
+		&item.Source,
+		&item.UndoneAt,
+		&item.CreatedAt)
+	switch err {
+	case nil:
+		return item, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "ChallengeFetchLastNotUndone")
+	}
+}
+
+func (r *DBCTrackHistoryRepo) ChallengeFetch(ctx context.Context, challengeUserId int64, limit, offset int64) ([]*domain.DBCTrackHistory, error) {
+	query := `select
+    				id,
+    				user_id,
+    				challenge_user_id,
+    				"date",
+    				old_done,
+    				new_done,
+    				source,
+    				undone_at,
+    				created_at
+				from dbc_challenge_track_history
+				where challenge_user_id=$1
+				order by id desc
+				limit $2 offset $3`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, challengeUserId, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeFetch")
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCTrackHistory
+	for rows.Next() {
+		item := &domain.DBCTrackHistory{}
+		err := rows.Scan(
+			&item.Id,
+			&item.UserId,
+			&item.ChallengeUserId,
+			&item.Date,
+			&item.OldDone,
+			&item.NewDone,
+			&item.Source,
+			&item.UndoneAt,
+			&item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
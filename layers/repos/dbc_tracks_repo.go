@@ -151,17 +151,18 @@ func (r *DBCTracksRepo) ChallengeFetchByDates(challengeUserId int64, list []time
 	})
 
 	query := fmt.Sprintf(`
-		select  st.date,
+		select  coalesce(st.id, 0),
+				st.date,
 				case
 				   when st.done is null then false
 				   else st.done
 				end as done
-					from (select s.date as date, t.done as done
+					from (select t.id as id, s.date as date, t.done as done
       						from dbc_challenge_tracks t
                					right join (select date
                            			from (values %s) s(date)) s
-                          			on s.date = t.date and challenge_id = $1
-							order by t.date asc) st`, strings.Join(dateStrings, ","))
+                          			on s.date = t.date and challenge_user_id = $1
+							order by s.date asc) st`, strings.Join(dateStrings, ","))
 
 	rows, err := r.db.Query(query, challengeUserId)
 	if err != nil {
@@ -171,7 +172,7 @@ func (r *DBCTracksRepo) ChallengeFetchByDates(challengeUserId int64, list []time
 	var result []*domain.DBCTrack
 	for rows.Next() {
 		item := &domain.DBCTrack{}
-		err := rows.Scan(&item.Date, &item.Done)
+		err := rows.Scan(&item.Id, &item.Date, &item.Done)
 		if err != nil {
 			return nil, err
 		}
@@ -236,7 +237,9 @@ func (r *DBCTracksRepo) ChallengeFetchBetween(ctx context.Context, challengeUser
     				done, 
        				last_series, 
        				score,
-       				score_daily from dbc_challenge_tracks 
+       				score_daily,
+       				note,
+       				mood from dbc_challenge_tracks 
             		where challenge_user_id=$1 and 
             		      "date" >= $2 and "date" <= $3
             		order by "date"`
@@ -259,7 +262,9 @@ func (r *DBCTracksRepo) ChallengeFetchBetween(ctx context.Context, challengeUser
 			&item.Done,
 			&item.LastSeries,
 			&item.Score,
-			&item.ScoreDaily)
+			&item.ScoreDaily,
+			&item.Note,
+			&item.Mood)
 		if err != nil {
 			return nil, err
 		}
@@ -269,6 +274,36 @@ func (r *DBCTracksRepo) ChallengeFetchBetween(ctx context.Context, challengeUser
 	return result, nil
 }
 
+// Только даты выполненных треков (index only scan по dbc_challenge_tracks_challenge_user_date_idx)
+func (r *DBCTracksRepo) ChallengeFetchDoneBetween(ctx context.Context, challengeUserId int64, from, to time.Time) ([]time.Time, error) {
+	from = tools.RoundDateTimeToDay(from.UTC())
+	to = tools.RoundDateTimeToDay(to.UTC())
+
+	query := `select "date" from dbc_challenge_tracks 
+            		where challenge_user_id=$1 and 
+            		      "date" >= $2 and "date" <= $3 and
+            		      done
+            		order by "date"`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, challengeUserId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []time.Time
+	for rows.Next() {
+		var date time.Time
+		err := rows.Scan(&date)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, date)
+	}
+
+	return result, nil
+}
+
 func (r *DBCTracksRepo) NotProcessedChallengeFetchAllBefore(ctx context.Context, challengeUserId int64, date time.Time) ([]*domain.DBCTrack, error) {
 	date = tools.RoundDateTimeToDay(date.UTC())
 
@@ -374,7 +409,7 @@ func (r *DBCTracksRepo) InsertOrUpdateBulk(ctx context.Context, tracks []*domain
 			   unnest(array[%s]),
 			   unnest(array[%s]),
 				unnest(array[%s])
-		on conflict (challenge_id, "date") do
+		on conflict (challenge_user_id, "date") do
 			update set
 					   "date" = excluded.date,
 					   score = excluded.score,
@@ -399,3 +434,262 @@ func (r *DBCTracksRepo) InsertOrUpdateBulk(ctx context.Context, tracks []*domain
 	return nil
 
 }
+
+// Все треки пользователя (для выгрузки данных)
+func (r *DBCTracksRepo) UserFetchAll(ctx context.Context, userId int64) ([]*domain.DBCTrack, error) {
+	query := `select 
+    				id,
+    				challenge_id,
+    				challenge_user_id,
+    				"date",
+    				done, 
+       				last_series, 
+       				score,
+       				score_daily,
+       				note,
+       				mood from dbc_challenge_tracks 
+            		where user_id=$1
+            		order by challenge_user_id, "date"`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCTrack
+	for rows.Next() {
+		item := &domain.DBCTrack{
+			UserId: userId,
+		}
+		err := rows.Scan(
+			&item.Id,
+			&item.ChallengeId,
+			&item.ChallengeUserId,
+			&item.Date,
+			&item.Done,
+			&item.LastSeries,
+			&item.Score,
+			&item.ScoreDaily,
+			&item.Note,
+			&item.Mood)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// Треки активных челленджей пользователя за период (для статистики категорий)
+func (r *DBCTracksRepo) UserFetchBetween(ctx context.Context, userId int64, from, to time.Time) ([]*domain.DBCTrack, error) {
+	from = tools.RoundDateTimeToDay(from.UTC())
+	to = tools.RoundDateTimeToDay(to.UTC())
+
+	query := `select 
+    				t.id,
+    				t.challenge_id,
+    				t.challenge_user_id,
+    				t."date",
+    				t.done, 
+       				t.last_series, 
+       				t.score,
+       				t.score_daily
+       			from dbc_challenge_tracks t
+       				join dbc_challenges_users cu on cu.id = t.challenge_user_id
+            		where t.user_id=$1 and 
+            		      cu.deleted_at is null and
+            		      t."date" >= $2 and t."date" <= $3
+            		order by t.challenge_user_id, t."date"`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCTrack
+	for rows.Next() {
+		item := &domain.DBCTrack{
+			UserId: userId,
+		}
+		err := rows.Scan(
+			&item.Id,
+			&item.ChallengeId,
+			&item.ChallengeUserId,
+			&item.Date,
+			&item.Done,
+			&item.LastSeries,
+			&item.Score,
+			&item.ScoreDaily)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func (r *DBCTracksRepo) UserCount(ctx context.Context, userId int64) (int64, error) {
+	query := `select count(*) from dbc_challenge_tracks where user_id=$1`
+
+	var count int64
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, userId).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "UserCount")
+	}
+	return count, nil
+}
+
+func (r *DBCTracksRepo) ChallengeUpdateNote(ctx context.Context, challengeUserId int64, date time.Time, note *string, mood *int64) (bool, error) {
+	date = tools.RoundDateTimeToDay(date.UTC())
+
+	query := `update dbc_challenge_tracks
+				set note = case when $3::varchar is null then note when $3::varchar = '' then null else $3::varchar end,
+				    mood = case when $4::smallint is null then mood when $4::smallint = 0 then null else $4::smallint end,
+				    updated_at = now()
+				where challenge_user_id=$1 and "date"=$2`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, challengeUserId, date, note, mood)
+	if err != nil {
+		return false, errors.Wrap(err, "ChallengeUpdateNote")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "RowsAffected")
+	}
+	return affected > 0, nil
+}
+
+// Поиск по заметкам пользователя во всех челленджах (пустой search - все заметки, новые сверху)
+func (r *DBCTracksRepo) UserSearchNotes(ctx context.Context, userId int64, search string, limit, offset int64) ([]*domain.DBCTrackNote, error) {
+	query := `select
+    				t.challenge_user_id,
+    				coalesce(c.name, ''),
+    				t."date",
+    				t.done,
+    				t.note,
+    				t.mood
+				from dbc_challenge_tracks t
+					left join dbc_challenges_users cu on cu.id = t.challenge_user_id
+					left join dbc_challenges c on c.id = cu.challenge_id
+				where t.user_id=$1 and
+				      t.note is not null and
+				      t.note ilike '%' || $2 || '%'
+				order by t."date" desc, t.id desc
+				limit $3 offset $4`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, userId, r.escapeLike(search), limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserSearchNotes")
+	}
+	defer rows.Close()
+
+	var result []*domain.DBCTrackNote
+	for rows.Next() {
+		item := &domain.DBCTrackNote{}
+		err := rows.Scan(
+			&item.ChallengeUserId,
+			&item.ChallengeName,
+			&item.Date,
+			&item.Done,
+			&item.Note,
+			&item.Mood)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func (r *DBCTracksRepo) ChallengeStats(ctx context.Context, challengeUserId int64, since time.Time) (*domain.DBCTrackStats, error) {
+	since = tools.RoundDateTimeToDay(since.UTC())
+	db := r.getter.DefaultTrOrDB(ctx, r.db)
+
+	// Серии - острова подряд идущих выполненных треков (gaps and islands).
+	// Текущая серия - последний остров, если после него нет невыполненных треков
+	query := `with islands as (
+					select done,
+						   "date",
+						   row_number() over (order by "date") -
+						   row_number() over (partition by done order by "date") as grp
+						from dbc_challenge_tracks
+						where challenge_user_id=$1
+				), series as (
+					select count(*) as len, max("date") as last_date
+						from islands
+						where done
+						group by grp
+				)
+				select 
+					coalesce((select max(len) from series), 0),
+					coalesce((select len from series 
+							  where last_date > coalesce((select max("date") from dbc_challenge_tracks 
+														  where challenge_user_id=$1 and not done), '-infinity'::date)), 0),
+					(select count(*) from dbc_challenge_tracks where challenge_user_id=$1 and done),
+					(select coalesce(sum(score_daily), 0) from dbc_challenge_tracks where challenge_user_id=$1),
+					(select min("date") from dbc_challenge_tracks where challenge_user_id=$1)`
+
+	stats := &domain.DBCTrackStats{
+		DoneByWeekday: make(map[int]int64),
+	}
+	err := db.QueryRowContext(ctx, query, challengeUserId).Scan(
+		&stats.BestSeries,
+		&stats.CurrentSeries,
+		&stats.TotalDone,
+		&stats.Score,
+		&stats.FirstDate)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeStats")
+	}
+
+	query = `select extract(isodow from "date")::int, count(*)
+				from dbc_challenge_tracks
+				where challenge_user_id=$1 and done
+				group by 1`
+
+	rows, err := db.QueryContext(ctx, query, challengeUserId)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeStats weekdays")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var weekday int
+		var count int64
+		err := rows.Scan(&weekday, &count)
+		if err != nil {
+			return nil, err
+		}
+		stats.DoneByWeekday[weekday] = count
+	}
+
+	query = `select "date"
+				from dbc_challenge_tracks
+				where challenge_user_id=$1 and done and "date" >= $2
+				order by "date"`
+
+	dateRows, err := db.QueryContext(ctx, query, challengeUserId, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeStats dates")
+	}
+	defer dateRows.Close()
+	for dateRows.Next() {
+		var date time.Time
+		err := dateRows.Scan(&date)
+		if err != nil {
+			return nil, err
+		}
+		stats.DoneDates = append(stats.DoneDates, date)
+	}
+
+	return stats, nil
+}
+
+func (r *DBCTracksRepo) escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
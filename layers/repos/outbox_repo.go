@@ -0,0 +1,158 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+// Ключ pg_try_advisory_xact_lock для OutboxRelayJob
+const outboxRelayLockKey = 48100
+
+type OutboxRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewOutboxRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *OutboxRepo {
+	return &OutboxRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+func (r *OutboxRepo) Insert(ctx context.Context, item *domain.OutboxEvent) error {
+	query := `insert into outbox_events (event_type, event_key, payload)
+				values ($1, $2, $3)
+				returning id, status, next_attempt_at, created_at`
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.EventType,
+		item.Key,
+		item.Payload).Scan(&item.Id, &item.Status, &item.NextAttemptAt, &item.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	return nil
+}
+
+func (r *OutboxRepo) TryLock(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, `select pg_try_advisory_xact_lock($1)`, outboxRelayLockKey).Scan(&locked)
+	if err != nil {
+		return false, errors.Wrap(err, "TryLock")
+	}
+	return locked, nil
+}
+
+// Событие, ожидающее повтора (или захваченное другим запуском), блокирует следующие события того же ключа -
+// порядок отправки по ключу сохраняется, остальные ключи не ждут.
+// Выбранные события захватываются на lease: до его истечения они не выбираются повторно
+func (r *OutboxRepo) ClaimDue(ctx context.Context, limit int64, lease time.Duration) ([]*domain.OutboxEvent, error) {
+	query := `with due as (
+				select e.id
+				from outbox_events e
+				where e.status = $1
+				  and e.next_attempt_at <= now()
+				  and not exists (select 1 from outbox_events w
+				                  where w.event_key = e.event_key
+				                    and w.status = $1
+				                    and w.id < e.id
+				                    and w.next_attempt_at > now())
+				order by e.id
+				limit $2
+				), claimed as (
+				update outbox_events o
+				set next_attempt_at = now() + $3 * interval '1 second'
+				from due
+				where o.id = due.id
+				returning o.id, o.event_type, o.event_key, o.payload, o.status, o.attempts, o.last_error,
+				          o.next_attempt_at, o.created_at, o.published_at
+				)
+				select * from claimed order by id`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, domain.OutboxStatusPending, limit, int64(lease.Seconds()))
+	if err != nil {
+		return nil, errors.Wrap(err, "ClaimDue")
+	}
+	defer rows.Close()
+
+	var result []*domain.OutboxEvent
+	for rows.Next() {
+		item := &domain.OutboxEvent{}
+		err := rows.Scan(
+			&item.Id,
+			&item.EventType,
+			&item.Key,
+			&item.Payload,
+			&item.Status,
+			&item.Attempts,
+			&item.LastError,
+			&item.NextAttemptAt,
+			&item.CreatedAt,
+			&item.PublishedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "Scan")
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func (r *OutboxRepo) MarkPublished(ctx context.Context, id int64) error {
+	query := `update outbox_events
+				set status=$2, published_at=now(), attempts=attempts+1, last_error=null
+				where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id, domain.OutboxStatusPublished)
+	if err != nil {
+		return errors.Wrap(err, "MarkPublished")
+	}
+	return nil
+}
+
+func (r *OutboxRepo) MarkRetry(ctx context.Context, id int64, lastError string, delay time.Duration) error {
+	query := `update outbox_events
+				set attempts=attempts+1, last_error=$2, next_attempt_at=now() + $3 * interval '1 second'
+				where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id, lastError, int64(delay.Seconds()))
+	if err != nil {
+		return errors.Wrap(err, "MarkRetry")
+	}
+	return nil
+}
+
+func (r *OutboxRepo) MarkFailed(ctx context.Context, id int64, lastError string) error {
+	query := `update outbox_events
+				set status=$2, attempts=attempts+1, last_error=$3
+				where id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, id, domain.OutboxStatusFailed, lastError)
+	if err != nil {
+		return errors.Wrap(err, "MarkFailed")
+	}
+	return nil
+}
+
+func (r *OutboxRepo) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `delete from outbox_events
+				where status=$1 and published_at < $2`
+
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, domain.OutboxStatusPublished, before.UTC())
+	if err != nil {
+		return 0, errors.Wrap(err, "DeletePublishedBefore")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "RowsAffected")
+	}
+	return affected, nil
+}
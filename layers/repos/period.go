@@ -0,0 +1,21 @@
+package repos
+
+import "github.com/lib/pq"
+
+// period_data хранится как integer[]
+
+func periodDataToDB(data []int) pq.Int64Array {
+	arr := pq.Int64Array{}
+	for _, x := range data {
+		arr = append(arr, int64(x))
+	}
+	return arr
+}
+
+func periodDataFromDB(arr pq.Int64Array) []int {
+	var data []int
+	for _, x := range arr {
+		data = append(data, int(x))
+	}
+	return data
+}
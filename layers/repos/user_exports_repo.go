@@ -0,0 +1,134 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+)
+
+type UserExportsRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewUserExportsRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *UserExportsRepo {
+	return &UserExportsRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+func (r *UserExportsRepo) FetchById(ctx context.Context, id int64) (*domain.UserExport, error) {
+	query := `select
+    			id,
+    			user_id,
+    			format,
+    			status,
+    			file,
+    			error,
+    			created_at,
+    			updated_at
+			from user_exports
+			where id=$1
+			limit 1`
+
+	item := &domain.UserExport{}
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&item.Id,
+		&item.UserId,
+		&item.Format,
+		&item.Status,
+		&item.File,
+		&item.Error,
+		&item.CreatedAt,
+		&item.UpdatedAt)
+	switch err {
+	case nil:
+		return item, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "FetchById")
+	}
+}
+
+func (r *UserExportsRepo) FetchPending(ctx context.Context, limit int64) ([]*domain.UserExport, error) {
+	query := `select
+    			id,
+    			user_id,
+    			format,
+    			status,
+    			file,
+    			error,
+    			created_at,
+    			updated_at
+			from user_exports
+			where status=$1
+			order by id
+			limit $2
+			for update skip locked`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, domain.ExportStatusPending, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchPending")
+	}
+	defer rows.Close()
+
+	var result []*domain.UserExport
+	for rows.Next() {
+		item := &domain.UserExport{}
+		err := rows.Scan(
+			&item.Id,
+			&item.UserId,
+			&item.Format,
+			&item.Status,
+			&item.File,
+			&item.Error,
+			&item.CreatedAt,
+			&item.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func (r *UserExportsRepo) Insert(ctx context.Context, item *domain.UserExport) error {
+	query := `INSERT INTO user_exports (user_id, format, status, file, error)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id, created_at, updated_at`
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.UserId,
+		item.Format,
+		item.Status,
+		item.File,
+		item.Error).Scan(&item.Id, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	return nil
+}
+
+func (r *UserExportsRepo) Update(ctx context.Context, item *domain.UserExport) error {
+	query := `UPDATE user_exports
+				SET status=$2, file=$3, error=$4, updated_at=now()
+				WHERE id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query,
+		item.Id,
+		item.Status,
+		item.File,
+		item.Error)
+	if err != nil {
+		return errors.Wrap(err, "Update")
+	}
+	return nil
+}
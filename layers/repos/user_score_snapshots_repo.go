@@ -0,0 +1,92 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/tools"
+	"time"
+)
+
+type UserScoreSnapshotsRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewUserScoreSnapshotsRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *UserScoreSnapshotsRepo {
+	return &UserScoreSnapshotsRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+// Delta считается от предыдущего снимка пользователя (первый снимок - 0)
+func (r *UserScoreSnapshotsRepo) Upsert(ctx context.Context, item *domain.UserScoreSnapshot) error {
+	query := `insert into user_score_snapshots (user_id, "date", score, score_daily, delta, active_challenges)
+				values ($1, $2, $3, $4,
+				        $3 - coalesce((select s.score from user_score_snapshots s
+				                       where s.user_id=$1 and s."date" < $2
+				                       order by s."date" desc limit 1), $3),
+				        $5)
+				on conflict (user_id, "date") do update
+					set score=excluded.score,
+					    score_daily=excluded.score_daily,
+					    delta=excluded.delta,
+					    active_challenges=excluded.active_challenges
+				returning id, delta, created_at`
+
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.UserId,
+		item.Date.Format("2006-01-02"),
+		item.Score,
+		item.ScoreDaily,
+		item.ActiveChallenges).Scan(&item.Id, &item.Delta, &item.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "Upsert")
+	}
+	return nil
+}
+
+// granularity - domain.ScoreGranularity* (значение date_trunc)
+func (r *UserScoreSnapshotsRepo) UserFetchTimeline(ctx context.Context, userId int64, granularity string, from, to time.Time) ([]*domain.ScoreTimelinePoint, error) {
+	from = tools.RoundDateTimeToDay(from.UTC())
+	to = tools.RoundDateTimeToDay(to.UTC())
+
+	query := `select date_trunc($2, "date"::timestamp)::date as bucket,
+					 (array_agg(score order by "date" desc))[1],
+					 (array_agg(score_daily order by "date" desc))[1],
+					 sum(delta),
+					 (array_agg(active_challenges order by "date" desc))[1]
+				from user_score_snapshots
+				where user_id=$1 and "date" >= $3 and "date" <= $4
+				group by bucket
+				order by bucket`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, userId, granularity, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.ScoreTimelinePoint
+	for rows.Next() {
+		item := &domain.ScoreTimelinePoint{}
+		err := rows.Scan(
+			&item.Date,
+			&item.Score,
+			&item.ScoreDaily,
+			&item.Delta,
+			&item.ActiveChallenges)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
@@ -8,6 +8,7 @@ import (
 	"gorm.io/gorm"
 	"microservice/app/core"
 	"microservice/layers/domain"
+	"time"
 )
 
 type UsersRepo struct {
@@ -30,6 +31,7 @@ func (r *UsersRepo) FetchById(id int64) (*domain.User, error) {
 
 	query := `select 
     				score,
+    				deletion_scheduled_at,
     				created_at, 
     				updated_at, 
     				deleted_at
@@ -39,6 +41,7 @@ func (r *UsersRepo) FetchById(id int64) (*domain.User, error) {
 
 	err := r.db.QueryRow(query, id).Scan(
 		&user.Score,
+		&user.DeletionScheduledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.DeletedAt)
@@ -52,9 +55,10 @@ func (r *UsersRepo) FetchById(id int64) (*domain.User, error) {
 	}
 }
 
-func (r *UsersRepo) Exist(id int64) (bool, error) {
-	query := `select id from users where id=$1 limit 1`
-	err := r.db.QueryRow(query, id).Scan(&id)
+// В транзакции из ctx строка блокируется до ее завершения
+func (r *UsersRepo) Exist(ctx context.Context, id int64) (bool, error) {
+	query := `select id from users where id=$1 limit 1 for update`
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, id).Scan(&id)
 	switch err {
 	case nil:
 		return true, nil
@@ -96,15 +100,92 @@ func (r *UsersRepo) Update(user *domain.User) error {
 	return nil
 }
 
-func (r *UsersRepo) AddScore(ctx context.Context, userId, score int64) error {
+func (r *UsersRepo) AddScore(ctx context.Context, userId, score int64) (int64, error) {
 
 	query := `UPDATE users
 				SET score=score+$2, updated_at=now()
+				WHERE id=$1
+				RETURNING score`
+
+	var newScore int64
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, userId, score).Scan(&newScore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return newScore, nil
+}
+
+// at = nil - отмена удаления
+func (r *UsersRepo) ScheduleDeletion(ctx context.Context, userId int64, at *time.Time) error {
+	query := `UPDATE users
+				SET deletion_scheduled_at=$2, updated_at=now()
 				WHERE id=$1`
 
-	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, userId, score)
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, userId, at)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "ScheduleDeletion")
+	}
+	return nil
+}
+
+func (r *UsersRepo) FetchScheduledForDeletion(ctx context.Context, before time.Time, limit int64) ([]int64, error) {
+	query := `select id from users
+				where deletion_scheduled_at is not null and deletion_scheduled_at <= $1
+				order by deletion_scheduled_at
+				limit $2`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchScheduledForDeletion")
+	}
+	defer rows.Close()
+
+	var result []int64
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+func (r *UsersRepo) FetchIds(ctx context.Context, limit, offset int64) ([]int64, error) {
+	query := `select id from users
+				where deleted_at is null and deletion_scheduled_at is null
+				order by id
+				limit $1 offset $2`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchIds")
+	}
+	defer rows.Close()
+
+	var result []int64
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+// Приватные данные пользователя удаляются каскадно (категории, челленджи, треки, достижения, выгрузки)
+func (r *UsersRepo) HardDelete(ctx context.Context, userId int64) error {
+	query := `DELETE FROM users WHERE id=$1`
+
+	_, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, userId)
+	if err != nil {
+		return errors.Wrap(err, "HardDelete")
 	}
 	return nil
 }
@@ -0,0 +1,121 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"time"
+)
+
+type WeeklyDigestsRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewWeeklyDigestsRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *WeeklyDigestsRepo {
+	return &WeeklyDigestsRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+// Время сборки (created_at) в сравнении не участвует
+func (r *WeeklyDigestsRepo) Upsert(ctx context.Context, item *domain.WeeklyDigest) (bool, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return false, errors.Wrap(err, "Marshal")
+	}
+
+	query := `insert into dbc_weekly_digests (user_id, week_start, data, created_at)
+				values ($1, $2, $3, $4)
+				on conflict (user_id, week_start) do update
+					set data=excluded.data, updated_at=now()
+					where dbc_weekly_digests.data - 'created_at' is distinct from excluded.data - 'created_at'
+				returning id`
+
+	err = r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query,
+		item.UserId,
+		item.WeekStart.Format("2006-01-02"),
+		data,
+		item.CreatedAt).Scan(&item.Id)
+	switch err {
+	case nil:
+		return true, nil
+	case sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, errors.Wrap(err, "Upsert")
+	}
+}
+
+func (r *WeeklyDigestsRepo) UserFetchByWeek(ctx context.Context, userId int64, weekStart time.Time) (*domain.WeeklyDigest, error) {
+	query := `select id, data
+				from dbc_weekly_digests
+				where user_id=$1 and week_start=$2`
+
+	var id int64
+	var data []byte
+	err := r.getter.DefaultTrOrDB(ctx, r.db).QueryRowContext(ctx, query, userId, weekStart.Format("2006-01-02")).Scan(&id, &data)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "UserFetchByWeek")
+	}
+
+	return r.unmarshal(id, data)
+}
+
+// Изменение трека пересчитывает цепочку дальше, поэтому затронуты все недели начиная с недели трека
+func (r *WeeklyDigestsRepo) FetchAffectedSince(ctx context.Context, since time.Time, afterId, limit int64) ([]*domain.WeeklyDigest, error) {
+	query := `with changed as (select user_id, min("date") as from_date
+								from dbc_challenge_track_history
+								where created_at >= $1
+								group by user_id)
+				select d.id, d.data
+				from dbc_weekly_digests d
+					join changed c on c.user_id = d.user_id and d.week_start + 7 > c.from_date
+				where d.id > $2
+				order by d.id
+				limit $3`
+
+	rows, err := r.getter.DefaultTrOrDB(ctx, r.db).QueryContext(ctx, query, since.UTC(), afterId, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchAffectedSince")
+	}
+	defer rows.Close()
+
+	var result []*domain.WeeklyDigest
+	for rows.Next() {
+		var id int64
+		var data []byte
+		err := rows.Scan(&id, &data)
+		if err != nil {
+			return nil, errors.Wrap(err, "Scan")
+		}
+		item, err := r.unmarshal(id, data)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func (r *WeeklyDigestsRepo) unmarshal(id int64, data []byte) (*domain.WeeklyDigest, error) {
+	item := &domain.WeeklyDigest{}
+	err := json.Unmarshal(data, item)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	item.Id = id
+	return item, nil
+}
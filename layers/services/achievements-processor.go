@@ -30,6 +30,13 @@ func (s *AchievementsProcessor) HandleUserScoreChanged(ctx context.Context, user
 	return nil
 }
 
+// When challenge with end date is finished (completed or failed)
+func (s *AchievementsProcessor) HandleChallengeFinished(ctx context.Context, challenge *domain.DBCUserChallenge) error {
+	// Here should be triggers that start executing after some achievement point
+
+	return nil
+}
+
 func (s *AchievementsProcessor) HandleChallengeLastSeriesChanged(ctx context.Context, challengeId, lastSeries int64) error {
 	// Here should be triggers that start executing after some achievement point
 
@@ -5,15 +5,25 @@ import (
 	"github.com/avito-tech/go-transaction-manager/trm/manager"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
+	"github.com/spf13/viper"
 	"math"
 	"microservice/app/core"
+	"microservice/app/tracing"
 	"microservice/layers/domain"
 	"microservice/tools"
+	"strconv"
 	"time"
 )
 
-const DBC_MAX_STEP_CAN_CHANGE = 3
-const DBC_MAX_STEP_CAN_CHANGE_AUTO = 1
+// Окно редактирования по умолчанию (в точках периода)
+const defaultEditWindow = 3
+const defaultEditWindowAuto = 1
+const MaxEditWindow = 31
+
+const defaultCompletionRatio = 1.0
+
+var ErrTrackOutOfWindow = errors.New("track date is out of edit window")
+var ErrTrackOutOfRange = errors.New("track date is out of challenge dates")
 
 type DBCProcessor struct {
 	log        core.Logger
@@ -21,9 +31,12 @@ type DBCProcessor struct {
 
 	periodProc *PeriodTypeProcessor
 	gamifyProc *AchievementsProcessor
+	outbox     *OutboxWriter
 
 	challengeUserRepository domain.DBCUserChallengeRepository
 	trackRepository         domain.DBCTrackRepository
+	trackHistoryRepository  domain.DBCTrackHistoryRepository
+	signalRulesRepository   domain.DBCSignalRulesRepository
 	userRepo                domain.UsersRepository
 }
 
@@ -31,51 +44,186 @@ func NewDBCTrackProcessor(log core.Logger,
 	trxManager *manager.Manager,
 	trackProcessor *PeriodTypeProcessor,
 	gamifyProc *AchievementsProcessor,
+	outbox *OutboxWriter,
 	challengeRepository domain.DBCUserChallengeRepository,
 	trackRepository domain.DBCTrackRepository,
+	trackHistoryRepository domain.DBCTrackHistoryRepository,
+	signalRulesRepository domain.DBCSignalRulesRepository,
 	userRepo domain.UsersRepository) *DBCProcessor {
 	return &DBCProcessor{
 		log:                     log,
 		periodProc:              trackProcessor,
 		gamifyProc:              gamifyProc,
+		outbox:                  outbox,
 		challengeUserRepository: challengeRepository,
 		trackRepository:         trackRepository,
+		trackHistoryRepository:  trackHistoryRepository,
+		signalRulesRepository:   signalRulesRepository,
 		trxManager:              trxManager,
 		userRepo:                userRepo,
 	}
 }
 
-// Меняет значение трека и всей предыдущей цепочки треков
-// (НЕ ПРОВЕРЯЕТ дату на возможность трека со стороны бизнеса)
-func (s *DBCProcessor) MakeTrack(ctx context.Context, challengeUserId int64, date time.Time, value bool) (bool, error) {
+// Сколько точек периода назад можно менять треки челленджа.
+// Свое значение челленджа или значение по умолчанию из конфига
+func (s *DBCProcessor) EditWindow(challenge *domain.DBCChallengeInfo) int {
+	if challenge != nil && challenge.EditWindow != nil && *challenge.EditWindow > 0 {
+		return int(*challenge.EditWindow)
+	}
 
-	now := tools.RoundDateTimeToDay(time.Now().UTC())
+	if challenge != nil && challenge.IsAutoTrack {
+		window := viper.GetInt("tracks.edit_window_auto")
+		if window <= 0 {
+			window = defaultEditWindowAuto
+		}
+		return window
+	}
+
+	window := viper.GetInt("tracks.edit_window")
+	if window <= 0 {
+		window = defaultEditWindow
+	}
+	return window
+}
+
+// Можно ли менять трек за дату (дата внутри окна редактирования челленджа)
+func (s *DBCProcessor) IsInEditWindow(challenge *domain.DBCChallengeInfo, date time.Time) (bool, error) {
+	period := s.periodProc.ChallengePeriod(challenge)
+
+	separatorDate, err := s.getSeparatorDateDaily(period, s.EditWindow(challenge))
+	if err != nil {
+		return false, errors.Wrap(err, "getSeparatorDateDaily")
+	}
+
+	return !tools.RoundDateTimeToDay(date.UTC()).Before(separatorDate), nil
+}
+
+// Дата внутри срока челленджа (для бессрочного - всегда)
+func (s *DBCProcessor) IsInChallengeDates(challenge *domain.DBCUserChallenge, date time.Time) bool {
 	date = tools.RoundDateTimeToDay(date.UTC())
+	if challenge.StartDate != nil && date.Before(tools.RoundDateTimeToDay(challenge.StartDate.UTC())) {
+		return false
+	}
+	if challenge.EndDate != nil && date.After(tools.RoundDateTimeToDay(challenge.EndDate.UTC())) {
+		return false
+	}
+	return true
+}
 
-	// Нельзя трекать будущие даты
-	if date.After(now) {
-		return false, nil
+// Даты челленджа для пользователя, вступившего в него joinedAt.
+// Фиксированные даты челленджа или длительность от даты вступления, nil - бессрочный
+func (s *DBCProcessor) ChallengeDates(challenge *domain.DBCChallengeInfo, joinedAt time.Time) (*time.Time, *time.Time) {
+	if challenge.StartDate == nil && challenge.EndDate == nil && challenge.DurationDays == nil {
+		return nil, nil
 	}
 
-	// Получаем челлендж
+	start := tools.RoundDateTimeToDay(joinedAt.UTC())
+	if challenge.StartDate != nil {
+		start = tools.RoundDateTimeToDay(challenge.StartDate.UTC())
+	}
+
+	var end *time.Time
+	if challenge.EndDate != nil {
+		x := tools.RoundDateTimeToDay(challenge.EndDate.UTC())
+		end = &x
+	} else if challenge.DurationDays != nil {
+		x := start.AddDate(0, 0, int(*challenge.DurationDays)-1)
+		end = &x
+	}
+
+	return &start, end
+}
+
+// Меняет значение трека и всей предыдущей цепочки треков.
+// Дата должна быть внутри срока челленджа (ErrTrackOutOfRange)
+// и окна редактирования челленджа (ErrTrackOutOfWindow)
+func (s *DBCProcessor) MakeTrack(ctx context.Context, challengeUserId int64, date time.Time, value bool, source string) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.MakeTrack")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	userChallenge, err := s.challengeUserRepository.FetchById(ctx, challengeUserId)
 	if err != nil {
 		return false, errors.Wrap(err, "FetchById")
 	}
-	if userChallenge == nil {
+	// Архивный челлендж не трекается
+	if userChallenge == nil || userChallenge.DeletedAt != nil {
 		return false, nil
 	}
 
-	// ToDo: Получаем у челленджа период
-	period := domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+	if !s.IsInChallengeDates(userChallenge, date) {
+		return false, ErrTrackOutOfRange
+	}
 
-	// Проверяем, что текущий день является точкой периода и может быть трекнут
-	match, err := s.periodProc.IsMatch(date, period)
+	inWindow, err := s.IsInEditWindow(userChallenge.ChallengeInfo, date)
 	if err != nil {
-		return false, errors.Wrap(err, "IsMatch")
+		return false, errors.Wrap(err, "IsInEditWindow")
 	}
-	if !match {
-		return false, errors.Wrap(err, "Incorrect date for period")
+	if !inWindow {
+		return false, ErrTrackOutOfWindow
+	}
+
+	return s.MakeTracks(ctx, challengeUserId, map[time.Time]bool{date: value}, source)
+}
+
+// Меняет значения сразу нескольких треков челленджа.
+// Цепочка пересчитывается один раз - начиная с самой ранней даты из values.
+// Каждое изменение done пишется в историю треков с источником source
+// (НЕ ПРОВЕРЯЕТ даты на возможность трека со стороны бизнеса)
+func (s *DBCProcessor) MakeTracks(ctx context.Context, challengeUserId int64, values map[time.Time]bool, source string) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.MakeTracks")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if len(values) == 0 {
+		return true, nil
+	}
+
+	now := tools.RoundDateTimeToDay(time.Now().UTC())
+
+	// Ключи - даты без времени
+	dayValues := make(map[time.Time]bool, len(values))
+	var date time.Time
+	for d, value := range values {
+		d = tools.RoundDateTimeToDay(d.UTC())
+
+		// Нельзя трекать будущие даты
+		if d.After(now) {
+			return false, nil
+		}
+
+		dayValues[d] = value
+		if date.IsZero() || d.Before(date) {
+			date = d
+		}
+	}
+
+	// Получаем челлендж
+	userChallenge, err := s.challengeUserRepository.FetchById(ctx, challengeUserId)
+	if err != nil {
+		return false, errors.Wrap(err, "FetchById")
+	}
+	if userChallenge == nil || userChallenge.DeletedAt != nil {
+		return false, nil
+	}
+
+	period := s.periodProc.ChallengePeriod(userChallenge.ChallengeInfo)
+
+	// Проверяем, что все дни являются точками периода и могут быть трекнуты
+	for d := range dayValues {
+		if !s.IsInChallengeDates(userChallenge, d) {
+			return false, nil
+		}
+
+		match, err := s.periodProc.IsMatch(d, period)
+		if err != nil {
+			return false, errors.Wrap(err, "IsMatch")
+		}
+		if !match {
+			return false, nil
+		}
 	}
 
 	//
@@ -106,7 +254,16 @@ func (s *DBCProcessor) MakeTrack(ctx context.Context, challengeUserId int64, dat
 
 	// Получаем окно дат, которые нужно перерассчитать (массив дат будет отсортированный)
 
-	absentDates, err := s.periodProc.AbsentWindow(dateSince, now.Add(24*time.Hour), period)
+	// Для челленджа на срок - не дальше даты окончания
+	dateTo := now.Add(24 * time.Hour)
+	if userChallenge.EndDate != nil {
+		endNext := tools.RoundDateTimeToDay(userChallenge.EndDate.UTC()).Add(24 * time.Hour)
+		if endNext.Before(dateTo) {
+			dateTo = endNext
+		}
+	}
+
+	absentDates, err := s.periodProc.AbsentWindow(dateSince, dateTo, period)
 	if err != nil {
 		return false, errors.Wrap(err, "AbsentWindow")
 	}
@@ -117,10 +274,27 @@ func (s *DBCProcessor) MakeTrack(ctx context.Context, challengeUserId int64, dat
 		return false, errors.Wrap(err, "ChallengeFetchByDates")
 	}
 
+	var history []*domain.DBCTrackHistory
 	for _, track := range tracks {
 		currentValue := track.Done
-		if track.Date.Equal(date) {
+		if value, ok := dayValues[tools.RoundDateTimeToDay(track.Date)]; ok {
 			currentValue = value
+
+			// Отсутствующий трек (Id = 0) или изменение значения
+			if track.Id == 0 || track.Done != value {
+				item := &domain.DBCTrackHistory{
+					UserId:          userChallenge.UserId,
+					ChallengeUserId: userChallenge.Id,
+					Date:            track.Date,
+					NewDone:         value,
+					Source:          source,
+				}
+				if track.Id != 0 {
+					oldDone := track.Done
+					item.OldDone = &oldDone
+				}
+				history = append(history, item)
+			}
 		}
 
 		// Рассчитываем score
@@ -135,15 +309,88 @@ func (s *DBCProcessor) MakeTrack(ctx context.Context, challengeUserId int64, dat
 		track.ScoreDaily = diff
 	}
 
-	err = s.trackRepository.InsertOrUpdateBulk(ctx, tracks)
+	err = s.trxManager.Do(ctx, func(ctx context.Context) error {
+		err := s.trackRepository.InsertOrUpdateBulk(ctx, tracks)
+		if err != nil {
+			return errors.Wrap(err, "InsertOrUpdateBulk")
+		}
+
+		err = s.trackHistoryRepository.InsertBulk(ctx, history)
+		if err != nil {
+			return errors.Wrap(err, "history InsertBulk")
+		}
+
+		err = s.addTrackMadeEvents(ctx, history)
+		if err != nil {
+			return errors.Wrap(err, "addTrackMadeEvents")
+		}
+		return nil
+	})
 	if err != nil {
-		return false, errors.Wrap(err, "InsertOrUpdateBulk")
+		return false, errors.Wrap(err, "trxManager")
+	}
+
+	for _, item := range history {
+		tracksMadeMetric.WithLabelValues(item.Source, strconv.FormatBool(item.NewDone)).Inc()
 	}
 
 	return true, nil
 }
 
-func (s *DBCProcessor) CalculateDailyScore(ctx context.Context, userId int64) (int64, error) {
+// Отменяет последнее изменение трека пользователем (восстанавливает предыдущее значение и пересчитывает цепочку).
+// nil - отменять нечего
+func (s *DBCProcessor) UndoLastTrack(ctx context.Context, challengeUserId int64) (_ *domain.DBCTrackHistory, err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.UndoLastTrack")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	var undone *domain.DBCTrackHistory
+
+	err = s.trxManager.Do(ctx, func(ctx context.Context) error {
+		last, err := s.trackHistoryRepository.ChallengeFetchLastNotUndone(ctx, challengeUserId, domain.TrackSourceUser)
+		if err != nil {
+			return errors.Wrap(err, "ChallengeFetchLastNotUndone")
+		}
+		if last == nil {
+			return nil
+		}
+
+		// Трека не было - в цепочке отсутствующий трек равен false
+		value := false
+		if last.OldDone != nil {
+			value = *last.OldDone
+		}
+
+		ok, err := s.MakeTrack(ctx, challengeUserId, last.Date, value, domain.TrackSourceUndo)
+		if err != nil {
+			return errors.Wrap(err, "MakeTrack")
+		}
+		if !ok {
+			return errors.Errorf("cannot restore track %s of challenge %d", last.Date.Format("2006-01-02"), challengeUserId)
+		}
+
+		err = s.trackHistoryRepository.SetUndone(ctx, last.Id)
+		if err != nil {
+			return errors.Wrap(err, "SetUndone")
+		}
+
+		undone = last
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "trxManager")
+	}
+
+	return undone, nil
+}
+
+func (s *DBCProcessor) CalculateDailyScore(ctx context.Context, userId int64) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.CalculateDailyScore")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	// Для каждого челленжда вычисляем scores
 	challenges, err := s.challengeUserRepository.UserFetchAll(userId)
 	if err != nil {
@@ -154,17 +401,10 @@ func (s *DBCProcessor) CalculateDailyScore(ctx context.Context, userId int64) (i
 	totalDailyScore := int64(0)
 	for _, challenge := range challenges {
 
-		// ToDo: real period
-		period := domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+		period := s.periodProc.ChallengePeriod(challenge.ChallengeInfo)
 
 		// Вычисляем дату на стыке score и dailyScore
-
-		n := DBC_MAX_STEP_CAN_CHANGE
-		if challenge.ChallengeInfo.IsAutoTrack {
-			n = DBC_MAX_STEP_CAN_CHANGE_AUTO
-		}
-
-		dateProcessed, err := s.getSeparatorDateDailyBefore(period, n)
+		dateProcessed, err := s.getSeparatorDateDailyBefore(period, s.EditWindow(challenge.ChallengeInfo))
 		if err != nil {
 			return -1, errors.Wrap(err, "getSeparatorDateDaily")
 		}
@@ -200,17 +440,23 @@ func (s *DBCProcessor) CalculateDailyScore(ctx context.Context, userId int64) (i
 }
 
 // Обрабатывает все треки (Ручные) для учета User.Score и Challenge.LastSeries
-func (s *DBCProcessor) ProcessChallengeTracks(ctx context.Context, challenge *domain.DBCUserChallenge) error {
+func (s *DBCProcessor) ProcessChallengeTracks(ctx context.Context, challenge *domain.DBCUserChallenge) (err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.ProcessChallengeTracks")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	period := s.periodProc.ChallengePeriod(challenge.ChallengeInfo)
 
-	period := domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+	window := s.EditWindow(challenge.ChallengeInfo)
 
-	dailyDate, err := s.getSeparatorDateDaily(period, DBC_MAX_STEP_CAN_CHANGE)
+	dailyDate, err := s.getSeparatorDateDaily(period, window)
 	if err != nil {
 		return errors.Wrap(err, "getSeparatorDateDaily")
 	}
 
 	// Fill all null values that were not set by user
-	err = s.fillAbsentTracksStepN(ctx, challenge, 3, false)
+	err = s.fillAbsentTracksStepN(ctx, challenge, window, false)
 	if err != nil {
 		return errors.Wrap(err, "fillAbsentTracks")
 	}
@@ -240,8 +486,19 @@ func (s *DBCProcessor) ProcessChallengeTracks(ctx context.Context, challenge *do
 		challenge.LastSeries = 0
 	}
 
+	// Рекордная серия и рубежи
+	reached, err := s.updateBestSeries(challenge, tracks, period)
+	if err != nil {
+		return errors.Wrap(err, "updateBestSeries")
+	}
+
+	broken, err := s.brokenSeries(ctx, challenge, tracks)
+	if err != nil {
+		return errors.Wrap(err, "brokenSeries")
+	}
+
 	err = s.trxManager.Do(ctx, func(ctx context.Context) error {
-		err := s.userRepo.AddScore(ctx, challenge.UserId, score)
+		newScore, err := s.userRepo.AddScore(ctx, challenge.UserId, score)
 		if err != nil {
 			return errors.Wrap(err, "AddScore")
 		}
@@ -251,9 +508,14 @@ func (s *DBCProcessor) ProcessChallengeTracks(ctx context.Context, challenge *do
 			return errors.Wrap(err, "SetProcessed")
 		}
 
-		err = s.challengeUserRepository.Update(challenge)
+		err = s.challengeUserRepository.UpdateProgress(ctx, challenge)
+		if err != nil {
+			return errors.Wrap(err, "UpdateProgress")
+		}
+
+		err = s.addProcessedEvents(ctx, challenge, score, newScore, broken, reached)
 		if err != nil {
-			return errors.Wrap(err, "ChallengeUpdate")
+			return errors.Wrap(err, "addProcessedEvents")
 		}
 
 		return nil
@@ -262,20 +524,37 @@ func (s *DBCProcessor) ProcessChallengeTracks(ctx context.Context, challenge *do
 		return errors.Wrap(err, "trxManager")
 	}
 
+	for _, milestone := range reached {
+		milestonesMetric.WithLabelValues(strconv.FormatInt(milestone, 10)).Inc()
+		achievementsUnlockedMetric.WithLabelValues(domain.AchievementKindSeriesMilestone).Inc()
+	}
+
 	return nil
 }
 
-func (s *DBCProcessor) ProcessAutoChallengeTracks(ctx context.Context, challenge *domain.DBCUserChallenge) error {
+func (s *DBCProcessor) ProcessAutoChallengeTracks(ctx context.Context, challenge *domain.DBCUserChallenge) (err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.ProcessAutoChallengeTracks")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	period := s.periodProc.ChallengePeriod(challenge.ChallengeInfo)
 
-	period := domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+	window := s.EditWindow(challenge.ChallengeInfo)
 
-	dailyDate, err := s.getSeparatorDateDaily(period, DBC_MAX_STEP_CAN_CHANGE_AUTO)
+	dailyDate, err := s.getSeparatorDateDaily(period, window)
 	if err != nil {
 		return errors.Wrap(err, "getSeparatorDateDaily")
 	}
 
+	// Челлендж с правилами внешних сигналов выполнен только если пришел сигнал
+	hasRules, err := s.signalRulesRepository.ChallengeHasRules(ctx, challenge.Id)
+	if err != nil {
+		return errors.Wrap(err, "ChallengeHasRules")
+	}
+
 	// Fill all null values that were not set by user
-	err = s.fillAbsentTracksStepN(ctx, challenge, 1, true)
+	err = s.fillAbsentTracksStepN(ctx, challenge, window, !hasRules)
 	if err != nil {
 		return errors.Wrap(err, "fillAbsentTracks")
 	}
@@ -305,8 +584,19 @@ func (s *DBCProcessor) ProcessAutoChallengeTracks(ctx context.Context, challenge
 		challenge.LastSeries = 0
 	}
 
+	// Рекордная серия и рубежи
+	reached, err := s.updateBestSeries(challenge, tracks, period)
+	if err != nil {
+		return errors.Wrap(err, "updateBestSeries")
+	}
+
+	broken, err := s.brokenSeries(ctx, challenge, tracks)
+	if err != nil {
+		return errors.Wrap(err, "brokenSeries")
+	}
+
 	err = s.trxManager.Do(ctx, func(ctx context.Context) error {
-		err = s.userRepo.AddScore(ctx, challenge.UserId, score)
+		newScore, err := s.userRepo.AddScore(ctx, challenge.UserId, score)
 		if err != nil {
 			return errors.Wrap(err, "AddScore")
 		}
@@ -316,9 +606,14 @@ func (s *DBCProcessor) ProcessAutoChallengeTracks(ctx context.Context, challenge
 			return errors.Wrap(err, "SetProcessed")
 		}
 
-		err = s.challengeUserRepository.Update(challenge)
+		err = s.challengeUserRepository.UpdateProgress(ctx, challenge)
+		if err != nil {
+			return errors.Wrap(err, "UpdateProgress")
+		}
+
+		err = s.addProcessedEvents(ctx, challenge, score, newScore, broken, reached)
 		if err != nil {
-			return errors.Wrap(err, "ChallengeUpdate")
+			return errors.Wrap(err, "addProcessedEvents")
 		}
 
 		return nil
@@ -327,14 +622,111 @@ func (s *DBCProcessor) ProcessAutoChallengeTracks(ctx context.Context, challenge
 		return errors.Wrap(err, "trxManager")
 	}
 
+	for _, milestone := range reached {
+		milestonesMetric.WithLabelValues(strconv.FormatInt(milestone, 10)).Inc()
+		achievementsUnlockedMetric.WithLabelValues(domain.AchievementKindSeriesMilestone).Inc()
+	}
+
 	return nil
 }
 
+// Завершает челлендж на срок, когда его последний день больше нельзя изменить.
+// completed - доля выполненных дней >= challenges.completion_ratio, иначе failed
+func (s *DBCProcessor) FinishIfEnded(ctx context.Context, challenge *domain.DBCUserChallenge) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "DBCProcessor.FinishIfEnded")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if challenge.EndDate == nil || challenge.Status != domain.ChallengeStatusActive {
+		return false, nil
+	}
+
+	period := s.periodProc.ChallengePeriod(challenge.ChallengeInfo)
+
+	separatorDate, err := s.getSeparatorDateDaily(period, s.EditWindow(challenge.ChallengeInfo))
+	if err != nil {
+		return false, errors.Wrap(err, "getSeparatorDateDaily")
+	}
+	endDate := tools.RoundDateTimeToDay(challenge.EndDate.UTC())
+	if !separatorDate.After(endDate) {
+		return false, nil
+	}
+
+	startDate := tools.RoundDateTimeToDay(challenge.CreatedAt.UTC())
+	if challenge.StartDate != nil {
+		startDate = tools.RoundDateTimeToDay(challenge.StartDate.UTC())
+	}
+
+	dates, err := s.periodProc.AbsentWindow(startDate.Add(-24*time.Hour), endDate.Add(24*time.Hour), period)
+	if err != nil {
+		return false, errors.Wrap(err, "AbsentWindow")
+	}
+
+	doneCount := int64(0)
+	if len(dates) > 0 {
+		tracks, err := s.trackRepository.ChallengeFetchByDates(challenge.Id, dates)
+		if err != nil {
+			return false, errors.Wrap(err, "ChallengeFetchByDates")
+		}
+		for _, track := range tracks {
+			if track.Done {
+				doneCount++
+			}
+		}
+	}
+	totalCount := int64(len(dates))
+
+	ratio := viper.GetFloat64("challenges.completion_ratio")
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultCompletionRatio
+	}
+
+	status := domain.ChallengeStatusFailed
+	if totalCount > 0 && float64(doneCount) >= ratio*float64(totalCount) {
+		status = domain.ChallengeStatusCompleted
+	}
+
+	err = s.trxManager.Do(ctx, func(ctx context.Context) error {
+		err := s.challengeUserRepository.Finish(ctx, challenge.Id, status)
+		if err != nil {
+			return errors.Wrap(err, "Finish")
+		}
+
+		err = s.outbox.Add(ctx, domain.OutboxEventChallengeFinished, challenge.UserId, &domain.ChallengeFinishedEvent{
+			UserId:          challenge.UserId,
+			ChallengeId:     challenge.Id,
+			ChallengeInfoId: challenge.ChallengeInfoId,
+			Status:          status,
+			DoneCount:       doneCount,
+			TotalCount:      totalCount,
+			StartDate:       startDate,
+			EndDate:         endDate,
+		})
+		if err != nil {
+			return errors.Wrap(err, "outbox Add")
+		}
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "trxManager")
+	}
+	challenge.Status = status
+	challengesFinishedMetric.WithLabelValues(status).Inc()
+
+	err = s.gamifyProc.HandleChallengeFinished(ctx, challenge)
+	if err != nil {
+		s.log.ErrorWrap(err, "HandleChallengeFinished for challenge %d", challenge.Id)
+	}
+
+	return true, nil
+}
+
 //
 // HELPERS
 //
 
-// Получение последней даты, которую можно менять (3ий шаг назад)
+// Получение последней даты, которую можно менять (шаг назад на окно редактирования)
 func (s *DBCProcessor) getSeparatorDateDaily(period domain.GenerationPeriod, step int) (time.Time, error) {
 	date := tools.RoundDateTimeToDay(time.Now().UTC().Add(24 * time.Hour))
 
@@ -346,7 +738,7 @@ func (s *DBCProcessor) getSeparatorDateDaily(period domain.GenerationPeriod, ste
 	return backDate, nil
 }
 
-// Получение первой даты, которую уже нельзя менять (4ий шаг назад)
+// Получение первой даты, которую уже нельзя менять (шаг за окном редактирования)
 func (s *DBCProcessor) getSeparatorDateDailyBefore(period domain.GenerationPeriod, step int) (time.Time, error) {
 	date := tools.RoundDateTimeToDay(time.Now().UTC().Add(24 * time.Hour))
 
@@ -358,6 +750,144 @@ func (s *DBCProcessor) getSeparatorDateDailyBefore(period domain.GenerationPerio
 	return backDate, nil
 }
 
+// Обновляет рекордную серию и достигнутые рубежи по обработанным трекам (отсортированы по дате).
+// Возвращает рубежи, достигнутые впервые
+func (s *DBCProcessor) updateBestSeries(challenge *domain.DBCUserChallenge, tracks []*domain.DBCTrack, period domain.GenerationPeriod) ([]int64, error) {
+	var seriesStart *time.Time
+	var reached []int64
+
+	for _, track := range tracks {
+		if track.LastSeries <= 0 {
+			seriesStart = nil
+			continue
+		}
+
+		date := track.Date
+		if track.LastSeries == 1 {
+			seriesStart = &date
+		}
+		if track.LastSeries <= challenge.BestSeries {
+			continue
+		}
+
+		// Серия началась до обработанных треков
+		if seriesStart == nil {
+			start, err := s.periodProc.StepBackN(date, period, int(track.LastSeries-1))
+			if err != nil {
+				return nil, errors.Wrap(err, "StepBackN")
+			}
+			seriesStart = &start
+		}
+
+		start := *seriesStart
+		challenge.BestSeries = track.LastSeries
+		challenge.BestSeriesStart = &start
+		challenge.BestSeriesEnd = &date
+
+		for _, milestone := range domain.SeriesMilestones {
+			if track.LastSeries >= milestone && !lo.Contains(challenge.Milestones, milestone) {
+				challenge.Milestones = append(challenge.Milestones, milestone)
+				reached = append(reached, milestone)
+			}
+		}
+	}
+
+	return reached, nil
+}
+
+// Серии, прерванные невыполненными треками среди обрабатываемых (tracks по возрастанию даты)
+func (s *DBCProcessor) brokenSeries(ctx context.Context, challenge *domain.DBCUserChallenge, tracks []*domain.DBCTrack) ([]*domain.SeriesBrokenEvent, error) {
+	if len(tracks) == 0 {
+		return nil, nil
+	}
+
+	prevSeries := int64(0)
+	prevTrack, err := s.trackRepository.ChallengeFetchLastBefore(ctx, challenge.Id, tracks[0].Date)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeFetchLastBefore")
+	}
+	if prevTrack != nil {
+		prevSeries = prevTrack.LastSeries
+	}
+
+	var result []*domain.SeriesBrokenEvent
+	for _, track := range tracks {
+		if track.LastSeries <= 0 && prevSeries > 0 {
+			result = append(result, &domain.SeriesBrokenEvent{
+				UserId:      challenge.UserId,
+				ChallengeId: challenge.Id,
+				Date:        track.Date.Format("2006-01-02"),
+				Length:      prevSeries,
+				BestSeries:  challenge.BestSeries,
+			})
+		}
+		prevSeries = track.LastSeries
+	}
+
+	return result, nil
+}
+
+// События обработки треков: изменение счета, прерванные серии и достигнутые рубежи серий
+func (s *DBCProcessor) addProcessedEvents(ctx context.Context, challenge *domain.DBCUserChallenge, delta, score int64,
+	broken []*domain.SeriesBrokenEvent, reached []int64) error {
+	now := time.Now().UTC()
+
+	if delta != 0 {
+		err := s.outbox.Add(ctx, domain.OutboxEventUserScoreChanged, challenge.UserId, &domain.UserScoreChangedEvent{
+			UserId:      challenge.UserId,
+			ChallengeId: challenge.Id,
+			Delta:       delta,
+			Score:       score,
+			ChangedAt:   now,
+		})
+		if err != nil {
+			return errors.Wrap(err, "user score changed")
+		}
+	}
+
+	for _, event := range broken {
+		err := s.outbox.Add(ctx, domain.OutboxEventSeriesBroken, challenge.UserId, event)
+		if err != nil {
+			return errors.Wrap(err, "series broken")
+		}
+	}
+
+	for _, milestone := range reached {
+		err := s.outbox.Add(ctx, domain.OutboxEventAchievementUnlocked, challenge.UserId, &domain.AchievementUnlockedEvent{
+			UserId:      challenge.UserId,
+			Kind:        domain.AchievementKindSeriesMilestone,
+			ChallengeId: challenge.Id,
+			Value:       milestone,
+			UnlockedAt:  now,
+		})
+		if err != nil {
+			return errors.Wrap(err, "achievement unlocked")
+		}
+	}
+
+	return nil
+}
+
+// События изменения треков (по одному на запись истории)
+func (s *DBCProcessor) addTrackMadeEvents(ctx context.Context, history []*domain.DBCTrackHistory) error {
+	madeAt := time.Now().UTC()
+	for _, item := range history {
+		err := s.outbox.Add(ctx, domain.OutboxEventTrackMade, item.UserId, &domain.TrackMadeEvent{
+			UserId:      item.UserId,
+			ChallengeId: item.ChallengeUserId,
+			Date:        item.Date.Format("2006-01-02"),
+			Done:        item.NewDone,
+			OldDone:     item.OldDone,
+			Source:      item.Source,
+			MadeAt:      madeAt,
+		})
+		if err != nil {
+			return errors.Wrap(err, "outbox Add")
+		}
+	}
+	return nil
+}
+
 // return lastScore, lastSeries, diff (сколько отнялось)
 func (s *DBCProcessor) nextTrackPoints(lastScore int64, lastSeries int64, currentValue bool) (int64, int64, int64) {
 
@@ -375,8 +905,7 @@ func (s *DBCProcessor) fillAbsentTracksStepN(ctx context.Context, challenge *dom
 	//
 	// Make step back 1 for auto track last track
 
-	// ToDO: Period should be binded with challenge
-	period := domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+	period := s.periodProc.ChallengePeriod(challenge.ChallengeInfo)
 
 	nowDate := tools.RoundDateTimeToDay(time.Now().UTC().Add(24 * time.Hour))
 
@@ -386,6 +915,14 @@ func (s *DBCProcessor) fillAbsentTracksStepN(ctx context.Context, challenge *dom
 		return errors.Wrap(err, "StepBack")
 	}
 
+	// Челлендж на срок - не заполняем после даты окончания
+	if challenge.EndDate != nil {
+		endNext := tools.RoundDateTimeToDay(challenge.EndDate.UTC()).Add(24 * time.Hour)
+		if endNext.Before(toDate) {
+			toDate = endNext
+		}
+	}
+
 	lastTrack, err := s.trackRepository.ChallengeFetchLastBefore(ctx, challenge.Id, toDate)
 	if err != nil {
 		return errors.Wrap(err, "ChallengeFetchLastBefore")
@@ -398,6 +935,9 @@ func (s *DBCProcessor) fillAbsentTracksStepN(ctx context.Context, challenge *dom
 	// Lets make all track since created date
 	if lastTrack == nil {
 		fromDate = challenge.CreatedAt.Add(-24 * time.Hour)
+		if challenge.StartDate != nil {
+			fromDate = challenge.StartDate.Add(-24 * time.Hour)
+		}
 	} else {
 		fromDate = lastTrack.Date
 		lastScore = lastTrack.Score
@@ -411,25 +951,47 @@ func (s *DBCProcessor) fillAbsentTracksStepN(ctx context.Context, challenge *dom
 
 	//
 	var tracks []*domain.DBCTrack
+	var history []*domain.DBCTrackHistory
 
 	for _, date := range windowDates {
 		// Рассчитываем score
 		lastScore, lastSeries, diff = s.nextTrackPoints(lastScore, lastSeries, value)
 
 		tracks = append(tracks, &domain.DBCTrack{
-			UserId:      challenge.UserId,
-			ChallengeId: challenge.Id,
-			Date:        date,
-			Done:        value,
-			LastSeries:  lastSeries,
-			Score:       lastScore,
-			ScoreDaily:  diff,
+			UserId:          challenge.UserId,
+			ChallengeId:     challenge.ChallengeInfoId,
+			ChallengeUserId: challenge.Id,
+			Date:            date,
+			Done:            value,
+			LastSeries:      lastSeries,
+			Score:           lastScore,
+			ScoreDaily:      diff,
+		})
+		history = append(history, &domain.DBCTrackHistory{
+			UserId:          challenge.UserId,
+			ChallengeUserId: challenge.Id,
+			Date:            date,
+			NewDone:         value,
+			Source:          domain.TrackSourceAutoFill,
 		})
 	}
 
-	err = s.trackRepository.InsertOrUpdateBulk(ctx, tracks)
+	err = s.trxManager.Do(ctx, func(ctx context.Context) error {
+		err := s.trackRepository.InsertOrUpdateBulk(ctx, tracks)
+		if err != nil {
+			return errors.Wrap(err, "InsertOrUpdateBulk")
+		}
+
+		// TrackMade не отправляется: автозаполнение не действие пользователя,
+		// а первое заполнение старого челленджа дало бы сотни событий
+		err = s.trackHistoryRepository.InsertBulk(ctx, history)
+		if err != nil {
+			return errors.Wrap(err, "history InsertBulk")
+		}
+		return nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "InsertOrUpdateBulk")
+		return errors.Wrap(err, "trxManager")
 	}
 
 	return nil
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app"
+	"microservice/app/core"
+	"sync"
+	"time"
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+const maxIdempotencyKeyLength = 128
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used with another request")
+var ErrIdempotencyKeyInvalid = errors.New("invalid idempotency key")
+
+type idempotencyEntry struct {
+	Fingerprint string          `json:"fingerprint"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// Хранит результаты запросов по ключу идемпотентности клиента (bitcask, с TTL)
+type IdempotencyStore struct {
+	log     core.Logger
+	storage *app.Storage
+
+	mu    sync.Mutex
+	locks map[string]*idempotencyLock
+}
+
+type idempotencyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewIdempotencyStore(log core.Logger) (*IdempotencyStore, error) {
+	storage, err := app.NewStorage("idempotency")
+	if err != nil {
+		return nil, errors.Wrap(err, "NewStorage")
+	}
+	return &IdempotencyStore{
+		log:     log,
+		storage: storage,
+		locks:   map[string]*idempotencyLock{},
+	}, nil
+}
+
+// Выполняет fn один раз для ключа. Повторный запрос с тем же ключом (в т.ч. параллельный)
+// получает сохраненный результат в out. Ошибки fn не сохраняются - запрос можно повторить
+func (s *IdempotencyStore) Do(scope string, userId int64, key, fingerprint string, out interface{}, fn func() (interface{}, error)) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return ErrIdempotencyKeyInvalid
+	}
+
+	storageKey := s.storageKey(scope, userId, key)
+
+	unlock := s.lock(storageKey)
+	defer unlock()
+
+	cached, err := s.storage.GetString(storageKey)
+	if err != nil {
+		return errors.Wrap(err, "GetString")
+	}
+	if cached != nil {
+		entry := idempotencyEntry{}
+		err = json.Unmarshal([]byte(*cached), &entry)
+		if err != nil {
+			return errors.Wrap(err, "Unmarshal entry")
+		}
+		if entry.Fingerprint != fingerprint {
+			return ErrIdempotencyKeyReused
+		}
+		return errors.Wrap(json.Unmarshal(entry.Result, out), "Unmarshal result")
+	}
+
+	result, err := fn()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "Marshal result")
+	}
+	entry, err := json.Marshal(idempotencyEntry{
+		Fingerprint: fingerprint,
+		Result:      data,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Marshal entry")
+	}
+
+	ttl := viper.GetDuration("idempotency.ttl")
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	err = s.storage.PutStringWithTTL(storageKey, string(entry), ttl)
+	if err != nil {
+		// Запрос уже выполнен, просто не сможем ответить на повтор из кэша
+		s.log.ErrorWrap(err, "cannot save idempotency result")
+	}
+
+	return errors.Wrap(json.Unmarshal(data, out), "Unmarshal result")
+}
+
+// bitcask ограничивает длину ключа 64 байтами, поэтому храним хэш
+func (s *IdempotencyStore) storageKey(scope string, userId int64, key string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", scope, userId, key)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *IdempotencyStore) lock(key string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &idempotencyLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+
+		s.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, key)
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"microservice/app/core"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const defaultImageMaxSize = 2 << 20
+const defaultImageMaxPixels = 25_000_000
+const defaultThumbnailSize = 256
+
+var ErrImageTooLarge = errors.New("image is too large")
+var ErrImageUnsupported = errors.New("unsupported image type")
+
+type ImageProcessor struct {
+	log core.Logger
+}
+
+func NewImageProcessor(log core.Logger) *ImageProcessor {
+	return &ImageProcessor{
+		log: log,
+	}
+}
+
+// Проверяет размер и тип изображения. Возвращает content type и расширение файла
+func (s *ImageProcessor) Validate(data []byte) (string, string, error) {
+
+	maxSize := viper.GetInt("images.max_size")
+	if maxSize <= 0 {
+		maxSize = defaultImageMaxSize
+	}
+	if len(data) > maxSize {
+		return "", "", ErrImageTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	var ext string
+	switch contentType {
+	case "image/jpeg":
+		ext = "jpg"
+	case "image/png":
+		ext = "png"
+	case "image/gif":
+		ext = "gif"
+	default:
+		return "", "", ErrImageUnsupported
+	}
+
+	// Header may lie - make sure that image is really decodable
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", "", ErrImageUnsupported
+	}
+
+	// Маленький файл может распаковаться в огромное изображение - проверяем до декодирования
+	maxPixels := viper.GetInt64("images.max_pixels")
+	if maxPixels <= 0 {
+		maxPixels = defaultImageMaxPixels
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return "", "", ErrImageTooLarge
+	}
+
+	return contentType, ext, nil
+}
+
+// Делает превью изображения (длинная сторона = images.thumbnail_size).
+// JPEG остается JPEG, все остальное кодируется в PNG
+func (s *ImageProcessor) Thumbnail(data []byte) ([]byte, string, error) {
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "Decode")
+	}
+
+	size := viper.GetInt("images.thumbnail_size")
+	if size <= 0 {
+		size = defaultThumbnailSize
+	}
+
+	dst := resize(src, size)
+
+	buf := &bytes.Buffer{}
+	if format == "jpeg" {
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: 85})
+		if err != nil {
+			return nil, "", errors.Wrap(err, "jpeg.Encode")
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	err = png.Encode(buf, dst)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "png.Encode")
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// Box-фильтр: каждый пиксель превью - среднее по соответствующей области оригинала
+func resize(src image.Image, maxSide int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+
+	dw, dh := maxSide, maxSide
+	if w > h {
+		dh = h * maxSide / w
+	} else {
+		dw = w * maxSide / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := b.Min.Y + y*h/dh
+		y1 := b.Min.Y + (y+1)*h/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0 := b.Min.X + x*w/dw
+			x1 := b.Min.X + (x+1)*w/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// Ключ превью рядом с оригиналом: challenges/1/abc.png -> challenges/1/abc_thumb.png.
+// Расширение совпадает с форматом из Thumbnail: все кроме JPEG хранится как PNG
+func ThumbnailKey(key string) string {
+	ext := path.Ext(key)
+	thumbExt := ".png"
+	if ext == ".jpg" || ext == ".jpeg" {
+		thumbExt = ext
+	}
+	return strings.TrimSuffix(key, ext) + "_thumb" + thumbExt
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io"
+	"microservice/layers/domain"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ImportFormatLoopHabits = "loop"
+
+// Полный бэкап Loop ("Export full backup") - база SQLite
+const sqliteHeader = "SQLite format 3\x00"
+
+const (
+	defaultImportMaxArchiveSize = 20 << 20
+	defaultImportMaxEntrySize   = 50 << 20
+)
+
+// Значения чекмарков в экспорте Loop Habit Tracker (boolean привычки)
+const (
+	loopNo        = 0
+	loopYesAuto   = 1 // выполнено "по частоте" (например, 3 раза в неделю уже набрано)
+	loopYesManual = 2
+	loopSkip      = 3 // пропуск, который не рвет серию
+)
+
+// Импорт из Loop Habit Tracker. "Export as CSV" (zip архив):
+//
+//	Habits.csv     - Position,Name,Type,Question,Description,FrequencyNumerator,FrequencyDenominator,...,Target Type,Target Value,...
+//	Checkmarks.csv - Date,<habit 1>,<habit 2>,...
+//	001 <habit>/Checkmarks.csv - Date,Value (если общего файла нет)
+//
+// "Export full backup" (SQLite): таблицы Habits и Repetitions.
+//
+// Частота Loop ("N раз за M дней") не переносится: привычка импортируется ежедневной,
+// выполненными - только реальные отметки. Выведенное из частоты расписание не совпадало бы
+// с днями отметок и рвало бы серии.
+type LoopHabitsImportAdapter struct{}
+
+func NewLoopHabitsImportAdapter() *LoopHabitsImportAdapter {
+	return &LoopHabitsImportAdapter{}
+}
+
+type loopHabit struct {
+	name      string
+	desc      string
+	numerical bool
+	atMost    bool
+	target    float64
+	checks    map[time.Time]int64
+}
+
+func (a *LoopHabitsImportAdapter) Format() string {
+	return ImportFormatLoopHabits
+}
+
+func (a *LoopHabitsImportAdapter) Parse(data []byte) (*domain.ImportBundle, error) {
+	maxArchiveSize := viper.GetInt("import.max_archive_size")
+	if maxArchiveSize <= 0 {
+		maxArchiveSize = defaultImportMaxArchiveSize
+	}
+	if len(data) > maxArchiveSize {
+		return nil, ErrImportTooLarge
+	}
+
+	var habits []*loopHabit
+	var err error
+	if bytes.HasPrefix(data, []byte(sqliteHeader)) {
+		habits, err = a.readDatabase(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "readDatabase")
+		}
+	} else {
+		habits, err = a.readArchive(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a.bundle(habits), nil
+}
+
+// Zip архив "Export as CSV"
+func (a *LoopHabitsImportAdapter) readArchive(data []byte) ([]*loopHabit, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "loop export should be zip archive or sqlite backup")
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[path.Clean(f.Name)] = f
+	}
+
+	habitsFile := a.findFile(files, "Habits.csv")
+	if habitsFile == nil {
+		return nil, errors.New("Habits.csv was not found in loop export")
+	}
+	root := path.Dir(path.Clean(habitsFile.Name))
+
+	habits, err := a.readHabits(habitsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "readHabits")
+	}
+
+	// Общий файл с чекмарками всех привычек, иначе - по папке на привычку
+	if f, ok := files[path.Join(root, "Checkmarks.csv")]; ok {
+		err = a.readAllCheckmarks(f, habits)
+		if err != nil {
+			return nil, errors.Wrap(err, "readAllCheckmarks")
+		}
+	} else {
+		for _, habit := range habits {
+			f := a.findHabitCheckmarks(files, root, habit.name)
+			if f == nil {
+				continue
+			}
+			err = a.readHabitCheckmarks(f, habit)
+			if err != nil {
+				return nil, errors.Wrapf(err, "readHabitCheckmarks %s", habit.name)
+			}
+		}
+	}
+
+	return habits, nil
+}
+
+// SQLite бэкап. Драйверу нужен файл - пишем во временный
+func (a *LoopHabitsImportAdapter) readDatabase(data []byte) (habits []*loopHabit, err error) {
+	f, err := os.CreateTemp("", "loop-*.db")
+	if err != nil {
+		return nil, errors.Wrap(err, "CreateTemp")
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "write temp file")
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+f.Name()+"?mode=ro")
+	if err != nil {
+		return nil, errors.Wrap(err, "sql.Open")
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`select id, coalesce(name, ''), coalesce(description, ''), coalesce(question, ''),
+		coalesce(type, 0), coalesce(target_type, 0), coalesce(target_value, 0)
+		from Habits order by position`)
+	if err != nil {
+		return nil, errors.Wrap(err, "select habits")
+	}
+	defer rows.Close()
+
+	byId := make(map[int64]*loopHabit)
+	for rows.Next() {
+		var id, habitType, targetType int64
+		var desc, question string
+		habit := &loopHabit{checks: make(map[time.Time]int64)}
+		err = rows.Scan(&id, &habit.name, &desc, &question, &habitType, &targetType, &habit.target)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan habit")
+		}
+		habit.name = strings.TrimSpace(habit.name)
+		if habit.name == "" {
+			continue
+		}
+		habit.desc = strings.TrimSpace(desc)
+		if habit.desc == "" {
+			habit.desc = strings.TrimSpace(question)
+		}
+		habit.numerical = habitType == 1
+		habit.atMost = targetType == 1
+		byId[id] = habit
+		habits = append(habits, habit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "select habits")
+	}
+
+	// timestamp - полночь дня в UTC, в миллисекундах
+	reps, err := db.Query(`select habit, timestamp, value from Repetitions`)
+	if err != nil {
+		return nil, errors.Wrap(err, "select repetitions")
+	}
+	defer reps.Close()
+
+	for reps.Next() {
+		var habitId, timestamp, value int64
+		err = reps.Scan(&habitId, &timestamp, &value)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan repetition")
+		}
+		habit, ok := byId[habitId]
+		if !ok {
+			continue
+		}
+		date := time.UnixMilli(timestamp).UTC().Truncate(24 * time.Hour)
+		habit.checks[date] = value
+	}
+	if err = reps.Err(); err != nil {
+		return nil, errors.Wrap(err, "select repetitions")
+	}
+
+	return habits, nil
+}
+
+func (a *LoopHabitsImportAdapter) bundle(habits []*loopHabit) *domain.ImportBundle {
+	bundle := &domain.ImportBundle{}
+	line := int64(0)
+	for _, habit := range habits {
+		challenge := &domain.ImportChallenge{
+			Name:   habit.name,
+			Period: domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay},
+		}
+		if habit.desc != "" {
+			challenge.Desc = &habit.desc
+		}
+		bundle.Challenges = append(bundle.Challenges, challenge)
+
+		dates := make([]time.Time, 0, len(habit.checks))
+		for date := range habit.checks {
+			dates = append(dates, date)
+		}
+		sort.Slice(dates, func(i, j int) bool {
+			return dates[i].Before(dates[j])
+		})
+
+		for _, date := range dates {
+			done, ok := a.isDone(habit, habit.checks[date])
+			if !ok {
+				continue
+			}
+			line++
+			bundle.Rows = append(bundle.Rows, &domain.ImportTrackRow{
+				Line:      line,
+				Challenge: habit.name,
+				Date:      date.Format("2006-01-02"),
+				Value:     strconv.FormatBool(done),
+			})
+		}
+	}
+
+	return bundle
+}
+
+func (a *LoopHabitsImportAdapter) readHabits(f *zip.File) ([]*loopHabit, error) {
+	records, err := a.readCSV(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Habits.csv is empty")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	get := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(record) && strings.TrimSpace(record[i]) != "" {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+
+	var habits []*loopHabit
+	for _, record := range records[1:] {
+		habit := &loopHabit{
+			name:      get(record, "name"),
+			desc:      get(record, "description", "question"),
+			numerical: get(record, "type") == "1",
+			atMost:    get(record, "target type") == "1",
+			checks:    make(map[time.Time]int64),
+		}
+		if habit.name == "" {
+			continue
+		}
+		if x, err := strconv.ParseFloat(get(record, "target value"), 64); err == nil {
+			habit.target = x
+		}
+		habits = append(habits, habit)
+	}
+
+	return habits, nil
+}
+
+func (a *LoopHabitsImportAdapter) readAllCheckmarks(f *zip.File, habits []*loopHabit) error {
+	records, err := a.readCSV(f)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]*loopHabit, len(habits))
+	for _, habit := range habits {
+		byName[habit.name] = habit
+	}
+
+	header := records[0]
+	for _, record := range records[1:] {
+		if len(record) == 0 {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return errors.Wrapf(err, "incorrect date %s", record[0])
+		}
+		for i := 1; i < len(record) && i < len(header); i++ {
+			habit, ok := byName[strings.TrimSpace(header[i])]
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseInt(strings.TrimSpace(record[i]), 10, 64)
+			if err != nil {
+				continue
+			}
+			habit.checks[date] = value
+		}
+	}
+
+	return nil
+}
+
+func (a *LoopHabitsImportAdapter) readHabitCheckmarks(f *zip.File, habit *loopHabit) error {
+	records, err := a.readCSV(f)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			// header
+			continue
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		habit.checks[date] = value
+	}
+
+	return nil
+}
+
+// Возвращает (выполнено?, есть ли значение)
+func (a *LoopHabitsImportAdapter) isDone(habit *loopHabit, value int64) (bool, bool) {
+	if habit.numerical {
+		// Числовые привычки хранятся * 1000
+		if value < 0 {
+			return false, false
+		}
+		target := int64(habit.target * 1000)
+		if habit.atMost {
+			return value <= target, true
+		}
+		return value >= target, true
+	}
+
+	// loopYesAuto - день, закрытый частотой, а не отметкой: не импортируется
+	switch value {
+	case loopYesManual, loopSkip:
+		return true, true
+	case loopNo:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func (a *LoopHabitsImportAdapter) findFile(files map[string]*zip.File, name string) *zip.File {
+	if f, ok := files[name]; ok {
+		return f
+	}
+	for p, f := range files {
+		if path.Base(p) == name && strings.Count(p, "/") <= 1 {
+			return f
+		}
+	}
+	return nil
+}
+
+// Папки привычек называются "001 Name"
+func (a *LoopHabitsImportAdapter) findHabitCheckmarks(files map[string]*zip.File, root, name string) *zip.File {
+	for p, f := range files {
+		if path.Base(p) != "Checkmarks.csv" || path.Dir(path.Dir(p)) != root {
+			continue
+		}
+		dir := path.Base(path.Dir(p))
+		if i := strings.Index(dir, " "); i >= 0 && dir[i+1:] == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (a *LoopHabitsImportAdapter) readCSV(f *zip.File) ([][]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open %s", f.Name)
+	}
+	defer rc.Close()
+
+	// Размер из заголовка zip может не совпадать с реальным - ограничиваем и чтение
+	maxEntrySize := viper.GetInt64("import.max_entry_size")
+	if maxEntrySize <= 0 {
+		maxEntrySize = defaultImportMaxEntrySize
+	}
+	if f.UncompressedSize64 > uint64(maxEntrySize) {
+		return nil, ErrImportTooLarge
+	}
+	limited := &io.LimitedReader{R: rc, N: maxEntrySize + 1}
+
+	reader := csv.NewReader(limited)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %s", f.Name)
+		}
+		if limited.N <= 0 {
+			return nil, ErrImportTooLarge
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
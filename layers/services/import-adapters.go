@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"strings"
+)
+
+var ErrImportTooLarge = errors.New("import file is too large")
+
+// ImportAdapter превращает файл конкретного формата (бэкап другого трекера) в ImportBundle
+type ImportAdapter interface {
+	Format() string
+	Parse(data []byte) (*domain.ImportBundle, error)
+}
+
+type ImportAdapters struct {
+	log      core.Logger
+	adapters map[string]ImportAdapter
+}
+
+func NewImportAdapters(log core.Logger, parser *TracksImportParser) *ImportAdapters {
+	r := &ImportAdapters{
+		log:      log,
+		adapters: make(map[string]ImportAdapter),
+	}
+	r.Register(&genericImportAdapter{format: ImportFormatCSV, parser: parser})
+	r.Register(&genericImportAdapter{format: ImportFormatJSON, parser: parser})
+	r.Register(NewLoopHabitsImportAdapter())
+	return r
+}
+
+func (r *ImportAdapters) Register(adapter ImportAdapter) {
+	r.adapters[adapter.Format()] = adapter
+}
+
+// nil если формат не поддерживается
+func (r *ImportAdapters) Get(format string) ImportAdapter {
+	return r.adapters[strings.ToLower(format)]
+}
+
+// Строки challenge,date,done без описания челленджей
+type genericImportAdapter struct {
+	format string
+	parser *TracksImportParser
+}
+
+func (a *genericImportAdapter) Format() string {
+	return a.format
+}
+
+func (a *genericImportAdapter) Parse(data []byte) (*domain.ImportBundle, error) {
+	rows, err := a.parser.Parse(a.format, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ImportBundle{
+		Rows: rows,
+	}, nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Доменные метрики (GET /metrics)
+var (
+	tracksMadeMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dbc_tracks_made_total",
+		Help: "Total number of track values changed by source (user, import, signal, ...) and value",
+	}, []string{"source", "done"})
+	milestonesMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dbc_series_milestones_reached_total",
+		Help: "Total number of challenge series milestones reached",
+	}, []string{"milestone"})
+	challengesFinishedMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dbc_challenges_finished_total",
+		Help: "Total number of fixed-length challenges finished by status",
+	}, []string{"status"})
+	achievementsUnlockedMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dbc_achievements_unlocked_total",
+		Help: "Total number of achievements unlocked by kind",
+	}, []string{"kind"})
+)
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"strconv"
+)
+
+// Пишет доменные события в outbox. Вызывается внутри trxManager.Do вместе с изменением данных,
+// поэтому событие появится только при коммите транзакции
+type OutboxWriter struct {
+	log  core.Logger
+	repo domain.OutboxRepository
+}
+
+func NewOutboxWriter(log core.Logger, repo domain.OutboxRepository) *OutboxWriter {
+	return &OutboxWriter{
+		log:  log,
+		repo: repo,
+	}
+}
+
+// eventType - domain.OutboxEvent*, события одного userId отправляются в порядке записи
+func (w *OutboxWriter) Add(ctx context.Context, eventType string, userId int64, event interface{}) error {
+	// Без kafka события некому отправлять - не копим их
+	if !viper.GetBool("kafka.enabled") {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %s event", eventType)
+	}
+
+	err = w.repo.Insert(ctx, &domain.OutboxEvent{
+		EventType: eventType,
+		Key:       strconv.FormatInt(userId, 10),
+		Payload:   payload,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	return nil
+}
@@ -21,6 +21,44 @@ func NewPeriodTypeProcessor(log core.Logger) *PeriodTypeProcessor {
 	}
 }
 
+// Период челленджа (every_day, если период не задан)
+func (s *PeriodTypeProcessor) ChallengePeriod(challenge *domain.DBCChallengeInfo) domain.GenerationPeriod {
+	if challenge == nil || challenge.Period.Type == "" {
+		return domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+	}
+	return challenge.Period
+}
+
+// Проверка данных периода
+func (s *PeriodTypeProcessor) Validate(period domain.GenerationPeriod) error {
+	switch period.Type {
+	case domain.PeriodTypeEveryDay:
+		return nil
+	case domain.PeriodTypeWeekDays:
+		if len(period.Data) == 0 {
+			return errors.New("week days period should contain at least one day")
+		}
+		for _, x := range period.Data {
+			if x < 0 || x > 6 {
+				return errors.Errorf("incorrect week day %d", x)
+			}
+		}
+		return nil
+	case domain.PeriodTypeMonthDates:
+		if len(period.Data) == 0 {
+			return errors.New("month dates period should contain at least one date")
+		}
+		for _, x := range period.Data {
+			if x < 1 || x > 31 {
+				return errors.Errorf("incorrect month date %d", x)
+			}
+		}
+		return nil
+	default:
+		return errors.New("Incorrect period type " + period.Type)
+	}
+}
+
 // Является ли date одним из точек периода periodType?
 func (s *PeriodTypeProcessor) IsMatch(date time.Time, period domain.GenerationPeriod) (bool, error) {
 
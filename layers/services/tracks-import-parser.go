@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/tools"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+var importDateLayouts = []string{
+	"2006-01-02",
+	"02-01-2006",
+	"2006-01-02T15:04:05.000Z",
+	time.RFC3339,
+}
+
+type TracksImportParser struct {
+	log core.Logger
+}
+
+func NewTracksImportParser(log core.Logger) *TracksImportParser {
+	return &TracksImportParser{
+		log: log,
+	}
+}
+
+func (p *TracksImportParser) Parse(format string, r io.Reader) ([]*domain.ImportTrackRow, error) {
+	switch strings.ToLower(format) {
+	case ImportFormatCSV:
+		return p.ParseCSV(r)
+	case ImportFormatJSON:
+		return p.ParseJSON(r)
+	default:
+		return nil, errors.Errorf("unknown import format %s", format)
+	}
+}
+
+// CSV с заголовком: challenge,date,done (вместо done может быть value)
+func (p *TracksImportParser) ParseCSV(r io.Reader) ([]*domain.ImportTrackRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read csv header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	challengeCol, ok := p.column(columns, "challenge", "name")
+	if !ok {
+		return nil, errors.New("csv header should contain challenge column")
+	}
+	dateCol, ok := p.column(columns, "date")
+	if !ok {
+		return nil, errors.New("csv header should contain date column")
+	}
+	valueCol, ok := p.column(columns, "done", "value")
+	if !ok {
+		return nil, errors.New("csv header should contain done or value column")
+	}
+
+	var rows []*domain.ImportTrackRow
+	line := int64(1)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read csv line %d", line)
+		}
+
+		rows = append(rows, &domain.ImportTrackRow{
+			Line:      line,
+			Challenge: p.field(record, challengeCol),
+			Date:      p.field(record, dateCol),
+			Value:     p.field(record, valueCol),
+		})
+	}
+
+	return rows, nil
+}
+
+type importJSONRow struct {
+	Challenge string   `json:"challenge"`
+	Date      string   `json:"date"`
+	Done      *bool    `json:"done"`
+	Value     *float64 `json:"value"`
+}
+
+// JSON массив: [{"challenge": "Run", "date": "2023-01-02", "done": true}, ...]
+func (p *TracksImportParser) ParseJSON(r io.Reader) ([]*domain.ImportTrackRow, error) {
+	var items []*importJSONRow
+	err := json.NewDecoder(r).Decode(&items)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode json rows")
+	}
+
+	var rows []*domain.ImportTrackRow
+	for i, item := range items {
+		row := &domain.ImportTrackRow{
+			Line:      int64(i + 1),
+			Challenge: item.Challenge,
+			Date:      item.Date,
+		}
+		switch {
+		case item.Value != nil:
+			row.Value = strconv.FormatFloat(*item.Value, 'f', -1, 64)
+		case item.Done != nil:
+			row.Value = strconv.FormatBool(*item.Done)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (p *TracksImportParser) ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range importDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return tools.RoundDateTimeToDay(t), nil
+		}
+	}
+	return time.Time{}, errors.Errorf("incorrect date %s", value)
+}
+
+// done/value: true/false, yes/no, x, или число (> 0 - выполнено)
+func (p *TracksImportParser) ParseValue(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "y", "x", "done", "+":
+		return true, nil
+	case "false", "no", "n", "", "-":
+		return false, nil
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false, errors.Errorf("incorrect value %s", value)
+	}
+	return n > 0, nil
+}
+
+func (p *TracksImportParser) column(columns map[string]int, names ...string) (int, bool) {
+	for _, name := range names {
+		if i, ok := columns[name]; ok {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (p *TracksImportParser) field(record []string, i int) string {
+	if i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
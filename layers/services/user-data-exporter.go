@@ -0,0 +1,569 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Собирает все данные пользователя и кодирует их в json архив или zip с csv на каждую таблицу
+type UserDataExporter struct {
+	log core.Logger
+
+	usersRepo          domain.UsersRepository
+	categoriesRepo     domain.DBCCategoryRepository
+	userChallengesRepo domain.DBCUserChallengeRepository
+	tracksRepo         domain.DBCTrackRepository
+	achievementsRepo   domain.AchievementsRepository
+	remindersRepo      domain.DBCRemindersRepository
+	signalRulesRepo    domain.DBCSignalRulesRepository
+
+	trackProc *DBCProcessor
+}
+
+func NewUserDataExporter(log core.Logger,
+	usersRepo domain.UsersRepository,
+	categoriesRepo domain.DBCCategoryRepository,
+	userChallengesRepo domain.DBCUserChallengeRepository,
+	tracksRepo domain.DBCTrackRepository,
+	achievementsRepo domain.AchievementsRepository,
+	remindersRepo domain.DBCRemindersRepository,
+	signalRulesRepo domain.DBCSignalRulesRepository,
+	trackProc *DBCProcessor) *UserDataExporter {
+	return &UserDataExporter{
+		log:                log,
+		usersRepo:          usersRepo,
+		categoriesRepo:     categoriesRepo,
+		userChallengesRepo: userChallengesRepo,
+		tracksRepo:         tracksRepo,
+		achievementsRepo:   achievementsRepo,
+		remindersRepo:      remindersRepo,
+		signalRulesRepo:    signalRulesRepo,
+		trackProc:          trackProc,
+	}
+}
+
+func (e *UserDataExporter) Collect(ctx context.Context, userId int64) (*domain.UserDataExport, error) {
+	user, err := e.usersRepo.FetchById(userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "usersRepo.FetchById")
+	}
+	if user == nil {
+		return nil, errors.Errorf("user %d not found", userId)
+	}
+
+	user.ScoreDaily, err = e.trackProc.CalculateDailyScore(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "CalculateDailyScore")
+	}
+
+	categories, err := e.categoriesRepo.FetchAllByUserId(userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchAllByUserId")
+	}
+
+	challenges, err := e.userChallengesRepo.UserFetchAll(userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll challenges")
+	}
+
+	// Треки архивных челленджей тоже выгружаются - челленджи должны быть в файле
+	archived, err := e.userChallengesRepo.UserFetchArchived(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchArchived")
+	}
+	challenges = append(challenges, archived...)
+
+	tracks, err := e.tracksRepo.UserFetchAll(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll tracks")
+	}
+
+	achievements, err := e.achievementsRepo.UserFetchAll(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll achievements")
+	}
+
+	reminders, err := e.remindersRepo.UserFetchAll(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll reminders")
+	}
+
+	signalRules, err := e.signalRulesRepo.UserFetchAll(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll signal rules")
+	}
+
+	return &domain.UserDataExport{
+		Version:      domain.UserDataExportVersion,
+		GeneratedAt:  time.Now().UTC(),
+		User:         user,
+		Categories:   categories,
+		Challenges:   challenges,
+		Tracks:       tracks,
+		Achievements: achievements,
+		Reminders:    reminders,
+		SignalRules:  signalRules,
+	}, nil
+}
+
+// Возвращает (данные, content type, расширение файла)
+func (e *UserDataExporter) Encode(format string, export *domain.UserDataExport) ([]byte, string, string, error) {
+	switch format {
+	case domain.ExportFormatJSON:
+		data, err := e.EncodeJSON(export)
+		return data, "application/json", "json", err
+	case domain.ExportFormatCSV:
+		data, err := e.EncodeCSV(export)
+		return data, "application/zip", "zip", err
+	default:
+		return nil, "", "", errors.Errorf("unknown export format %s", format)
+	}
+}
+
+//
+// JSON
+//
+
+type exportJSON struct {
+	Version      int64                   `json:"version"`
+	GeneratedAt  time.Time               `json:"generated_at"`
+	User         exportUserJSON          `json:"user"`
+	Categories   []exportCategoryJSON    `json:"categories"`
+	Challenges   []exportChallengeJSON   `json:"challenges"`
+	Tracks       []exportTrackJSON       `json:"tracks"`
+	Achievements []exportAchievementJSON `json:"achievements"`
+	Reminders    []exportReminderJSON    `json:"reminders"`
+	SignalRules  []exportSignalRuleJSON  `json:"signal_rules"`
+}
+
+type exportUserJSON struct {
+	Id                  int64      `json:"id"`
+	Score               int64      `json:"score"`
+	ScoreDaily          int64      `json:"score_daily"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	DeletedAt           *time.Time `json:"deleted_at"`
+}
+
+type exportCategoryJSON struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type exportChallengeJSON struct {
+	Id              int64      `json:"id"`
+	ChallengeId     int64      `json:"challenge_id"`
+	IsOwner         bool       `json:"is_owner"`
+	Name            string     `json:"name"`
+	Desc            *string    `json:"desc"`
+	Image           *string    `json:"image"`
+	CategoryId      *int64     `json:"category_id"`
+	IsAutoTrack     bool       `json:"is_auto_track"`
+	VisibilityType  string     `json:"visibility_type"`
+	PeriodType      string     `json:"period_type"`
+	PeriodData      []int      `json:"period_data"`
+	EditWindow      *int64     `json:"edit_window"`
+	StartDate       *string    `json:"start_date"`
+	EndDate         *string    `json:"end_date"`
+	DurationDays    *int64     `json:"duration_days"`
+	Status          string     `json:"status"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	LastSeries      int64      `json:"last_series"`
+	BestSeries      int64      `json:"best_series"`
+	BestSeriesStart *string    `json:"best_series_start"`
+	BestSeriesEnd   *string    `json:"best_series_end"`
+	Archived        bool       `json:"archived"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at"`
+}
+
+type exportTrackJSON struct {
+	Id              int64   `json:"id"`
+	ChallengeUserId int64   `json:"challenge_user_id"`
+	Date            string  `json:"date"`
+	Done            bool    `json:"done"`
+	LastSeries      int64   `json:"last_series"`
+	Score           int64   `json:"score"`
+	ScoreDaily      int64   `json:"score_daily"`
+	Note            *string `json:"note"`
+	Mood            *int64  `json:"mood"`
+}
+
+type exportReminderJSON struct {
+	Id              int64     `json:"id"`
+	ChallengeUserId int64     `json:"challenge_user_id"`
+	Time            string    `json:"time"`
+	Timezone        string    `json:"timezone"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type exportSignalRuleJSON struct {
+	Id              int64     `json:"id"`
+	ChallengeUserId int64     `json:"challenge_user_id"`
+	Source          string    `json:"source"`
+	EventType       string    `json:"event_type"`
+	Threshold       float64   `json:"threshold"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type exportAchievementJSON struct {
+	Id          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Desc        string    `json:"desc"`
+	TriggerName string    `json:"trigger_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (e *UserDataExporter) EncodeJSON(export *domain.UserDataExport) ([]byte, error) {
+	result := exportJSON{
+		Version:     export.Version,
+		GeneratedAt: export.GeneratedAt,
+		User: exportUserJSON{
+			Id:                  export.User.Id,
+			Score:               export.User.Score,
+			ScoreDaily:          export.User.ScoreDaily,
+			DeletionScheduledAt: export.User.DeletionScheduledAt,
+			CreatedAt:           export.User.CreatedAt,
+			UpdatedAt:           export.User.UpdatedAt,
+			DeletedAt:           export.User.DeletedAt,
+		},
+		Categories:   []exportCategoryJSON{},
+		Challenges:   []exportChallengeJSON{},
+		Tracks:       []exportTrackJSON{},
+		Achievements: []exportAchievementJSON{},
+		Reminders:    []exportReminderJSON{},
+		SignalRules:  []exportSignalRuleJSON{},
+	}
+
+	for _, item := range export.Categories {
+		result.Categories = append(result.Categories, exportCategoryJSON{
+			Id:        item.Id,
+			Name:      item.Name,
+			CreatedAt: item.CreatedAt,
+			UpdatedAt: item.UpdatedAt,
+			DeletedAt: item.DeletedAt,
+		})
+	}
+
+	for _, item := range export.Challenges {
+		result.Challenges = append(result.Challenges, e.challengeJSON(export.User.Id, item))
+	}
+
+	for _, item := range export.Tracks {
+		result.Tracks = append(result.Tracks, exportTrackJSON{
+			Id:              item.Id,
+			ChallengeUserId: item.ChallengeUserId,
+			Date:            item.Date.Format("2006-01-02"),
+			Done:            item.Done,
+			LastSeries:      item.LastSeries,
+			Score:           item.Score,
+			ScoreDaily:      item.ScoreDaily,
+			Note:            item.Note,
+			Mood:            item.Mood,
+		})
+	}
+
+	for _, item := range export.Achievements {
+		result.Achievements = append(result.Achievements, exportAchievementJSON{
+			Id:          item.Id,
+			Title:       item.Title,
+			Desc:        item.Desc,
+			TriggerName: item.TriggerName,
+			CreatedAt:   item.CreatedAt,
+		})
+	}
+
+	for _, item := range export.Reminders {
+		result.Reminders = append(result.Reminders, e.reminderJSON(item))
+	}
+
+	for _, item := range export.SignalRules {
+		result.SignalRules = append(result.SignalRules, exportSignalRuleJSON{
+			Id:              item.Id,
+			ChallengeUserId: item.ChallengeUserId,
+			Source:          item.Source,
+			EventType:       item.EventType,
+			Threshold:       item.Threshold,
+			CreatedAt:       item.CreatedAt,
+		})
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "MarshalIndent")
+	}
+	return data, nil
+}
+
+func (e *UserDataExporter) challengeJSON(userId int64, item *domain.DBCUserChallenge) exportChallengeJSON {
+	result := exportChallengeJSON{
+		Id:              item.Id,
+		ChallengeId:     item.ChallengeInfoId,
+		PeriodData:      []int{},
+		StartDate:       e.formatNullableDate(item.StartDate),
+		EndDate:         e.formatNullableDate(item.EndDate),
+		Status:          item.Status,
+		FinishedAt:      item.FinishedAt,
+		LastSeries:      item.LastSeries,
+		BestSeries:      item.BestSeries,
+		BestSeriesStart: e.formatNullableDate(item.BestSeriesStart),
+		BestSeriesEnd:   e.formatNullableDate(item.BestSeriesEnd),
+		Archived:        item.DeletedAt != nil,
+		CreatedAt:       item.CreatedAt,
+		UpdatedAt:       item.UpdatedAt,
+		DeletedAt:       item.DeletedAt,
+	}
+	if info := item.ChallengeInfo; info != nil {
+		result.IsOwner = info.OwnerId == userId
+		result.Name = info.Name
+		result.Desc = info.Desc
+		result.Image = info.Image
+		result.CategoryId = info.CategoryId
+		if result.CategoryId == nil && info.Category != nil {
+			result.CategoryId = &info.Category.Id
+		}
+		result.IsAutoTrack = info.IsAutoTrack
+		result.VisibilityType = info.VisibilityType
+		result.PeriodType = info.Period.Type
+		if info.Period.Data != nil {
+			result.PeriodData = info.Period.Data
+		}
+		result.EditWindow = info.EditWindow
+		result.DurationDays = info.DurationDays
+	}
+	return result
+}
+
+func (e *UserDataExporter) reminderJSON(item *domain.DBCReminder) exportReminderJSON {
+	return exportReminderJSON{
+		Id:              item.Id,
+		ChallengeUserId: item.ChallengeUserId,
+		Time:            fmt.Sprintf("%02d:%02d", item.Minute/60, item.Minute%60),
+		Timezone:        item.Timezone,
+		CreatedAt:       item.CreatedAt,
+	}
+}
+
+//
+// CSV (zip, файл на каждую таблицу)
+//
+
+func (e *UserDataExporter) EncodeCSV(export *domain.UserDataExport) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+
+	user := export.User
+	files := []struct {
+		name    string
+		records [][]string
+	}{
+		{"meta.csv", [][]string{
+			{"version", "generated_at"},
+			{e.formatInt(export.Version), e.formatTime(export.GeneratedAt)},
+		}},
+		{"user.csv", [][]string{
+			{"id", "score", "score_daily", "deletion_scheduled_at", "created_at", "updated_at", "deleted_at"},
+			{e.formatInt(user.Id), e.formatInt(user.Score), e.formatInt(user.ScoreDaily), e.formatNullableTime(user.DeletionScheduledAt),
+				e.formatTime(user.CreatedAt), e.formatTime(user.UpdatedAt), e.formatNullableTime(user.DeletedAt)},
+		}},
+		{"categories.csv", e.categoriesCSV(export.Categories)},
+		{"challenges.csv", e.challengesCSV(user.Id, export.Challenges)},
+		{"tracks.csv", e.tracksCSV(export.Tracks)},
+		{"achievements.csv", e.achievementsCSV(export.Achievements)},
+		{"reminders.csv", e.remindersCSV(export.Reminders)},
+		{"signal_rules.csv", e.signalRulesCSV(export.SignalRules)},
+	}
+
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create %s", file.name)
+		}
+		err = csv.NewWriter(w).WriteAll(file.records)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot write %s", file.name)
+		}
+	}
+
+	err := archive.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot close zip")
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *UserDataExporter) categoriesCSV(items []*domain.DBCCategory) [][]string {
+	records := [][]string{{"id", "name", "created_at", "updated_at", "deleted_at"}}
+	for _, item := range items {
+		records = append(records, []string{
+			e.formatInt(item.Id),
+			item.Name,
+			e.formatTime(item.CreatedAt),
+			e.formatTime(item.UpdatedAt),
+			e.formatNullableTime(item.DeletedAt),
+		})
+	}
+	return records
+}
+
+func (e *UserDataExporter) challengesCSV(userId int64, items []*domain.DBCUserChallenge) [][]string {
+	records := [][]string{{"id", "challenge_id", "is_owner", "name", "desc", "image", "category_id", "is_auto_track",
+		"visibility_type", "period_type", "period_data", "edit_window", "start_date", "end_date", "duration_days",
+		"status", "finished_at", "last_series", "best_series", "best_series_start", "best_series_end", "archived",
+		"created_at", "updated_at", "deleted_at"}}
+	for _, item := range items {
+		c := e.challengeJSON(userId, item)
+
+		periodData := make([]string, 0, len(c.PeriodData))
+		for _, x := range c.PeriodData {
+			periodData = append(periodData, strconv.Itoa(x))
+		}
+		categoryId := ""
+		if c.CategoryId != nil {
+			categoryId = e.formatInt(*c.CategoryId)
+		}
+
+		records = append(records, []string{
+			e.formatInt(c.Id),
+			e.formatInt(c.ChallengeId),
+			strconv.FormatBool(c.IsOwner),
+			c.Name,
+			e.formatNullableString(c.Desc),
+			e.formatNullableString(c.Image),
+			categoryId,
+			strconv.FormatBool(c.IsAutoTrack),
+			c.VisibilityType,
+			c.PeriodType,
+			strings.Join(periodData, " "),
+			e.formatNullableInt(c.EditWindow),
+			e.formatNullableString(c.StartDate),
+			e.formatNullableString(c.EndDate),
+			e.formatNullableInt(c.DurationDays),
+			c.Status,
+			e.formatNullableTime(c.FinishedAt),
+			e.formatInt(c.LastSeries),
+			e.formatInt(c.BestSeries),
+			e.formatNullableString(c.BestSeriesStart),
+			e.formatNullableString(c.BestSeriesEnd),
+			strconv.FormatBool(c.Archived),
+			e.formatTime(c.CreatedAt),
+			e.formatTime(c.UpdatedAt),
+			e.formatNullableTime(c.DeletedAt),
+		})
+	}
+	return records
+}
+
+func (e *UserDataExporter) tracksCSV(items []*domain.DBCTrack) [][]string {
+	records := [][]string{{"id", "challenge_user_id", "date", "done", "last_series", "score", "score_daily", "note", "mood"}}
+	for _, item := range items {
+		records = append(records, []string{
+			e.formatInt(item.Id),
+			e.formatInt(item.ChallengeUserId),
+			item.Date.Format("2006-01-02"),
+			strconv.FormatBool(item.Done),
+			e.formatInt(item.LastSeries),
+			e.formatInt(item.Score),
+			e.formatInt(item.ScoreDaily),
+			e.formatNullableString(item.Note),
+			e.formatNullableInt(item.Mood),
+		})
+	}
+	return records
+}
+
+func (e *UserDataExporter) achievementsCSV(items []*domain.Achievement) [][]string {
+	records := [][]string{{"id", "title", "desc", "trigger_name", "created_at"}}
+	for _, item := range items {
+		records = append(records, []string{
+			e.formatInt(item.Id),
+			item.Title,
+			item.Desc,
+			item.TriggerName,
+			e.formatTime(item.CreatedAt),
+		})
+	}
+	return records
+}
+
+func (e *UserDataExporter) remindersCSV(items []*domain.DBCReminder) [][]string {
+	records := [][]string{{"id", "challenge_user_id", "time", "timezone", "created_at"}}
+	for _, item := range items {
+		r := e.reminderJSON(item)
+		records = append(records, []string{
+			e.formatInt(r.Id),
+			e.formatInt(r.ChallengeUserId),
+			r.Time,
+			r.Timezone,
+			e.formatTime(r.CreatedAt),
+		})
+	}
+	return records
+}
+
+func (e *UserDataExporter) signalRulesCSV(items []*domain.DBCSignalRule) [][]string {
+	records := [][]string{{"id", "challenge_user_id", "source", "event_type", "threshold", "created_at"}}
+	for _, item := range items {
+		records = append(records, []string{
+			e.formatInt(item.Id),
+			e.formatInt(item.ChallengeUserId),
+			item.Source,
+			item.EventType,
+			strconv.FormatFloat(item.Threshold, 'f', -1, 64),
+			e.formatTime(item.CreatedAt),
+		})
+	}
+	return records
+}
+
+func (e *UserDataExporter) formatInt(x int64) string {
+	return strconv.FormatInt(x, 10)
+}
+
+func (e *UserDataExporter) formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (e *UserDataExporter) formatNullableTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return e.formatTime(*t)
+}
+
+func (e *UserDataExporter) formatNullableDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	date := t.Format("2006-01-02")
+	return &date
+}
+
+func (e *UserDataExporter) formatNullableString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (e *UserDataExporter) formatNullableInt(x *int64) string {
+	if x == nil {
+		return ""
+	}
+	return e.formatInt(*x)
+}
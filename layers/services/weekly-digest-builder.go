@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/tools"
+	"time"
+)
+
+// Собирает итоги недели пользователя по его активным челленджам
+type WeeklyDigestBuilder struct {
+	log core.Logger
+
+	challengeUserRepository domain.DBCUserChallengeRepository
+	trackRepository         domain.DBCTrackRepository
+	achievementsRepository  domain.AchievementsRepository
+
+	periodProc *PeriodTypeProcessor
+	dbcProc    *DBCProcessor
+}
+
+func NewWeeklyDigestBuilder(log core.Logger,
+	challengeUserRepository domain.DBCUserChallengeRepository,
+	trackRepository domain.DBCTrackRepository,
+	achievementsRepository domain.AchievementsRepository,
+	periodProc *PeriodTypeProcessor,
+	dbcProc *DBCProcessor) *WeeklyDigestBuilder {
+	return &WeeklyDigestBuilder{
+		log:                     log,
+		challengeUserRepository: challengeUserRepository,
+		trackRepository:         trackRepository,
+		achievementsRepository:  achievementsRepository,
+		periodProc:              periodProc,
+		dbcProc:                 dbcProc,
+	}
+}
+
+// nil - у пользователя нет челленджей
+func (s *WeeklyDigestBuilder) Build(ctx context.Context, userId int64, weekStart time.Time) (*domain.WeeklyDigest, error) {
+	weekStart = tools.RoundDateTimeToWeek(weekStart)
+	weekEnd := weekStart.AddDate(0, 0, 6)
+
+	challenges, err := s.challengeUserRepository.UserFetchAll(userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll")
+	}
+	if len(challenges) == 0 {
+		return nil, nil
+	}
+
+	digest := &domain.WeeklyDigest{
+		UserId:       userId,
+		WeekStart:    weekStart,
+		WeekEnd:      weekEnd,
+		Challenges:   []*domain.WeeklyDigestChallenge{},
+		Achievements: []*domain.WeeklyDigestAchievement{},
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	doneByDay := make(map[time.Time]int64)
+	for _, challenge := range challenges {
+		item, err := s.buildChallenge(ctx, challenge, weekStart, weekEnd, digest, doneByDay)
+		if err != nil {
+			return nil, errors.Wrapf(err, "buildChallenge %d", challenge.Id)
+		}
+		if item != nil {
+			digest.Challenges = append(digest.Challenges, item)
+		}
+	}
+
+	for day, count := range doneByDay {
+		if digest.BestDay == nil || count > digest.BestDay.DoneCount ||
+			(count == digest.BestDay.DoneCount && day.Before(digest.BestDay.Date)) {
+			digest.BestDay = &domain.WeeklyDigestDay{Date: day, DoneCount: count}
+		}
+	}
+
+	achievements, err := s.achievementsRepository.UserFetchAll(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserFetchAll achievements")
+	}
+	for _, achievement := range achievements {
+		createdAt := tools.RoundDateTimeToDay(achievement.CreatedAt)
+		if createdAt.Before(weekStart) || createdAt.After(weekEnd) {
+			continue
+		}
+		digest.Achievements = append(digest.Achievements, &domain.WeeklyDigestAchievement{
+			Id:    achievement.Id,
+			Title: achievement.Title,
+			Desc:  achievement.Desc,
+		})
+	}
+
+	return digest, nil
+}
+
+// nil - челлендж не активен на этой неделе
+func (s *WeeklyDigestBuilder) buildChallenge(ctx context.Context,
+	challenge *domain.DBCUserChallenge,
+	weekStart, weekEnd time.Time,
+	digest *domain.WeeklyDigest,
+	doneByDay map[time.Time]int64) (*domain.WeeklyDigestChallenge, error) {
+
+	period := s.periodProc.ChallengePeriod(challenge.ChallengeInfo)
+	joinedAt := tools.RoundDateTimeToDay(challenge.CreatedAt)
+
+	// Точки периода недели, в которые челлендж уже был у пользователя
+	total := int64(0)
+	for day := weekStart; !day.After(weekEnd); day = day.AddDate(0, 0, 1) {
+		if day.Before(joinedAt) || !s.dbcProc.IsInChallengeDates(challenge, day) {
+			continue
+		}
+		match, err := s.periodProc.IsMatch(day, period)
+		if err != nil {
+			return nil, errors.Wrap(err, "IsMatch")
+		}
+		if match {
+			total++
+		}
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	tracks, err := s.trackRepository.ChallengeFetchBetween(ctx, challenge.Id, weekStart, weekEnd)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeFetchBetween")
+	}
+
+	item := &domain.WeeklyDigestChallenge{
+		ChallengeId: challenge.Id,
+		Name:        challenge.ChallengeInfo.Name,
+		TotalCount:  total,
+	}
+
+	before, err := s.trackRepository.ChallengeFetchLastBefore(ctx, challenge.Id, weekStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeFetchLastBefore")
+	}
+	if before != nil {
+		item.SeriesStart = before.LastSeries
+	}
+	item.SeriesEnd = item.SeriesStart
+
+	for _, track := range tracks {
+		digest.ScoreDelta += track.ScoreDaily
+		item.SeriesEnd = track.LastSeries
+		if track.Done {
+			item.DoneCount++
+			doneByDay[tools.RoundDateTimeToDay(track.Date)]++
+		}
+	}
+	item.CompletionRate = float64(item.DoneCount) / float64(item.TotalCount)
+
+	return item, nil
+}
@@ -2,45 +2,87 @@ package usecase
 
 import (
 	"context"
+	"fmt"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/viper"
+	"math"
+	"microservice/app"
 	"microservice/app/core"
+	"microservice/app/tracing"
 	"microservice/layers/domain"
 	"microservice/layers/services"
 	"microservice/tools"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+const (
+	defaultTrackNoteMaxLength = 2000
+	trackMoodMin              = 1
+	trackMoodMax              = 5
+	defaultTrackNotesLimit    = 20
+	maxTrackNotesLimit        = 100
+	defaultTrackHistoryLimit  = 50
+	maxTrackHistoryLimit      = 500
+	maxChallengeDurationDays  = 366
+	defaultTracksMaxRangeDays = 366
+	maxCategoryStatsWindows   = 5
+)
+
+// Окна статистики категорий по умолчанию (в днях)
+var defaultCategoryStatsWindows = []int64{7, 30, 90}
+
 type ChallengesUseCase struct {
-	log core.Logger
+	log        core.Logger
+	trxManager *manager.Manager
+	blobStore  app.BlobStore
 
 	usersRepo          domain.UsersRepository
 	categoryRepo       domain.DBCCategoryRepository
 	userChallengesRepo domain.DBCUserChallengeRepository
 	challengesRepo     domain.DBChallengeInfoRepository
 	tracksRepo         domain.DBCTrackRepository
+	trackHistoryRepo   domain.DBCTrackHistoryRepository
 
 	periodTypeGenerator *services.PeriodTypeProcessor
 	trackProcessor      *services.DBCProcessor
+	imageProcessor      *services.ImageProcessor
+	idempotencyStore    *services.IdempotencyStore
 }
 
 func NewChallengesUseCase(log core.Logger,
+	trxManager *manager.Manager,
 	usersRepo domain.UsersRepository,
 	projectsRepo domain.DBCCategoryRepository,
 	periodTypeGenerator *services.PeriodTypeProcessor,
 	userChallengesRepo domain.DBCUserChallengeRepository,
 	tracksRepo domain.DBCTrackRepository,
+	trackHistoryRepo domain.DBCTrackHistoryRepository,
 	challengesRepo domain.DBChallengeInfoRepository,
-	trackProcessor *services.DBCProcessor) *ChallengesUseCase {
+	trackProcessor *services.DBCProcessor,
+	imageProcessor *services.ImageProcessor,
+	idempotencyStore *services.IdempotencyStore,
+	blobStore app.BlobStore) *ChallengesUseCase {
 	return &ChallengesUseCase{
 		log:                 log,
+		trxManager:          trxManager,
+		blobStore:           blobStore,
 		usersRepo:           usersRepo,
 		categoryRepo:        projectsRepo,
 		challengesRepo:      challengesRepo,
 		userChallengesRepo:  userChallengesRepo,
 		tracksRepo:          tracksRepo,
+		trackHistoryRepo:    trackHistoryRepo,
 		periodTypeGenerator: periodTypeGenerator,
 		trackProcessor:      trackProcessor,
+		imageProcessor:      imageProcessor,
+		idempotencyStore:    idempotencyStore,
 	}
 }
 
@@ -77,13 +119,11 @@ func (ucase *ChallengesUseCase) UserAll(userId int64) (domain.UserChallengesList
 			continue
 		}
 
-		// ToDo: Период генерации треков (на данный момент он равен 1 суток без возможности изменения)
-		period := domain.GenerationPeriod{
-			Type: domain.PeriodTypeEveryDay,
-		}
+		// Период генерации треков
+		period := ucase.periodTypeGenerator.ChallengePeriod(item.ChallengeInfo)
 
-		// Отскочить на 3 последних треков (учитывая период их генерации)
-		list, err := ucase.periodTypeGenerator.BackwardList(time.Now(), period, 3)
+		// Отскочить на последние треки в окне редактирования (учитывая период их генерации)
+		list, err := ucase.periodTypeGenerator.BackwardList(time.Now(), period, uint(ucase.trackProcessor.EditWindow(item.ChallengeInfo)))
 		if err != nil {
 			return domain.UserChallengesListResponse{}, errors.Wrap(err, "UserAll")
 		}
@@ -128,39 +168,13 @@ func (ucase *ChallengesUseCase) UserAll(userId int64) (domain.UserChallengesList
 	}, nil
 }
 
-func (ucase *ChallengesUseCase) UserCreate(form *domain.CreateDBCChallengeForm) (domain.CreateChallengeResponse, error) {
-
+func (ucase *ChallengesUseCase) UserCreate(ctx context.Context, form *domain.CreateDBCChallengeForm) (domain.CreateChallengeResponse, error) {
 	//
 	err := ucase.usersRepo.InsertIfNotExists(&domain.User{Id: form.UserId})
 	if err != nil {
 		return domain.CreateChallengeResponse{}, errors.Wrap(err, "UserCreate")
 	}
 
-	// Is challenge connected to category?
-	var categoryId *int64
-	if form.CategoryName != nil {
-		// Finding category
-		category, err := ucase.categoryRepo.FetchByName(form.UserId, *form.CategoryName)
-		if err != nil {
-			return domain.CreateChallengeResponse{}, errors.Wrap(err, "cannot fetch category before creating task")
-		}
-
-		// Creating if not exists
-		if category == nil {
-			category = &domain.DBCCategory{
-				UserId: form.UserId,
-				Name:   *form.CategoryName,
-			}
-			err = ucase.categoryRepo.Insert(category)
-			if err != nil {
-				return domain.CreateChallengeResponse{}, errors.Wrap(err, "cannot insert new category before creating task")
-			}
-		}
-
-		// Set category Id for next step
-		categoryId = &category.Id
-	}
-
 	// Check if challenge with same name already exists
 	form.Name = strings.TrimSpace(form.Name)
 	challengeFound, err := ucase.userChallengesRepo.UserFetchByName(form.UserId, form.Name)
@@ -174,35 +188,73 @@ func (ucase *ChallengesUseCase) UserCreate(form *domain.CreateDBCChallengeForm)
 	}
 
 	// Validation of challenge form
-	if form.Name == "" {
+	if form.Name == "" || !isValidEditWindow(form.EditWindow) || !isValidChallengeDates(form) {
 		return domain.CreateChallengeResponse{
 			StatusCode: domain.ValidationError,
 		}, nil
 	}
 
-	// Creating challenge
-	challengeInfo := &domain.DBCChallengeInfo{
-		OwnerId:        form.UserId,
-		IsAutoTrack:    form.IsAutoTrack,
-		VisibilityType: "private",
-		CategoryId:     categoryId,
-		Name:           form.Name,
-		Desc:           form.Desc,
-		Image:          nil,
-	}
-	err = ucase.challengesRepo.Insert(challengeInfo)
-	if err != nil {
-		return domain.CreateChallengeResponse{}, errors.Wrap(err, "challengesRepo.Insert")
-	}
-
-	// Binding challenge with user
+	// Категория, челлендж и привязка к пользователю создаются вместе - без сирот при ошибке
+	var categoryId *int64
 	challengeUser := &domain.DBCUserChallenge{
-		ChallengeInfo: &domain.DBCChallengeInfo{Id: challengeInfo.Id},
-		UserId:        form.UserId,
+		UserId: form.UserId,
+		Status: domain.ChallengeStatusActive,
 	}
-	err = ucase.userChallengesRepo.Insert(challengeUser)
+	err = ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		// Is challenge connected to category?
+		if form.CategoryName != nil {
+			// Finding category
+			category, err := ucase.categoryRepo.FetchByName(ctx, form.UserId, *form.CategoryName)
+			if err != nil {
+				return errors.Wrap(err, "cannot fetch category before creating task")
+			}
+
+			// Creating if not exists
+			if category == nil {
+				category = &domain.DBCCategory{
+					UserId: form.UserId,
+					Name:   *form.CategoryName,
+				}
+				err = ucase.categoryRepo.Insert(ctx, category)
+				if err != nil {
+					return errors.Wrap(err, "cannot insert new category before creating task")
+				}
+			}
+
+			// Set category Id for next step
+			categoryId = &category.Id
+		}
+
+		// Creating challenge
+		challengeInfo := &domain.DBCChallengeInfo{
+			OwnerId:        form.UserId,
+			IsAutoTrack:    form.IsAutoTrack,
+			VisibilityType: "private",
+			CategoryId:     categoryId,
+			Name:           form.Name,
+			Desc:           form.Desc,
+			Image:          nil,
+			EditWindow:     form.EditWindow,
+			StartDate:      form.StartDate,
+			EndDate:        form.EndDate,
+			DurationDays:   form.DurationDays,
+		}
+		err := ucase.challengesRepo.Insert(ctx, challengeInfo)
+		if err != nil {
+			return errors.Wrap(err, "challengesRepo.Insert")
+		}
+
+		// Binding challenge with user
+		challengeUser.ChallengeInfo = &domain.DBCChallengeInfo{Id: challengeInfo.Id}
+		challengeUser.StartDate, challengeUser.EndDate = ucase.trackProcessor.ChallengeDates(challengeInfo, time.Now())
+		err = ucase.userChallengesRepo.Insert(ctx, challengeUser)
+		if err != nil {
+			return errors.Wrap(err, "userChallengesRepo.Insert")
+		}
+		return nil
+	})
 	if err != nil {
-		return domain.CreateChallengeResponse{}, errors.Wrap(err, "userChallengesRepo.Insert")
+		return domain.CreateChallengeResponse{}, errors.Wrap(err, "UserCreate")
 	}
 
 	//
@@ -232,48 +284,288 @@ func (ucase *ChallengesUseCase) Update(ctx context.Context, challenge *domain.DB
 	}, nil
 }
 
-func (ucase *ChallengesUseCase) Remove(userId, taskId int64) (domain.StatusResponse, error) {
-
-	//task, err := ucase.userChallengesRepo.FetchById(taskId)
-	//if err != nil {
-	//	return domain.StatusResponse{}, errors.Wrapf(err, "cannot fetch task by id %d", taskId)
-	//}
-	//
-	//if task.UserId != userId {
-	//	return domain.StatusResponse{
-	//		StatusCode: domain.AccessDenied,
-	//	}, nil
-	//}
-	//
-	//err = ucase.userChallengesRepo.Remove(task.Id)
-	//if err != nil {
-	//	return domain.StatusResponse{}, errors.Wrap(err, "cannot remove task")
-	//}
+// Архивные челленджи пользователя
+func (ucase *ChallengesUseCase) UserArchived(ctx context.Context, userId int64) (domain.UserChallengesListResponse, error) {
+	items, err := ucase.userChallengesRepo.UserFetchArchived(ctx, userId)
+	if err != nil {
+		return domain.UserChallengesListResponse{}, errors.Wrap(err, "UserFetchArchived")
+	}
 
-	return domain.StatusResponse{
-		StatusCode: domain.Success,
+	return domain.UserChallengesListResponse{
+		StatusCode:     domain.Success,
+		UserChallenges: items,
 	}, nil
 }
 
-func (ucase *ChallengesUseCase) TrackDay(ctx context.Context, form *domain.DBCTrack) (domain.UserGamifyResponse, error) {
+func (ucase *ChallengesUseCase) Remove(ctx context.Context, userId, challengeId int64) (domain.StatusResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId || challenge.DeletedAt != nil {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	err = ucase.userChallengesRepo.Remove(challenge.Id)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "Remove")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
 
-	status, err := ucase.trackProcessor.MakeTrack(ctx, form.ChallengeId, form.Date, form.Done)
+func (ucase *ChallengesUseCase) Restore(ctx context.Context, userId, challengeId int64) (domain.StatusResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId || challenge.DeletedAt == nil {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	err = ucase.userChallengesRepo.Restore(ctx, challenge.Id)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "Restore")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+// Удаляет участие пользователя вместе с треками.
+// Сам челлендж удаляется, только если его удаляет владелец и других участников не осталось
+func (ucase *ChallengesUseCase) Delete(ctx context.Context, userId, challengeId int64) (domain.StatusResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	// Челлендж владельца удаляется вместе с участием, если в нем никого не осталось
+	deleted := false
+	err = ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		err := ucase.userChallengesRepo.Delete(ctx, challenge.Id)
+		if err != nil {
+			return errors.Wrap(err, "Delete")
+		}
+
+		if challenge.ChallengeInfo.OwnerId != userId {
+			return nil
+		}
+
+		deleted, err = ucase.challengesRepo.DeleteIfNoMembers(ctx, challenge.ChallengeInfoId)
+		if err != nil {
+			return errors.Wrap(err, "DeleteIfNoMembers")
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "trxManager")
+	}
+
+	// Изображение больше не нужно - удаляем после коммита (если не удалится - его уберет джоба очистки)
+	if deleted && challenge.ChallengeInfo.Image != nil {
+		for _, key := range []string{*challenge.ChallengeInfo.Image, services.ThumbnailKey(*challenge.ChallengeInfo.Image)} {
+			err = ucase.blobStore.Remove(key)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot remove image %s", key)
+			}
+		}
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+// Окно редактирования челленджа. Менять его может только владелец
+func (ucase *ChallengesUseCase) SetEditWindow(ctx context.Context, form *domain.SetChallengeEditWindowForm) (domain.StatusResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != form.UserId {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+	if challenge.ChallengeInfo.OwnerId != form.UserId {
+		return domain.StatusResponse{StatusCode: domain.AccessDenied}, nil
+	}
+
+	if !isValidEditWindow(form.EditWindow) {
+		return domain.StatusResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	err = ucase.challengesRepo.UpdateEditWindow(ctx, challenge.ChallengeInfoId, form.EditWindow)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "UpdateEditWindow")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+// Порядок меняется целиком: ids должен содержать все активные челленджи пользователя
+func (ucase *ChallengesUseCase) Reorder(ctx context.Context, userId int64, ids []int64) (domain.StatusResponse, error) {
+	items, err := ucase.userChallengesRepo.UserFetchAll(userId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "UserFetchAll")
+	}
+	if len(ids) != len(items) {
+		return domain.StatusResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	active := make(map[int64]bool, len(items))
+	for _, item := range items {
+		active[item.Id] = true
+	}
+	for _, id := range ids {
+		if !active[id] {
+			return domain.StatusResponse{StatusCode: domain.ValidationError}, nil
+		}
+		// Повтор id
+		delete(active, id)
+	}
+
+	err = ucase.userChallengesRepo.Reorder(ctx, userId, ids)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "Reorder")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+func (ucase *ChallengesUseCase) SetPinned(ctx context.Context, userId, challengeId int64, pinned bool) (domain.StatusResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId || challenge.DeletedAt != nil {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	err = ucase.userChallengesRepo.SetPinned(ctx, challenge.Id, pinned)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "SetPinned")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+// Срок задается датой окончания или длительностью, но не обоими сразу
+func isValidChallengeDates(form *domain.CreateDBCChallengeForm) bool {
+	if form.EndDate != nil && form.DurationDays != nil {
+		return false
+	}
+	if form.DurationDays != nil && (*form.DurationDays < 1 || *form.DurationDays > maxChallengeDurationDays) {
+		return false
+	}
+	if form.EndDate != nil {
+		startDate := tools.RoundDateTimeToDay(time.Now().UTC())
+		if form.StartDate != nil {
+			startDate = tools.RoundDateTimeToDay(form.StartDate.UTC())
+		}
+		endDate := tools.RoundDateTimeToDay(form.EndDate.UTC())
+		if endDate.Before(startDate) || endDate.Sub(startDate) >= maxChallengeDurationDays*24*time.Hour {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidEditWindow(editWindow *int64) bool {
+	return editWindow == nil || (*editWindow >= 1 && *editWindow <= services.MaxEditWindow)
+}
+
+// Отметка дня. С ключом идемпотентности повтор запроса вернет сохраненный ответ без пересчета
+func (ucase *ChallengesUseCase) TrackDay(ctx context.Context, form *domain.DBCTrack, idempotencyKey string) (_ domain.UserGamifyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.TrackDay")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if idempotencyKey == "" {
+		return ucase.trackDay(ctx, form)
+	}
+
+	response := domain.UserGamifyResponse{}
+	err = ucase.idempotencyStore.Do("track_day", form.UserId, idempotencyKey, trackDayFingerprint(form), &response,
+		func() (interface{}, error) {
+			return ucase.trackDay(ctx, form)
+		})
+	switch {
+	case errors.Is(err, services.ErrIdempotencyKeyReused), errors.Is(err, services.ErrIdempotencyKeyInvalid):
+		return domain.UserGamifyResponse{
+			StatusCode: domain.ValidationError,
+		}, nil
+	case err != nil:
+		return domain.UserGamifyResponse{}, errors.Wrap(err, "idempotencyStore.Do")
+	}
+
+	return response, nil
+}
+
+func trackDayFingerprint(form *domain.DBCTrack) string {
+	fingerprint := fmt.Sprintf("%d:%s:%t", form.ChallengeId, form.Date.Format("2006-01-02"), form.Done)
+	if form.Note != nil {
+		fingerprint += ":n=" + *form.Note
+	}
+	if form.Mood != nil {
+		fingerprint += fmt.Sprintf(":m=%d", *form.Mood)
+	}
+	return fingerprint
+}
+
+func (ucase *ChallengesUseCase) trackDay(ctx context.Context, form *domain.DBCTrack) (domain.UserGamifyResponse, error) {
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	if err != nil {
+		return domain.UserGamifyResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != form.UserId || challenge.DeletedAt != nil {
+		return domain.UserGamifyResponse{
+			StatusCode: domain.NotFound,
+		}, nil
+	}
+
+	if !ucase.isValidNote(form.Note, form.Mood) {
+		return domain.UserGamifyResponse{
+			StatusCode: domain.ValidationError,
+		}, nil
+	}
+
+	status, err := ucase.trackProcessor.MakeTrack(ctx, form.ChallengeId, form.Date, form.Done, domain.TrackSourceUser)
+	if errors.Is(err, services.ErrTrackOutOfWindow) {
+		return domain.UserGamifyResponse{
+			StatusCode: domain.OutOfWindow,
+		}, nil
+	}
+	if errors.Is(err, services.ErrTrackOutOfRange) {
+		return domain.UserGamifyResponse{
+			StatusCode: domain.OutOfRange,
+		}, nil
+	}
 	if err != nil {
 		return domain.UserGamifyResponse{}, errors.Wrap(err, "MakeTrack")
 	}
+	// Будущая дата или дата не из периода челленджа
 	if !status {
 		return domain.UserGamifyResponse{
-			StatusCode: domain.ServerError,
+			StatusCode: domain.ValidationError,
 		}, nil
 	}
 
+	if form.Note != nil || form.Mood != nil {
+		_, err = ucase.tracksRepo.ChallengeUpdateNote(ctx, form.ChallengeId, form.Date, form.Note, form.Mood)
+		if err != nil {
+			return domain.UserGamifyResponse{}, errors.Wrap(err, "ChallengeUpdateNote")
+		}
+	}
+
 	dailyScore, err := ucase.trackProcessor.CalculateDailyScore(ctx, form.UserId)
 	if err != nil {
 		return domain.UserGamifyResponse{}, errors.Wrap(err, "CalculateScores")
 	}
 
-	// Получаем челлендж
-	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	// Серия после пересчета цепочки
+	challenge, err = ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
 	if err != nil {
 		return domain.UserGamifyResponse{}, errors.Wrap(err, "FetchById")
 	}
@@ -290,7 +582,11 @@ func (ucase *ChallengesUseCase) TrackDay(ctx context.Context, form *domain.DBCTr
 	}, nil
 }
 
-func (ucase *ChallengesUseCase) GetMonthTracks(ctx context.Context, date time.Time, challengeId, userId int64) (*domain.ChallengeMonthTracksResponse, error) {
+func (ucase *ChallengesUseCase) GetMonthTracks(ctx context.Context, date time.Time, challengeId, userId int64) (_ *domain.ChallengeMonthTracksResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.GetMonthTracks")
+	defer func() {
+		tracing.End(span, err)
+	}()
 
 	fromDate := tools.RoundDateTimeToMonth(date)
 	toDate := fromDate.AddDate(0, 1, -1)
@@ -316,6 +612,531 @@ func (ucase *ChallengesUseCase) GetMonthTracks(ctx context.Context, date time.Ti
 	}, nil
 }
 
+// Треки за произвольный период (не больше tracks.max_range_days дней)
+func (ucase *ChallengesUseCase) GetTracksRange(ctx context.Context, userId, challengeId int64, from, to time.Time) (_ *domain.ChallengeMonthTracksResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.GetTracksRange")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	maxRangeDays := viper.GetInt("tracks.max_range_days")
+	if maxRangeDays <= 0 {
+		maxRangeDays = defaultTracksMaxRangeDays
+	}
+
+	from = tools.RoundDateTimeToDay(from)
+	to = tools.RoundDateTimeToDay(to)
+	if to.Before(from) || to.Sub(from) >= time.Duration(maxRangeDays)*24*time.Hour {
+		return &domain.ChallengeMonthTracksResponse{
+			StatusCode: domain.ValidationError,
+		}, nil
+	}
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return &domain.ChallengeMonthTracksResponse{
+			StatusCode: domain.NotFound,
+		}, nil
+	}
+
+	tracks, err := ucase.tracksRepo.ChallengeFetchBetween(ctx, challengeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChallengeFetchBetween")
+	}
+
+	return &domain.ChallengeMonthTracksResponse{
+		StatusCode: domain.Success,
+		Tracks:     tracks,
+	}, nil
+}
+
+// Тепловая карта года (0 - текущий год)
+func (ucase *ChallengesUseCase) GetHeatmap(ctx context.Context, userId, challengeId int64, year int) (_ domain.ChallengeHeatmapResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.GetHeatmap")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	today := tools.RoundDateTimeToDay(time.Now().UTC())
+	if year == 0 {
+		year = today.Year()
+	}
+	if year < 1970 || year > today.Year()+1 {
+		return domain.ChallengeHeatmapResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.ChallengeHeatmapResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return domain.ChallengeHeatmapResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, -1)
+
+	doneDates, err := ucase.tracksRepo.ChallengeFetchDoneBetween(ctx, challengeId, from, to)
+	if err != nil {
+		return domain.ChallengeHeatmapResponse{}, errors.Wrap(err, "ChallengeFetchDoneBetween")
+	}
+	done := make(map[time.Time]bool, len(doneDates))
+	for _, date := range doneDates {
+		done[tools.RoundDateTimeToDay(date)] = true
+	}
+
+	joinedAt := tools.RoundDateTimeToDay(challenge.CreatedAt)
+	period := ucase.periodTypeGenerator.ChallengePeriod(challenge.ChallengeInfo)
+
+	days := make([]byte, 0, 366)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if done[day] {
+			days = append(days, domain.HeatmapDayDone)
+			continue
+		}
+		if !day.Before(today) || day.Before(joinedAt) ||
+			(challenge.DeletedAt != nil && day.After(*challenge.DeletedAt)) ||
+			!ucase.trackProcessor.IsInChallengeDates(challenge, day) {
+			days = append(days, domain.HeatmapDayPaused)
+			continue
+		}
+
+		match, err := ucase.periodTypeGenerator.IsMatch(day, period)
+		if err != nil {
+			return domain.ChallengeHeatmapResponse{}, errors.Wrap(err, "IsMatch")
+		}
+		if match {
+			days = append(days, domain.HeatmapDayMissed)
+		} else {
+			days = append(days, domain.HeatmapDayNotScheduled)
+		}
+	}
+
+	return domain.ChallengeHeatmapResponse{
+		StatusCode: domain.Success,
+		Year:       year,
+		Days:       string(days),
+	}, nil
+}
+
+// Отменяет последнее изменение трека, сделанное пользователем
+func (ucase *ChallengesUseCase) UndoLastTrack(ctx context.Context, userId, challengeId int64) (_ domain.UndoTrackResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.UndoLastTrack")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.UndoTrackResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId || challenge.DeletedAt != nil {
+		return domain.UndoTrackResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	undone, err := ucase.trackProcessor.UndoLastTrack(ctx, challengeId)
+	if errors.Is(err, services.ErrTrackOutOfWindow) {
+		return domain.UndoTrackResponse{StatusCode: domain.OutOfWindow}, nil
+	}
+	if errors.Is(err, services.ErrTrackOutOfRange) {
+		return domain.UndoTrackResponse{StatusCode: domain.OutOfRange}, nil
+	}
+	if err != nil {
+		return domain.UndoTrackResponse{}, errors.Wrap(err, "UndoLastTrack")
+	}
+	if undone == nil {
+		return domain.UndoTrackResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	dailyScore, err := ucase.trackProcessor.CalculateDailyScore(ctx, userId)
+	if err != nil {
+		return domain.UndoTrackResponse{}, errors.Wrap(err, "CalculateScores")
+	}
+
+	challenge, err = ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.UndoTrackResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil {
+		return domain.UndoTrackResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	response := domain.UndoTrackResponse{
+		StatusCode: domain.Success,
+		Date:       undone.Date,
+		LastSeries: challenge.LastSeries,
+		ScoreDaily: dailyScore,
+	}
+	if undone.OldDone != nil {
+		response.Done = *undone.OldDone
+	}
+	return response, nil
+}
+
+func (ucase *ChallengesUseCase) GetTrackHistory(ctx context.Context, userId, challengeId int64, limit, offset int64) (_ domain.TrackHistoryResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.GetTrackHistory")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.TrackHistoryResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return domain.TrackHistoryResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	if limit <= 0 || limit > maxTrackHistoryLimit {
+		limit = defaultTrackHistoryLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	history, err := ucase.trackHistoryRepo.ChallengeFetch(ctx, challengeId, limit, offset)
+	if err != nil {
+		return domain.TrackHistoryResponse{}, errors.Wrap(err, "ChallengeFetch")
+	}
+
+	return domain.TrackHistoryResponse{
+		StatusCode: domain.Success,
+		History:    history,
+	}, nil
+}
+
+// Заметка / настроение к уже отмеченному дню
+func (ucase *ChallengesUseCase) UpdateTrackNote(ctx context.Context, form *domain.UpdateTrackNoteForm) (_ domain.StatusResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.UpdateTrackNote")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != form.UserId || challenge.DeletedAt != nil {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	if !ucase.isValidNote(form.Note, form.Mood) {
+		return domain.StatusResponse{StatusCode: domain.ValidationError}, nil
+	}
+	if form.Note == nil && form.Mood == nil {
+		return domain.StatusResponse{StatusCode: domain.Success}, nil
+	}
+
+	found, err := ucase.tracksRepo.ChallengeUpdateNote(ctx, form.ChallengeId, form.Date, form.Note, form.Mood)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "ChallengeUpdateNote")
+	}
+	if !found {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+func (ucase *ChallengesUseCase) SearchTrackNotes(ctx context.Context, userId int64, search string, limit, offset int64) (_ domain.TrackNotesResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.SearchTrackNotes")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if limit <= 0 || limit > maxTrackNotesLimit {
+		limit = defaultTrackNotesLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	notes, err := ucase.tracksRepo.UserSearchNotes(ctx, userId, strings.TrimSpace(search), limit, offset)
+	if err != nil {
+		return domain.TrackNotesResponse{}, errors.Wrap(err, "UserSearchNotes")
+	}
+
+	return domain.TrackNotesResponse{
+		StatusCode: domain.Success,
+		Notes:      notes,
+	}, nil
+}
+
+// Статистика считается только по точкам периода челленджа: пропуск дня вне периода не снижает процент выполнения.
+// Текущий день учитывается, только если уже выполнен
+func (ucase *ChallengesUseCase) GetStats(ctx context.Context, userId, challengeId int64) (_ domain.ChallengeStatsResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.GetStats")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.ChallengeStatsResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return domain.ChallengeStatsResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	today := tools.RoundDateTimeToDay(time.Now().UTC())
+	windows := []int64{7, 30, 90}
+
+	trackStats, err := ucase.tracksRepo.ChallengeStats(ctx, challenge.Id, today.AddDate(0, 0, -int(windows[len(windows)-1])+1))
+	if err != nil {
+		return domain.ChallengeStatsResponse{}, errors.Wrap(err, "ChallengeStats")
+	}
+
+	stats := &domain.DBCChallengeStats{
+		CurrentSeries: trackStats.CurrentSeries,
+		BestSeries:    trackStats.BestSeries,
+		TotalDone:     trackStats.TotalDone,
+		Score:         trackStats.Score,
+	}
+
+	doneToday := false
+	for _, date := range trackStats.DoneDates {
+		if date.Equal(today) {
+			doneToday = true
+		}
+	}
+
+	// Точки периода: с вступления в челлендж (или первого трека) по сегодня
+	from := tools.RoundDateTimeToDay(challenge.CreatedAt)
+	if trackStats.FirstDate != nil && trackStats.FirstDate.Before(from) {
+		from = tools.RoundDateTimeToDay(*trackStats.FirstDate)
+	}
+	period := ucase.periodTypeGenerator.ChallengePeriod(challenge.ChallengeInfo)
+
+	totalAll := int64(0)
+	totalWindows := make([]int64, len(windows))
+	totalWeekdays := make(map[int]int64)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if (day.Equal(today) && !doneToday) || !ucase.trackProcessor.IsInChallengeDates(challenge, day) {
+			continue
+		}
+		match, err := ucase.periodTypeGenerator.IsMatch(day, period)
+		if err != nil {
+			return domain.ChallengeStatsResponse{}, errors.Wrap(err, "IsMatch")
+		}
+		if !match {
+			continue
+		}
+
+		totalAll++
+		totalWeekdays[(int(day.Weekday())+6)%7+1]++
+		for i, days := range windows {
+			if today.Sub(day) < time.Duration(days)*24*time.Hour {
+				totalWindows[i]++
+			}
+		}
+	}
+
+	for i, days := range windows {
+		done := int64(0)
+		for _, date := range trackStats.DoneDates {
+			if today.Sub(date) < time.Duration(days)*24*time.Hour {
+				done++
+			}
+		}
+		stats.Completion = append(stats.Completion, newCompletionRate(days, done, totalWindows[i]))
+	}
+	stats.Completion = append(stats.Completion, newCompletionRate(0, trackStats.TotalDone, totalAll))
+
+	for weekday := 1; weekday <= 7; weekday++ {
+		total := totalWeekdays[weekday]
+		if total == 0 {
+			continue
+		}
+		rate := newCompletionRate(0, trackStats.DoneByWeekday[weekday], total)
+		stats.Weekdays = append(stats.Weekdays, &domain.DBCWeekdayRate{
+			Weekday: int64(weekday),
+			Done:    rate.Done,
+			Total:   rate.Total,
+			Rate:    rate.Rate,
+		})
+	}
+
+	return domain.ChallengeStatsResponse{
+		StatusCode: domain.Success,
+		Stats:      stats,
+	}, nil
+}
+
+// Completion rate, активные серии и вклад в очки по категориям пользователя.
+// Категории в порядке челленджей пользователя, челленджи без категории - с CategoryId = nil
+func (ucase *ChallengesUseCase) GetCategoryStats(ctx context.Context, userId int64, windows []int64) (_ domain.CategoryStatsResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ChallengesUseCase.GetCategoryStats")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	maxRangeDays := viper.GetInt64("tracks.max_range_days")
+	if maxRangeDays <= 0 {
+		maxRangeDays = defaultTracksMaxRangeDays
+	}
+
+	if len(windows) == 0 {
+		windows = defaultCategoryStatsWindows
+	}
+	if len(windows) > maxCategoryStatsWindows {
+		return domain.CategoryStatsResponse{StatusCode: domain.ValidationError}, nil
+	}
+	maxDays := int64(0)
+	for _, days := range windows {
+		if days <= 0 || days > maxRangeDays {
+			return domain.CategoryStatsResponse{StatusCode: domain.ValidationError}, nil
+		}
+		if days > maxDays {
+			maxDays = days
+		}
+	}
+
+	challenges, err := ucase.userChallengesRepo.UserFetchAll(userId)
+	if err != nil {
+		return domain.CategoryStatsResponse{}, errors.Wrap(err, "UserFetchAll")
+	}
+
+	today := tools.RoundDateTimeToDay(time.Now().UTC())
+	from := today.AddDate(0, 0, -int(maxDays)+1)
+	windowFrom := make([]time.Time, len(windows))
+	for i, days := range windows {
+		windowFrom[i] = today.AddDate(0, 0, -int(days)+1)
+	}
+
+	tracks, err := ucase.tracksRepo.UserFetchBetween(ctx, userId, from, today)
+	if err != nil {
+		return domain.CategoryStatsResponse{}, errors.Wrap(err, "UserFetchBetween")
+	}
+	tracksByChallenge := lo.GroupBy(tracks, func(item *domain.DBCTrack) int64 {
+		return item.ChallengeUserId
+	})
+
+	var result []*domain.DBCCategoryStats
+	byCategory := make(map[int64]*domain.DBCCategoryStats)
+	// Доля считается по заработанным очкам (max(score_daily, 0)): штрафы одной категории
+	// не увеличивают долю остальных и доля всегда в [0, 1]
+	earnedScore := make(map[*domain.DBCCategoryWindowStats]int64)
+	totalEarned := make([]int64, len(windows))
+
+	for _, challenge := range challenges {
+		// 0 - без категории
+		categoryKey := int64(0)
+		if challenge.ChallengeInfo.Category != nil {
+			categoryKey = challenge.ChallengeInfo.Category.Id
+		}
+		stats, ok := byCategory[categoryKey]
+		if !ok {
+			stats = &domain.DBCCategoryStats{}
+			if challenge.ChallengeInfo.Category != nil {
+				stats.CategoryId = &challenge.ChallengeInfo.Category.Id
+				stats.CategoryName = challenge.ChallengeInfo.Category.Name
+			}
+			for _, days := range windows {
+				stats.Windows = append(stats.Windows, &domain.DBCCategoryWindowStats{Days: days})
+			}
+			byCategory[categoryKey] = stats
+			result = append(result, stats)
+		}
+
+		stats.Challenges++
+		if challenge.LastSeries > 0 {
+			stats.ActiveSeries++
+		}
+
+		// Треки есть только в точках периода: score и выполненные дни берем из них
+		tracked := make(map[time.Time]bool)
+		done := make(map[time.Time]bool)
+		for _, track := range tracksByChallenge[challenge.Id] {
+			date := tools.RoundDateTimeToDay(track.Date)
+			tracked[date] = true
+			done[date] = track.Done
+			for i := range windows {
+				if !date.Before(windowFrom[i]) {
+					stats.Windows[i].Score += track.ScoreDaily
+					if track.ScoreDaily > 0 {
+						earnedScore[stats.Windows[i]] += track.ScoreDaily
+						totalEarned[i] += track.ScoreDaily
+					}
+				}
+			}
+		}
+
+		// Запланированные дни: с вступления в челлендж (или с трека импорта), сегодня - только если выполнен
+		joinedAt := tools.RoundDateTimeToDay(challenge.CreatedAt)
+		period := ucase.periodTypeGenerator.ChallengePeriod(challenge.ChallengeInfo)
+		for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+			if (day.Before(joinedAt) && !tracked[day]) || (day.Equal(today) && !done[day]) {
+				continue
+			}
+			if !ucase.trackProcessor.IsInChallengeDates(challenge, day) {
+				continue
+			}
+			match, err := ucase.periodTypeGenerator.IsMatch(day, period)
+			if err != nil {
+				return domain.CategoryStatsResponse{}, errors.Wrap(err, "IsMatch")
+			}
+			if !match {
+				continue
+			}
+
+			for i := range windows {
+				if day.Before(windowFrom[i]) {
+					continue
+				}
+				stats.Windows[i].Total++
+				if done[day] {
+					stats.Windows[i].Done++
+				}
+			}
+		}
+	}
+
+	for _, stats := range result {
+		for i, window := range stats.Windows {
+			rate := newCompletionRate(window.Days, window.Done, window.Total)
+			window.Rate = rate.Rate
+			if totalEarned[i] > 0 {
+				window.ScoreShare = float64(earnedScore[window]) / float64(totalEarned[i])
+			}
+		}
+	}
+
+	return domain.CategoryStatsResponse{
+		StatusCode: domain.Success,
+		Categories: result,
+	}, nil
+}
+
+func newCompletionRate(days, done, total int64) *domain.DBCCompletionRate {
+	rate := &domain.DBCCompletionRate{
+		Days:  days,
+		Done:  done,
+		Total: total,
+	}
+	if total > 0 {
+		rate.Rate = math.Min(float64(done)/float64(total), 1)
+	}
+	return rate
+}
+
+func (ucase *ChallengesUseCase) isValidNote(note *string, mood *int64) bool {
+	maxLength := viper.GetInt("tracks.note_max_length")
+	if maxLength <= 0 || maxLength > defaultTrackNoteMaxLength {
+		maxLength = defaultTrackNoteMaxLength
+	}
+	if note != nil && utf8.RuneCountInString(*note) > maxLength {
+		return false
+	}
+	// 0 - очистить
+	if mood != nil && *mood != 0 && (*mood < trackMoodMin || *mood > trackMoodMax) {
+		return false
+	}
+	return true
+}
+
 func (ucase *ChallengesUseCase) Info(userId int64, challengeId int64) (domain.ChallengeInfoResponse, error) {
 	exists, err := ucase.userChallengesRepo.UserExistsByChallengeId(userId, challengeId)
 	if err != nil {
@@ -333,3 +1154,124 @@ func (ucase *ChallengesUseCase) Info(userId int64, challengeId int64) (domain.Ch
 		IsMember:   exists,
 	}, nil
 }
+
+func (ucase *ChallengesUseCase) UploadImage(ctx context.Context, form *domain.UploadChallengeImageForm) (domain.UploadChallengeImageResponse, error) {
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	if err != nil {
+		return domain.UploadChallengeImageResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != form.UserId {
+		return domain.UploadChallengeImageResponse{
+			StatusCode: domain.NotFound,
+		}, nil
+	}
+
+	// Only owner can change image of challenge (it is shared between members)
+	if challenge.ChallengeInfo.OwnerId != form.UserId {
+		return domain.UploadChallengeImageResponse{
+			StatusCode: domain.AccessDenied,
+		}, nil
+	}
+
+	_, ext, err := ucase.imageProcessor.Validate(form.Data)
+	switch err {
+	case nil:
+	case services.ErrImageTooLarge:
+		return domain.UploadChallengeImageResponse{
+			StatusCode: domain.FileTooLarge,
+		}, nil
+	case services.ErrImageUnsupported:
+		return domain.UploadChallengeImageResponse{
+			StatusCode: domain.UnsupportedFile,
+		}, nil
+	default:
+		return domain.UploadChallengeImageResponse{}, errors.Wrap(err, "Validate")
+	}
+
+	thumbnail, _, err := ucase.imageProcessor.Thumbnail(form.Data)
+	if err != nil {
+		return domain.UploadChallengeImageResponse{}, errors.Wrap(err, "Thumbnail")
+	}
+
+	key := fmt.Sprintf("challenges/%d/%s.%s", challenge.ChallengeInfoId, uuid.NewString(), ext)
+	thumbnailKey := services.ThumbnailKey(key)
+
+	err = ucase.blobStore.Put(key, form.Data)
+	if err != nil {
+		return domain.UploadChallengeImageResponse{}, errors.Wrap(err, "Put")
+	}
+	err = ucase.blobStore.Put(thumbnailKey, thumbnail)
+	if err != nil {
+		return domain.UploadChallengeImageResponse{}, errors.Wrap(err, "Put thumbnail")
+	}
+
+	err = ucase.challengesRepo.UpdateImage(ctx, challenge.ChallengeInfoId, &key)
+	if err != nil {
+		return domain.UploadChallengeImageResponse{}, errors.Wrap(err, "UpdateImage")
+	}
+
+	// Previous image is not needed anymore (if something goes wrong here - cleaner job will remove it)
+	if challenge.ChallengeInfo.Image != nil {
+		for _, old := range []string{*challenge.ChallengeInfo.Image, services.ThumbnailKey(*challenge.ChallengeInfo.Image)} {
+			err = ucase.blobStore.Remove(old)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot remove previous image %s", old)
+			}
+		}
+	}
+
+	return domain.UploadChallengeImageResponse{
+		StatusCode: domain.Success,
+		Image:      key,
+		Thumbnail:  thumbnailKey,
+	}, nil
+}
+
+func (ucase *ChallengesUseCase) GetImage(ctx context.Context, userId int64, image string, thumbnail bool) (domain.ChallengeImageResponse, error) {
+
+	// challenges/{challenge id}/{uuid}.{ext}
+	parts := strings.Split(image, "/")
+	if len(parts) != 3 || parts[0] != "challenges" {
+		return domain.ChallengeImageResponse{
+			StatusCode: domain.NotFound,
+		}, nil
+	}
+	challengeId, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return domain.ChallengeImageResponse{
+			StatusCode: domain.NotFound,
+		}, nil
+	}
+
+	canView, err := ucase.challengesRepo.UserCanView(ctx, userId, challengeId)
+	if err != nil {
+		return domain.ChallengeImageResponse{}, errors.Wrap(err, "UserCanView")
+	}
+	if !canView {
+		return domain.ChallengeImageResponse{
+			StatusCode: domain.AccessDenied,
+		}, nil
+	}
+
+	key := image
+	if thumbnail {
+		key = services.ThumbnailKey(image)
+	}
+
+	data, err := ucase.blobStore.Get(key)
+	if err != nil {
+		return domain.ChallengeImageResponse{}, errors.Wrap(err, "Get")
+	}
+	if data == nil {
+		return domain.ChallengeImageResponse{
+			StatusCode: domain.NotFound,
+		}, nil
+	}
+
+	return domain.ChallengeImageResponse{
+		StatusCode:  domain.Success,
+		ContentType: http.DetectContentType(data),
+		Data:        data,
+	}, nil
+}
@@ -0,0 +1,278 @@
+package usecase
+
+import (
+	"context"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"microservice/tools"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultImportMaxRows = 100000
+
+type DBCImportUCase struct {
+	log        core.Logger
+	trxManager *manager.Manager
+
+	usersRepo          domain.UsersRepository
+	userChallengesRepo domain.DBCUserChallengeRepository
+	challengesRepo     domain.DBChallengeInfoRepository
+
+	parser         *services.TracksImportParser
+	adapters       *services.ImportAdapters
+	periodProc     *services.PeriodTypeProcessor
+	trackProcessor *services.DBCProcessor
+}
+
+func NewDBCImportUCase(log core.Logger,
+	trxManager *manager.Manager,
+	usersRepo domain.UsersRepository,
+	userChallengesRepo domain.DBCUserChallengeRepository,
+	challengesRepo domain.DBChallengeInfoRepository,
+	parser *services.TracksImportParser,
+	adapters *services.ImportAdapters,
+	periodProc *services.PeriodTypeProcessor,
+	trackProcessor *services.DBCProcessor) *DBCImportUCase {
+	return &DBCImportUCase{
+		log:                log,
+		trxManager:         trxManager,
+		usersRepo:          usersRepo,
+		userChallengesRepo: userChallengesRepo,
+		challengesRepo:     challengesRepo,
+		parser:             parser,
+		adapters:           adapters,
+		periodProc:         periodProc,
+		trackProcessor:     trackProcessor,
+	}
+}
+
+// Импортирует историю треков пользователя.
+// Несуществующие челленджи создаются, цепочка пересчитывается один раз на каждый челлендж.
+func (ucase *DBCImportUCase) ImportTracks(ctx context.Context, userId int64, rows []*domain.ImportTrackRow) (_ domain.ImportTracksResponse, err error) {
+	ctx, span := tracing.Start(ctx, "DBCImportUCase.ImportTracks")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	return ucase.importBundle(ctx, userId, &domain.ImportBundle{Rows: rows})
+}
+
+// Импортирует бэкап другого трекера (формат определяет адаптер)
+func (ucase *DBCImportUCase) ImportBackup(ctx context.Context, userId int64, format string, data []byte) (_ domain.ImportTracksResponse, err error) {
+	ctx, span := tracing.Start(ctx, "DBCImportUCase.ImportBackup")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	adapter := ucase.adapters.Get(format)
+	if adapter == nil {
+		return domain.ImportTracksResponse{StatusCode: domain.UnsupportedFile}, nil
+	}
+
+	bundle, err := adapter.Parse(data)
+	if errors.Cause(err) == services.ErrImportTooLarge {
+		return domain.ImportTracksResponse{StatusCode: domain.FileTooLarge}, nil
+	}
+	if err != nil {
+		ucase.log.Warn("cannot parse import backup (format %s): %s", format, err)
+		return domain.ImportTracksResponse{StatusCode: domain.UnsupportedFile}, nil
+	}
+
+	return ucase.importBundle(ctx, userId, bundle)
+}
+
+func (ucase *DBCImportUCase) importBundle(ctx context.Context, userId int64, bundle *domain.ImportBundle) (domain.ImportTracksResponse, error) {
+
+	response := domain.ImportTracksResponse{
+		StatusCode: domain.Success,
+		Rejected:   []*domain.ImportRejectedRow{},
+	}
+
+	rows := bundle.Rows
+	maxRows := ucase.MaxRows()
+	if len(rows) > maxRows {
+		for _, row := range rows[maxRows:] {
+			response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectTooManyRows))
+		}
+		rows = rows[:maxRows]
+	}
+
+	err := ucase.usersRepo.InsertIfNotExists(&domain.User{Id: userId})
+	if err != nil {
+		return domain.ImportTracksResponse{}, errors.Wrap(err, "InsertIfNotExists")
+	}
+
+	infos := make(map[string]*domain.ImportChallenge)
+	for _, info := range bundle.Challenges {
+		name := strings.TrimSpace(info.Name)
+		if name == "" {
+			continue
+		}
+		// Некорректный период из бэкапа - не повод отказываться от импорта
+		if err := ucase.periodProc.Validate(info.Period); err != nil {
+			info.Period = domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay}
+		}
+		infos[name] = info
+	}
+
+	today := tools.RoundDateTimeToDay(time.Now().UTC())
+
+	// Validation & grouping by challenge name (last row wins for the same date)
+	grouped := make(map[string]map[time.Time]*domain.ImportTrackRow)
+	var names []string
+	for _, row := range rows {
+		name := strings.TrimSpace(row.Challenge)
+		if name == "" {
+			response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectEmptyChallenge))
+			continue
+		}
+
+		date, err := ucase.parser.ParseDate(row.Date)
+		if err != nil {
+			response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectInvalidDate))
+			continue
+		}
+		if date.After(today) {
+			response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectFutureDate))
+			continue
+		}
+
+		if _, err := ucase.parser.ParseValue(row.Value); err != nil {
+			response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectInvalidValue))
+			continue
+		}
+
+		if _, ok := grouped[name]; !ok {
+			grouped[name] = make(map[time.Time]*domain.ImportTrackRow)
+			names = append(names, name)
+		}
+		grouped[name][date] = row
+	}
+
+	sort.Strings(names)
+
+	// Импорт целиком: при ошибке не остается созданных челленджей и части треков
+	err = ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		for _, name := range names {
+			info, ok := infos[name]
+			if !ok {
+				info = &domain.ImportChallenge{
+					Name:   name,
+					Period: domain.GenerationPeriod{Type: domain.PeriodTypeEveryDay},
+				}
+			}
+
+			challenge, created, err := ucase.fetchOrCreateChallenge(ctx, userId, info)
+			if err != nil {
+				return errors.Wrapf(err, "fetchOrCreateChallenge %s", name)
+			}
+			if created {
+				response.ChallengesCreated++
+			}
+
+			// В архивный челлендж не импортируем
+			if challenge.DeletedAt != nil {
+				for _, row := range grouped[name] {
+					response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectArchived))
+				}
+				continue
+			}
+
+			// Даты проверяем по периоду челленджа (уже существующего или из бэкапа)
+			period := ucase.periodProc.ChallengePeriod(challenge.ChallengeInfo)
+			values := make(map[time.Time]bool)
+			for date, row := range grouped[name] {
+				match, err := ucase.periodProc.IsMatch(date, period)
+				if err != nil {
+					return errors.Wrap(err, "IsMatch")
+				}
+				if !match {
+					response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectNotInPeriod))
+					continue
+				}
+				if !ucase.trackProcessor.IsInChallengeDates(challenge, date) {
+					response.Rejected = append(response.Rejected, ucase.reject(row, domain.ImportRejectOutOfRange))
+					continue
+				}
+				values[date], _ = ucase.parser.ParseValue(row.Value)
+			}
+			if len(values) == 0 {
+				continue
+			}
+
+			// Один пересчет цепочки на весь челлендж
+			ok, err = ucase.trackProcessor.MakeTracks(ctx, challenge.Id, values, domain.TrackSourceImport)
+			if err != nil {
+				return errors.Wrapf(err, "MakeTracks %s", name)
+			}
+			if !ok {
+				return errors.Errorf("cannot make tracks for challenge %s", name)
+			}
+			response.TracksImported += int64(len(values))
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.ImportTracksResponse{}, errors.Wrap(err, "trxManager")
+	}
+
+	return response, nil
+}
+
+func (ucase *DBCImportUCase) fetchOrCreateChallenge(ctx context.Context, userId int64, info *domain.ImportChallenge) (*domain.DBCUserChallenge, bool, error) {
+	name := strings.TrimSpace(info.Name)
+	challenge, err := ucase.userChallengesRepo.UserFetchByName(userId, name)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "UserFetchByName")
+	}
+	if challenge != nil {
+		return challenge, false, nil
+	}
+
+	challengeInfo := &domain.DBCChallengeInfo{
+		OwnerId:        userId,
+		VisibilityType: "private",
+		Name:           name,
+		Desc:           info.Desc,
+		Period:         info.Period,
+	}
+	err = ucase.challengesRepo.Insert(ctx, challengeInfo)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "challengesRepo.Insert")
+	}
+
+	challenge = &domain.DBCUserChallenge{
+		ChallengeInfoId: challengeInfo.Id,
+		ChallengeInfo:   challengeInfo,
+		UserId:          userId,
+	}
+	err = ucase.userChallengesRepo.Insert(ctx, challenge)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "userChallengesRepo.Insert")
+	}
+
+	return challenge, true, nil
+}
+
+func (ucase *DBCImportUCase) MaxRows() int {
+	maxRows := viper.GetInt("import.max_rows")
+	if maxRows <= 0 {
+		maxRows = defaultImportMaxRows
+	}
+	return maxRows
+}
+
+func (ucase *DBCImportUCase) reject(row *domain.ImportTrackRow, reason string) *domain.ImportRejectedRow {
+	return &domain.ImportRejectedRow{
+		Line:      row.Line,
+		Challenge: row.Challenge,
+		Reason:    reason,
+	}
+}
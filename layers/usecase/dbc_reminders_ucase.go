@@ -0,0 +1,292 @@
+package usecase
+
+import (
+	"context"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"strings"
+	"time"
+)
+
+const (
+	maxRemindersPerChallenge = 5
+	defaultReminderLookback  = time.Hour
+	remindersChunkSize       = 1000
+
+	// Отметки об отправке нужны только за текущий день пользователя
+	reminderSendsRetention = 7 * 24 * time.Hour
+)
+
+type DBCRemindersUCase struct {
+	log        core.Logger
+	trxManager *manager.Manager
+
+	remindersRepo      domain.DBCRemindersRepository
+	userChallengesRepo domain.DBCUserChallengeRepository
+	tracksRepo         domain.DBCTrackRepository
+
+	periodProc     *services.PeriodTypeProcessor
+	trackProcessor *services.DBCProcessor
+	outbox         *services.OutboxWriter
+}
+
+func NewDBCRemindersUCase(log core.Logger,
+	trxManager *manager.Manager,
+	remindersRepo domain.DBCRemindersRepository,
+	userChallengesRepo domain.DBCUserChallengeRepository,
+	tracksRepo domain.DBCTrackRepository,
+	periodProc *services.PeriodTypeProcessor,
+	trackProcessor *services.DBCProcessor,
+	outbox *services.OutboxWriter) *DBCRemindersUCase {
+	return &DBCRemindersUCase{
+		log:                log,
+		trxManager:         trxManager,
+		remindersRepo:      remindersRepo,
+		userChallengesRepo: userChallengesRepo,
+		tracksRepo:         tracksRepo,
+		periodProc:         periodProc,
+		trackProcessor:     trackProcessor,
+		outbox:             outbox,
+	}
+}
+
+func (ucase *DBCRemindersUCase) CreateReminder(ctx context.Context, form *domain.CreateReminderForm) (domain.IdResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	if err != nil {
+		return domain.IdResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != form.UserId || challenge.DeletedAt != nil {
+		return domain.IdResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	minute, ok := parseReminderTime(form.Time)
+	if !ok {
+		return domain.IdResponse{StatusCode: domain.ValidationError}, nil
+	}
+	form.Timezone = strings.TrimSpace(form.Timezone)
+	if form.Timezone == "" {
+		form.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(form.Timezone)
+	if err != nil {
+		return domain.IdResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	reminders, err := ucase.remindersRepo.ChallengeFetchAll(ctx, challenge.Id)
+	if err != nil {
+		return domain.IdResponse{}, errors.Wrap(err, "ChallengeFetchAll")
+	}
+	if len(reminders) >= maxRemindersPerChallenge {
+		return domain.IdResponse{StatusCode: domain.ValidationError}, nil
+	}
+	for _, reminder := range reminders {
+		if reminder.Minute == minute {
+			return domain.IdResponse{StatusCode: domain.AlreadyExists}, nil
+		}
+	}
+
+	reminder := &domain.DBCReminder{
+		UserId:          form.UserId,
+		ChallengeUserId: challenge.Id,
+		Minute:          minute,
+		Timezone:        form.Timezone,
+		NextFireAt:      nextReminderFire(minute, loc, time.Now()),
+	}
+	err = ucase.remindersRepo.Insert(ctx, reminder)
+	if err != nil {
+		return domain.IdResponse{}, errors.Wrap(err, "Insert")
+	}
+
+	return domain.IdResponse{
+		StatusCode: domain.Success,
+		Id:         reminder.Id,
+	}, nil
+}
+
+func (ucase *DBCRemindersUCase) GetReminders(ctx context.Context, userId, challengeId int64) (domain.RemindersResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.RemindersResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return domain.RemindersResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	reminders, err := ucase.remindersRepo.ChallengeFetchAll(ctx, challengeId)
+	if err != nil {
+		return domain.RemindersResponse{}, errors.Wrap(err, "ChallengeFetchAll")
+	}
+
+	return domain.RemindersResponse{
+		StatusCode: domain.Success,
+		Reminders:  reminders,
+	}, nil
+}
+
+func (ucase *DBCRemindersUCase) RemoveReminder(ctx context.Context, userId, reminderId int64) (domain.StatusResponse, error) {
+	reminder, err := ucase.remindersRepo.FetchById(ctx, reminderId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if reminder == nil || reminder.UserId != userId {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	err = ucase.remindersRepo.Remove(ctx, reminderId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "Remove")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+// Напоминание срабатывает, если его время наступило не раньше чем lookback назад
+// (после простоя старые напоминания не отправляются), день челленджа - точка периода
+// и еще не отмечен. Каждое напоминание отправляется не больше одного раза за день
+func (ucase *DBCRemindersUCase) ProcessDue(ctx context.Context, now time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "DBCRemindersUCase.ProcessDue")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	// Топик есть только при включенной kafka
+	if domain.ReminderDueTopic == nil {
+		return nil
+	}
+
+	lookback := viper.GetDuration("reminders.lookback")
+	if lookback <= 0 {
+		lookback = defaultReminderLookback
+	}
+
+	// Выбираем только наступившие напоминания. После обработки срабатывание переносится на следующий день,
+	// при ошибке остается прежним - повторим в следующий запуск (пока не выйдет lookback)
+	afterId := int64(0)
+	for {
+		reminders, err := ucase.remindersRepo.FetchDue(ctx, now, afterId, remindersChunkSize)
+		if err != nil {
+			return errors.Wrap(err, "FetchDue")
+		}
+		if len(reminders) == 0 {
+			break
+		}
+		afterId = reminders[len(reminders)-1].Id
+
+		for _, reminder := range reminders {
+			loc, err := time.LoadLocation(reminder.Timezone)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot load time zone of reminder %d", reminder.Id)
+				continue
+			}
+
+			err = ucase.processReminder(ctx, reminder, loc, now, lookback)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot process reminder %d", reminder.Id)
+				continue
+			}
+
+			err = ucase.remindersRepo.SetNextFireAt(ctx, reminder.Id, nextReminderFire(reminder.Minute, loc, now))
+			if err != nil {
+				return errors.Wrap(err, "SetNextFireAt")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ucase *DBCRemindersUCase) PruneSends(ctx context.Context, now time.Time) (int64, error) {
+	deleted, err := ucase.remindersRepo.DeleteSendsBefore(ctx, now.Add(-reminderSendsRetention))
+	if err != nil {
+		return 0, errors.Wrap(err, "DeleteSendsBefore")
+	}
+	return deleted, nil
+}
+
+func (ucase *DBCRemindersUCase) processReminder(ctx context.Context, reminder *domain.DBCReminder, loc *time.Location, now time.Time, lookback time.Duration) error {
+	remindAt := reminder.NextFireAt
+	if now.Before(remindAt) || now.Sub(remindAt) >= lookback {
+		return nil
+	}
+
+	// Треки хранятся по дате, день берем в часовом поясе пользователя
+	local := remindAt.In(loc)
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, reminder.ChallengeUserId)
+	if err != nil {
+		return errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.DeletedAt != nil || challenge.ChallengeInfo.IsAutoTrack ||
+		challenge.Status != domain.ChallengeStatusActive ||
+		!ucase.trackProcessor.IsInChallengeDates(challenge, date) {
+		return nil
+	}
+
+	match, err := ucase.periodProc.IsMatch(date, ucase.periodProc.ChallengePeriod(challenge.ChallengeInfo))
+	if err != nil {
+		return errors.Wrap(err, "IsMatch")
+	}
+	if !match {
+		return nil
+	}
+
+	tracks, err := ucase.tracksRepo.ChallengeFetchByDates(challenge.Id, []time.Time{date})
+	if err != nil {
+		return errors.Wrap(err, "ChallengeFetchByDates")
+	}
+	for _, track := range tracks {
+		if track.Id != 0 && track.Done {
+			return nil
+		}
+	}
+
+	// Отметка об отправке и событие в outbox пишутся в одной транзакции
+	return ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		isNew, err := ucase.remindersRepo.InsertSendIfNotExists(ctx, reminder.Id, date)
+		if err != nil {
+			return errors.Wrap(err, "InsertSendIfNotExists")
+		}
+		if !isNew {
+			return nil
+		}
+
+		err = ucase.outbox.Add(ctx, domain.OutboxEventReminderDue, reminder.UserId, &domain.ReminderDueEvent{
+			ReminderId:    reminder.Id,
+			UserId:        reminder.UserId,
+			ChallengeId:   challenge.Id,
+			ChallengeName: challenge.ChallengeInfo.Name,
+			Date:          date.Format("2006-01-02"),
+			Timezone:      reminder.Timezone,
+			RemindAt:      remindAt.UTC(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "reminder due")
+		}
+		return nil
+	})
+}
+
+// HH:MM -> минута дня
+func parseReminderTime(value string) (int64, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+	return int64(t.Hour()*60 + t.Minute()), true
+}
+
+// Первое срабатывание напоминания (минута дня в loc) позже after
+func nextReminderFire(minute int64, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+	fireAt := time.Date(local.Year(), local.Month(), local.Day(), int(minute/60), int(minute%60), 0, 0, loc)
+	if !fireAt.After(after) {
+		fireAt = time.Date(local.Year(), local.Month(), local.Day()+1, int(minute/60), int(minute%60), 0, 0, loc)
+	}
+	return fireAt.UTC()
+}
@@ -0,0 +1,254 @@
+package usecase
+
+import (
+	"context"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"math"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"microservice/tools"
+	"strings"
+	"time"
+)
+
+const (
+	maxSignalNameLength    = 64
+	maxSignalEventIdLength = 128
+	defaultSignalThreshold = 1
+
+	defaultSignalEventsRetention = 30 * 24 * time.Hour
+)
+
+type DBCSignalsUCase struct {
+	log        core.Logger
+	trxManager *manager.Manager
+
+	rulesRepo          domain.DBCSignalRulesRepository
+	userChallengesRepo domain.DBCUserChallengeRepository
+	tracksRepo         domain.DBCTrackRepository
+
+	trackProcessor *services.DBCProcessor
+}
+
+func NewDBCSignalsUCase(log core.Logger,
+	trxManager *manager.Manager,
+	rulesRepo domain.DBCSignalRulesRepository,
+	userChallengesRepo domain.DBCUserChallengeRepository,
+	tracksRepo domain.DBCTrackRepository,
+	trackProcessor *services.DBCProcessor) *DBCSignalsUCase {
+	return &DBCSignalsUCase{
+		log:                log,
+		trxManager:         trxManager,
+		rulesRepo:          rulesRepo,
+		userChallengesRepo: userChallengesRepo,
+		tracksRepo:         tracksRepo,
+		trackProcessor:     trackProcessor,
+	}
+}
+
+// Применяет событие внешнего сервиса к правилам пользователя.
+// День челленджа отмечается выполненным, когда сумма значений за день достигает порога правила
+func (ucase *DBCSignalsUCase) Ingest(ctx context.Context, event *domain.SignalEvent) (_ domain.SignalIngestResponse, err error) {
+	ctx, span := tracing.Start(ctx, "DBCSignalsUCase.Ingest")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	event.Source = strings.TrimSpace(event.Source)
+	event.Type = strings.TrimSpace(event.Type)
+	if event.UserId <= 0 ||
+		event.Source == "" || len(event.Source) > maxSignalNameLength ||
+		event.Type == "" || len(event.Type) > maxSignalNameLength ||
+		len(event.Id) > maxSignalEventIdLength ||
+		math.IsNaN(event.Value) || math.IsInf(event.Value, 0) {
+		return domain.SignalIngestResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	date := time.Now().UTC()
+	if event.Date != nil {
+		date = event.Date.UTC()
+	}
+	date = tools.RoundDateTimeToDay(date)
+
+	rules, err := ucase.rulesRepo.UserFetchMatching(ctx, event.UserId, event.Source, event.Type)
+	if err != nil {
+		return domain.SignalIngestResponse{}, errors.Wrap(err, "UserFetchMatching")
+	}
+	if len(rules) == 0 {
+		return domain.SignalIngestResponse{StatusCode: domain.Success}, nil
+	}
+
+	// Накапливаем значения и отмечаем дни в одной транзакции: при ошибке событие не считается
+	// обработанным и повторная доставка применит его заново. Повторно доставленное событие пропускаем
+	tracked := int64(0)
+	err = ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		if event.Id != "" {
+			isNew, err := ucase.rulesRepo.InsertEventIfNotExists(ctx, event.Source, event.Id)
+			if err != nil {
+				return errors.Wrap(err, "InsertEventIfNotExists")
+			}
+			if !isNew {
+				return nil
+			}
+		}
+
+		for _, rule := range rules {
+			total, err := ucase.rulesRepo.AddDayValue(ctx, rule.Id, date, event.Value)
+			if err != nil {
+				return errors.Wrap(err, "AddDayValue")
+			}
+			if total < rule.Threshold {
+				continue
+			}
+
+			done, err := ucase.isDone(rule.ChallengeUserId, date)
+			if err != nil {
+				return errors.Wrap(err, "isDone")
+			}
+			if done {
+				continue
+			}
+
+			ok, err := ucase.trackProcessor.MakeTrack(ctx, rule.ChallengeUserId, date, true, domain.TrackSourceSignal)
+			if errors.Is(err, services.ErrTrackOutOfWindow) || errors.Is(err, services.ErrTrackOutOfRange) {
+				ucase.log.Warn("signal %s/%s for challenge %d is out of edit window or challenge dates (%s)",
+					event.Source, event.Type, rule.ChallengeUserId, date.Format("2006-01-02"))
+				continue
+			}
+			if err != nil {
+				return errors.Wrap(err, "MakeTrack")
+			}
+			if !ok {
+				ucase.log.Warn("signal %s/%s cannot track challenge %d at %s",
+					event.Source, event.Type, rule.ChallengeUserId, date.Format("2006-01-02"))
+				continue
+			}
+			tracked++
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SignalIngestResponse{}, errors.Wrap(err, "trxManager")
+	}
+
+	return domain.SignalIngestResponse{
+		StatusCode: domain.Success,
+		Tracked:    tracked,
+	}, nil
+}
+
+// Удаляет id обработанных событий старше signals.events_retention (повторная доставка приходит раньше)
+func (ucase *DBCSignalsUCase) PruneEvents(ctx context.Context) (int64, error) {
+	retention := viper.GetDuration("signals.events_retention")
+	if retention <= 0 {
+		retention = defaultSignalEventsRetention
+	}
+
+	deleted, err := ucase.rulesRepo.DeleteEventsBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, errors.Wrap(err, "DeleteEventsBefore")
+	}
+	return deleted, nil
+}
+
+func (ucase *DBCSignalsUCase) CreateRule(ctx context.Context, form *domain.CreateSignalRuleForm) (domain.IdResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, form.ChallengeId)
+	if err != nil {
+		return domain.IdResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != form.UserId {
+		return domain.IdResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	form.Source = strings.TrimSpace(form.Source)
+	form.EventType = strings.TrimSpace(form.EventType)
+	if form.Threshold == 0 {
+		form.Threshold = defaultSignalThreshold
+	}
+	if form.Source == "" || len(form.Source) > maxSignalNameLength ||
+		form.EventType == "" || len(form.EventType) > maxSignalNameLength ||
+		form.Threshold < 0 || math.IsNaN(form.Threshold) || math.IsInf(form.Threshold, 0) {
+		return domain.IdResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	rules, err := ucase.rulesRepo.ChallengeFetchAll(ctx, challenge.Id)
+	if err != nil {
+		return domain.IdResponse{}, errors.Wrap(err, "ChallengeFetchAll")
+	}
+	for _, rule := range rules {
+		if rule.Source == form.Source && rule.EventType == form.EventType {
+			return domain.IdResponse{StatusCode: domain.AlreadyExists}, nil
+		}
+	}
+
+	rule := &domain.DBCSignalRule{
+		UserId:          form.UserId,
+		ChallengeUserId: challenge.Id,
+		Source:          form.Source,
+		EventType:       form.EventType,
+		Threshold:       form.Threshold,
+	}
+	err = ucase.rulesRepo.Insert(ctx, rule)
+	if err != nil {
+		return domain.IdResponse{}, errors.Wrap(err, "Insert")
+	}
+
+	return domain.IdResponse{
+		StatusCode: domain.Success,
+		Id:         rule.Id,
+	}, nil
+}
+
+func (ucase *DBCSignalsUCase) GetRules(ctx context.Context, userId, challengeId int64) (domain.SignalRulesResponse, error) {
+	challenge, err := ucase.userChallengesRepo.FetchById(ctx, challengeId)
+	if err != nil {
+		return domain.SignalRulesResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if challenge == nil || challenge.UserId != userId {
+		return domain.SignalRulesResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	rules, err := ucase.rulesRepo.ChallengeFetchAll(ctx, challengeId)
+	if err != nil {
+		return domain.SignalRulesResponse{}, errors.Wrap(err, "ChallengeFetchAll")
+	}
+
+	return domain.SignalRulesResponse{
+		StatusCode: domain.Success,
+		Rules:      rules,
+	}, nil
+}
+
+func (ucase *DBCSignalsUCase) RemoveRule(ctx context.Context, userId, ruleId int64) (domain.StatusResponse, error) {
+	rule, err := ucase.rulesRepo.FetchById(ctx, ruleId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if rule == nil || rule.UserId != userId {
+		return domain.StatusResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	err = ucase.rulesRepo.Remove(ctx, ruleId)
+	if err != nil {
+		return domain.StatusResponse{}, errors.Wrap(err, "Remove")
+	}
+
+	return domain.StatusResponse{StatusCode: domain.Success}, nil
+}
+
+func (ucase *DBCSignalsUCase) isDone(challengeUserId int64, date time.Time) (bool, error) {
+	tracks, err := ucase.tracksRepo.ChallengeFetchByDates(challengeUserId, []time.Time{date})
+	if err != nil {
+		return false, errors.Wrap(err, "ChallengeFetchByDates")
+	}
+	for _, track := range tracks {
+		if track.Id != 0 && track.Done {
+			return true, nil
+		}
+	}
+	return false, nil
+}
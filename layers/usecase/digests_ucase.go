@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"context"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"microservice/tools"
+	"time"
+)
+
+const (
+	digestUsersChunkSize = 1000
+	digestRefreshChunk   = 1000
+)
+
+type DigestsUCase struct {
+	log        core.Logger
+	trxManager *manager.Manager
+
+	usersRepo   domain.UsersRepository
+	digestsRepo domain.WeeklyDigestsRepository
+
+	digestBuilder *services.WeeklyDigestBuilder
+	outbox        *services.OutboxWriter
+}
+
+func NewDigestsUCase(log core.Logger,
+	trxManager *manager.Manager,
+	usersRepo domain.UsersRepository,
+	digestsRepo domain.WeeklyDigestsRepository,
+	digestBuilder *services.WeeklyDigestBuilder,
+	outbox *services.OutboxWriter) *DigestsUCase {
+	return &DigestsUCase{
+		log:           log,
+		trxManager:    trxManager,
+		usersRepo:     usersRepo,
+		digestsRepo:   digestsRepo,
+		digestBuilder: digestBuilder,
+		outbox:        outbox,
+	}
+}
+
+// Повторный запуск за ту же неделю безопасен: неизмененные дайджесты не публикуются повторно
+func (ucase *DigestsUCase) GenerateWeekly(ctx context.Context, weekStart time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "DigestsUCase.GenerateWeekly")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	weekStart = tools.RoundDateTimeToWeek(weekStart)
+
+	offset := int64(0)
+	for {
+		ids, err := ucase.usersRepo.FetchIds(ctx, digestUsersChunkSize, offset)
+		if err != nil {
+			return errors.Wrap(err, "FetchIds")
+		}
+		offset += digestUsersChunkSize
+
+		if len(ids) == 0 {
+			break
+		}
+
+		for _, userId := range ids {
+			err := ucase.generateUserWeekly(ctx, userId, weekStart)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot generate weekly digest for user %d", userId)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Треки недели можно менять, пока не закрылось окно редактирования (до 31 дня),
+// поэтому дайджесты с затронутыми неделями пересобираются и публикуются заново
+func (ucase *DigestsUCase) RefreshChanged(ctx context.Context, since time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "DigestsUCase.RefreshChanged")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	afterId := int64(0)
+	for {
+		digests, err := ucase.digestsRepo.FetchAffectedSince(ctx, since, afterId, digestRefreshChunk)
+		if err != nil {
+			return errors.Wrap(err, "FetchAffectedSince")
+		}
+		if len(digests) == 0 {
+			break
+		}
+		afterId = digests[len(digests)-1].Id
+
+		for _, digest := range digests {
+			err := ucase.generateUserWeekly(ctx, digest.UserId, digest.WeekStart)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot refresh weekly digest %d of user %d", digest.Id, digest.UserId)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ucase *DigestsUCase) generateUserWeekly(ctx context.Context, userId int64, weekStart time.Time) error {
+	digest, err := ucase.digestBuilder.Build(ctx, userId, weekStart)
+	if err != nil {
+		return errors.Wrap(err, "Build")
+	}
+	if digest == nil {
+		return nil
+	}
+
+	// Дайджест сохраняется вместе с событием в outbox
+	return ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		changed, err := ucase.digestsRepo.Upsert(ctx, digest)
+		if err != nil {
+			return errors.Wrap(err, "Upsert")
+		}
+
+		if !changed {
+			return nil
+		}
+
+		err = ucase.outbox.Add(ctx, domain.OutboxEventWeeklyDigest, digest.UserId, digest)
+		if err != nil {
+			return errors.Wrap(err, "weekly digest")
+		}
+		return nil
+	})
+}
+
+func (ucase *DigestsUCase) GetDigest(ctx context.Context, userId int64, date *time.Time) (_ domain.DigestResponse, err error) {
+	ctx, span := tracing.Start(ctx, "DigestsUCase.GetDigest")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	weekStart := tools.RoundDateTimeToWeek(time.Now()).AddDate(0, 0, -7)
+	if date != nil {
+		weekStart = tools.RoundDateTimeToWeek(*date)
+	}
+
+	digest, err := ucase.digestsRepo.UserFetchByWeek(ctx, userId, weekStart)
+	if err != nil {
+		return domain.DigestResponse{}, errors.Wrap(err, "UserFetchByWeek")
+	}
+	if digest == nil {
+		return domain.DigestResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	return domain.DigestResponse{
+		StatusCode: domain.Success,
+		Digest:     digest,
+	}, nil
+}
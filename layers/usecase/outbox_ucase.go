@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"time"
+)
+
+const (
+	defaultOutboxBatchSize   = 100
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxRetention   = 7 * 24 * time.Hour
+	defaultOutboxLease       = 5 * time.Minute
+
+	outboxRetryBaseDelay = 10 * time.Second
+	outboxRetryMaxDelay  = time.Hour
+)
+
+// Отправляет события из outbox в kafka по порядку записи (в пределах ключа)
+type OutboxUCase struct {
+	log        core.Logger
+	trxManager *manager.Manager
+
+	outboxRepo domain.OutboxRepository
+}
+
+func NewOutboxUCase(log core.Logger,
+	trxManager *manager.Manager,
+	outboxRepo domain.OutboxRepository) *OutboxUCase {
+	return &OutboxUCase{
+		log:        log,
+		trxManager: trxManager,
+		outboxRepo: outboxRepo,
+	}
+}
+
+func (ucase *OutboxUCase) Relay(ctx context.Context) (_ int, err error) {
+	if !viper.GetBool("kafka.enabled") {
+		return 0, nil
+	}
+
+	ctx, span := tracing.Start(ctx, "OutboxUCase.Relay")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	published := 0
+	for {
+		count, done, err := ucase.relayBatch(ctx)
+		published += count
+		if err != nil {
+			return published, errors.Wrap(err, "relayBatch")
+		}
+		if done {
+			break
+		}
+	}
+
+	retention := viper.GetDuration("outbox.retention")
+	if retention <= 0 {
+		retention = defaultOutboxRetention
+	}
+	_, err = ucase.outboxRepo.DeletePublishedBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return published, errors.Wrap(err, "DeletePublishedBefore")
+	}
+
+	return published, nil
+}
+
+// done - отправлять больше нечего, события ждут повтора или их отправляет другой инстанс.
+// События захватываются в короткой транзакции, отправка в kafka и отметки идут вне ее:
+// доставка at-least-once - событие, отправленное до ошибки отметки, будет отправлено повторно
+func (ucase *OutboxUCase) relayBatch(ctx context.Context) (published int, done bool, err error) {
+	batchSize := viper.GetInt64("outbox.batch_size")
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatchSize
+	}
+	maxAttempts := viper.GetInt64("outbox.max_attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = defaultOutboxMaxAttempts
+	}
+	lease := viper.GetDuration("outbox.lease")
+	if lease <= 0 {
+		lease = defaultOutboxLease
+	}
+
+	var events []*domain.OutboxEvent
+	err = ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		locked, err := ucase.outboxRepo.TryLock(ctx)
+		if err != nil {
+			return errors.Wrap(err, "TryLock")
+		}
+		if !locked {
+			return nil
+		}
+
+		events, err = ucase.outboxRepo.ClaimDue(ctx, batchSize, lease)
+		if err != nil {
+			return errors.Wrap(err, "ClaimDue")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, true, errors.Wrap(err, "trxManager")
+	}
+	if int64(len(events)) < batchSize {
+		done = true
+	}
+
+	// Не отправленные к середине lease события отправит следующий запуск (после истечения lease)
+	deadline := time.Now().Add(lease / 2)
+	// Ключи, событие которых ждет повтора: следующие события ключа ждут его
+	retryKeys := make(map[string]bool)
+	for _, event := range events {
+		if time.Now().After(deadline) {
+			return published, true, nil
+		}
+		if retryKeys[event.Key] {
+			continue
+		}
+
+		publishErr := ucase.publish(ctx, event)
+		if publishErr == nil {
+			err = ucase.outboxRepo.MarkPublished(ctx, event.Id)
+			if err != nil {
+				return published, true, errors.Wrap(err, "MarkPublished")
+			}
+			published++
+			continue
+		}
+
+		// Попытки исчерпаны - событие пропускается, чтобы не блокировать остальные
+		if event.Attempts+1 >= maxAttempts {
+			ucase.log.ErrorWrap(publishErr, "outbox event %d (%s) failed after %d attempts", event.Id, event.EventType, event.Attempts+1)
+			err = ucase.outboxRepo.MarkFailed(ctx, event.Id, publishErr.Error())
+			if err != nil {
+				return published, true, errors.Wrap(err, "MarkFailed")
+			}
+			continue
+		}
+
+		ucase.log.ErrorWrap(publishErr, "cannot publish outbox event %d (%s)", event.Id, event.EventType)
+		err = ucase.outboxRepo.MarkRetry(ctx, event.Id, publishErr.Error(), outboxRetryDelay(event.Attempts+1))
+		if err != nil {
+			return published, true, errors.Wrap(err, "MarkRetry")
+		}
+		retryKeys[event.Key] = true
+	}
+
+	return published, done, nil
+}
+
+func (ucase *OutboxUCase) publish(ctx context.Context, event *domain.OutboxEvent) error {
+	switch event.EventType {
+	case domain.OutboxEventUserScoreChanged:
+		return produceOutboxEvent(ctx, domain.UserScoreChangedTopic, event)
+	case domain.OutboxEventTrackMade:
+		return produceOutboxEvent(ctx, domain.TrackMadeTopic, event)
+	case domain.OutboxEventSeriesBroken:
+		return produceOutboxEvent(ctx, domain.SeriesBrokenTopic, event)
+	case domain.OutboxEventAchievementUnlocked:
+		return produceOutboxEvent(ctx, domain.AchievementUnlockedTopic, event)
+	case domain.OutboxEventChallengeFinished:
+		return produceOutboxEvent(ctx, domain.ChallengeFinishedTopic, event)
+	case domain.OutboxEventReminderDue:
+		return produceOutboxEvent(ctx, domain.ReminderDueTopic, event)
+	case domain.OutboxEventWeeklyDigest:
+		return produceOutboxEvent(ctx, domain.WeeklyDigestTopic, event)
+	}
+	return errors.Errorf("unknown outbox event type %s", event.EventType)
+}
+
+// Событие отправляется с кодировщиком топика, ключ (id пользователя) сохраняет порядок событий пользователя в партиции
+func produceOutboxEvent[T any](ctx context.Context, topic *kafka.KafkaTopic[T], event *domain.OutboxEvent) error {
+	// Топики инициализируются при запуске consumers
+	if topic == nil {
+		return errors.New("kafka topic is not initialized")
+	}
+
+	var obj T
+	err := json.Unmarshal(event.Payload, &obj)
+	if err != nil {
+		return errors.Wrap(err, "cannot unmarshal payload")
+	}
+	return topic.ProduceKey(ctx, event.Key, obj)
+}
+
+// 10s, 20s, 40s ... не больше часа
+func outboxRetryDelay(attempts int64) time.Duration {
+	delay := outboxRetryBaseDelay
+	for i := int64(1); i < attempts && delay < outboxRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxRetryMaxDelay {
+		delay = outboxRetryMaxDelay
+	}
+	return delay
+}
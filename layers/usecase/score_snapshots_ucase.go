@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"microservice/tools"
+	"time"
+)
+
+const (
+	scoreSnapshotsUsersChunkSize  = 1000
+	defaultScoreTimelineMaxPoints = 366
+)
+
+type ScoreSnapshotsUCase struct {
+	log core.Logger
+
+	usersRepo          domain.UsersRepository
+	userChallengesRepo domain.DBCUserChallengeRepository
+	snapshotsRepo      domain.UserScoreSnapshotsRepository
+
+	trackProc *services.DBCProcessor
+}
+
+func NewScoreSnapshotsUCase(log core.Logger,
+	usersRepo domain.UsersRepository,
+	userChallengesRepo domain.DBCUserChallengeRepository,
+	snapshotsRepo domain.UserScoreSnapshotsRepository,
+	trackProc *services.DBCProcessor) *ScoreSnapshotsUCase {
+	return &ScoreSnapshotsUCase{
+		log:                log,
+		usersRepo:          usersRepo,
+		userChallengesRepo: userChallengesRepo,
+		snapshotsRepo:      snapshotsRepo,
+		trackProc:          trackProc,
+	}
+}
+
+// Запускается после обработки треков, поэтому users.score уже учитывает закрытые дни
+func (ucase *ScoreSnapshotsUCase) SnapshotDaily(ctx context.Context, date time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "ScoreSnapshotsUCase.SnapshotDaily")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	date = tools.RoundDateTimeToDay(date)
+
+	offset := int64(0)
+	for {
+		ids, err := ucase.usersRepo.FetchIds(ctx, scoreSnapshotsUsersChunkSize, offset)
+		if err != nil {
+			return errors.Wrap(err, "FetchIds")
+		}
+		offset += scoreSnapshotsUsersChunkSize
+
+		if len(ids) == 0 {
+			break
+		}
+
+		for _, userId := range ids {
+			err := ucase.snapshotUser(ctx, userId, date)
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot snapshot score for user %d", userId)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ucase *ScoreSnapshotsUCase) snapshotUser(ctx context.Context, userId int64, date time.Time) error {
+	user, err := ucase.usersRepo.FetchById(userId)
+	if err != nil {
+		return errors.Wrap(err, "FetchById")
+	}
+	if user == nil {
+		return nil
+	}
+
+	dailyScore, err := ucase.trackProc.CalculateDailyScore(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "CalculateDailyScore")
+	}
+
+	activeChallenges, err := ucase.userChallengesRepo.UserCountActive(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "UserCountActive")
+	}
+
+	err = ucase.snapshotsRepo.Upsert(ctx, &domain.UserScoreSnapshot{
+		UserId:           userId,
+		Date:             date,
+		Score:            user.Score,
+		ScoreDaily:       dailyScore,
+		ActiveChallenges: activeChallenges,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Upsert")
+	}
+	return nil
+}
+
+// Число точек ограничено score_timeline.max_points
+func (ucase *ScoreSnapshotsUCase) GetScoreTimeline(ctx context.Context, userId int64, granularity string, from, to time.Time) (_ domain.ScoreTimelineResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ScoreSnapshotsUCase.GetScoreTimeline")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if granularity == "" {
+		granularity = domain.ScoreGranularityDay
+	}
+
+	from = tools.RoundDateTimeToDay(from)
+	to = tools.RoundDateTimeToDay(to)
+	if to.Before(from) {
+		return domain.ScoreTimelineResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	var points int
+	switch granularity {
+	case domain.ScoreGranularityDay:
+		points = int(to.Sub(from).Hours()/24) + 1
+	case domain.ScoreGranularityWeek:
+		points = int(tools.RoundDateTimeToWeek(to).Sub(tools.RoundDateTimeToWeek(from)).Hours()/24/7) + 1
+	case domain.ScoreGranularityMonth:
+		points = (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month()) + 1
+	default:
+		return domain.ScoreTimelineResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	maxPoints := viper.GetInt("score_timeline.max_points")
+	if maxPoints <= 0 {
+		maxPoints = defaultScoreTimelineMaxPoints
+	}
+	if points > maxPoints {
+		return domain.ScoreTimelineResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	timeline, err := ucase.snapshotsRepo.UserFetchTimeline(ctx, userId, granularity, from, to)
+	if err != nil {
+		return domain.ScoreTimelineResponse{}, errors.Wrap(err, "UserFetchTimeline")
+	}
+
+	return domain.ScoreTimelineResponse{
+		StatusCode: domain.Success,
+		Points:     timeline,
+	}, nil
+}
@@ -0,0 +1,222 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/layers/domain"
+	"microservice/layers/services"
+	"path"
+)
+
+const (
+	defaultExportSyncMaxTracks = 5000
+	defaultExportBatchSize     = 10
+
+	// Размер колонки user_exports.error
+	exportErrorMaxLength = 1000
+)
+
+type UserExportUCase struct {
+	log        core.Logger
+	trxManager *manager.Manager
+	blobStore  app.BlobStore
+
+	usersRepo   domain.UsersRepository
+	exportsRepo domain.UserExportsRepository
+	tracksRepo  domain.DBCTrackRepository
+
+	exporter *services.UserDataExporter
+}
+
+func NewUserExportUCase(log core.Logger,
+	trxManager *manager.Manager,
+	blobStore app.BlobStore,
+	usersRepo domain.UsersRepository,
+	exportsRepo domain.UserExportsRepository,
+	tracksRepo domain.DBCTrackRepository,
+	exporter *services.UserDataExporter) *UserExportUCase {
+	return &UserExportUCase{
+		log:         log,
+		trxManager:  trxManager,
+		blobStore:   blobStore,
+		usersRepo:   usersRepo,
+		exportsRepo: exportsRepo,
+		tracksRepo:  tracksRepo,
+		exporter:    exporter,
+	}
+}
+
+// Создает выгрузку данных пользователя.
+// Небольшие аккаунты выгружаются сразу, большие - в фоне (UserExportsJob)
+func (ucase *UserExportUCase) ExportMyData(ctx context.Context, userId int64, format string) (domain.ExportMyDataResponse, error) {
+	if format == "" {
+		format = domain.ExportFormatJSON
+	}
+	if format != domain.ExportFormatJSON && format != domain.ExportFormatCSV {
+		return domain.ExportMyDataResponse{StatusCode: domain.ValidationError}, nil
+	}
+
+	err := ucase.usersRepo.InsertIfNotExists(&domain.User{Id: userId})
+	if err != nil {
+		return domain.ExportMyDataResponse{}, errors.Wrap(err, "InsertIfNotExists")
+	}
+
+	export := &domain.UserExport{
+		UserId: userId,
+		Format: format,
+		Status: domain.ExportStatusPending,
+	}
+	err = ucase.exportsRepo.Insert(ctx, export)
+	if err != nil {
+		return domain.ExportMyDataResponse{}, errors.Wrap(err, "exportsRepo.Insert")
+	}
+
+	tracksCount, err := ucase.tracksRepo.UserCount(ctx, userId)
+	if err != nil {
+		return domain.ExportMyDataResponse{}, errors.Wrap(err, "UserCount")
+	}
+
+	syncMaxTracks := viper.GetInt64("export.sync_max_tracks")
+	if syncMaxTracks <= 0 {
+		syncMaxTracks = defaultExportSyncMaxTracks
+	}
+	if tracksCount <= syncMaxTracks {
+		err = ucase.build(ctx, export)
+		if err != nil {
+			return domain.ExportMyDataResponse{}, errors.Wrap(err, "build")
+		}
+	}
+
+	return domain.ExportMyDataResponse{
+		StatusCode: domain.Success,
+		Export:     export,
+	}, nil
+}
+
+func (ucase *UserExportUCase) GetMyDataExport(ctx context.Context, userId, exportId int64) (domain.UserDataExportFileResponse, error) {
+	export, err := ucase.exportsRepo.FetchById(ctx, exportId)
+	if err != nil {
+		return domain.UserDataExportFileResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if export == nil || export.UserId != userId {
+		return domain.UserDataExportFileResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	switch export.Status {
+	case domain.ExportStatusPending:
+		return domain.UserDataExportFileResponse{StatusCode: domain.InProgress, Export: export}, nil
+	case domain.ExportStatusFailed:
+		return domain.UserDataExportFileResponse{StatusCode: domain.ServerError, Export: export}, nil
+	}
+
+	if export.File == nil {
+		return domain.UserDataExportFileResponse{StatusCode: domain.NotFound, Export: export}, nil
+	}
+
+	data, err := ucase.blobStore.Get(*export.File)
+	if err != nil {
+		return domain.UserDataExportFileResponse{}, errors.Wrap(err, "blobStore.Get")
+	}
+	if data == nil {
+		return domain.UserDataExportFileResponse{StatusCode: domain.NotFound, Export: export}, nil
+	}
+
+	contentType := "application/json"
+	if export.Format == domain.ExportFormatCSV {
+		contentType = "application/zip"
+	}
+
+	return domain.UserDataExportFileResponse{
+		StatusCode:  domain.Success,
+		Export:      export,
+		ContentType: contentType,
+		FileName:    path.Base(*export.File),
+		Data:        data,
+	}, nil
+}
+
+func (ucase *UserExportUCase) BuildPending(ctx context.Context) error {
+	batchSize := viper.GetInt64("export.batch_size")
+	if batchSize <= 0 {
+		batchSize = defaultExportBatchSize
+	}
+
+	// По одной выгрузке в транзакции: строка заблокирована, пока выгрузка собирается,
+	// и другой инстанс возьмет следующую
+	for i := int64(0); i < batchSize; i++ {
+		found := false
+		err := ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+			exports, err := ucase.exportsRepo.FetchPending(ctx, 1)
+			if err != nil {
+				return errors.Wrap(err, "FetchPending")
+			}
+			if len(exports) == 0 {
+				return nil
+			}
+			found = true
+
+			err = ucase.build(ctx, exports[0])
+			if err != nil {
+				ucase.log.ErrorWrap(err, "cannot build user export %d", exports[0].Id)
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "trxManager")
+		}
+		if !found {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Собирает выгрузку и кладет файл в blob хранилище.
+// Ошибка сборки фиксируется в самой выгрузке (status = failed)
+func (ucase *UserExportUCase) build(ctx context.Context, export *domain.UserExport) error {
+	key, buildErr := ucase.buildFile(ctx, export)
+	if buildErr != nil {
+		message := buildErr.Error()
+		if runes := []rune(message); len(runes) > exportErrorMaxLength {
+			message = string(runes[:exportErrorMaxLength])
+		}
+		export.Status = domain.ExportStatusFailed
+		export.Error = &message
+		ucase.log.ErrorWrap(buildErr, "user export %d failed", export.Id)
+	} else {
+		export.Status = domain.ExportStatusReady
+		export.File = &key
+	}
+
+	err := ucase.exportsRepo.Update(ctx, export)
+	if err != nil {
+		return errors.Wrap(err, "exportsRepo.Update")
+	}
+	return nil
+}
+
+func (ucase *UserExportUCase) buildFile(ctx context.Context, export *domain.UserExport) (string, error) {
+	data, err := ucase.exporter.Collect(ctx, export.UserId)
+	if err != nil {
+		return "", errors.Wrap(err, "Collect")
+	}
+
+	file, _, ext, err := ucase.exporter.Encode(export.Format, data)
+	if err != nil {
+		return "", errors.Wrap(err, "Encode")
+	}
+
+	key := fmt.Sprintf("exports/%d/%d.%s", export.UserId, export.Id, ext)
+	err = ucase.blobStore.Put(key, file)
+	if err != nil {
+		return "", errors.Wrap(err, "blobStore.Put")
+	}
+
+	return key, nil
+}
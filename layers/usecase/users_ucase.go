@@ -2,25 +2,46 @@ package usecase
 
 import (
 	"context"
+	"fmt"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app"
 	"microservice/app/core"
 	"microservice/layers/domain"
 	"microservice/layers/services"
+	"time"
+)
+
+const (
+	defaultDeletionGraceDays = 30
+	defaultDeletionBatchSize = 100
 )
 
 type UsersUseCase struct {
-	log       core.Logger
-	repo      domain.UsersRepository
+	log        core.Logger
+	trxManager *manager.Manager
+	blobStore  app.BlobStore
+
+	repo           domain.UsersRepository
+	challengesRepo domain.DBChallengeInfoRepository
+
 	trackProc *services.DBCProcessor
 }
 
 func NewUsersUseCase(log core.Logger,
+	trxManager *manager.Manager,
+	blobStore app.BlobStore,
 	repo domain.UsersRepository,
+	challengesRepo domain.DBChallengeInfoRepository,
 	trackProc *services.DBCProcessor) *UsersUseCase {
 	return &UsersUseCase{
-		log:       log,
-		repo:      repo,
-		trackProc: trackProc,
+		log:            log,
+		trxManager:     trxManager,
+		blobStore:      blobStore,
+		repo:           repo,
+		challengesRepo: challengesRepo,
+		trackProc:      trackProc,
 	}
 }
 
@@ -67,12 +88,138 @@ func (ucase *UsersUseCase) CreateIfNotExists(user *domain.User) (domain.CreateUs
 	}, nil
 }
 
-func (ucase *UsersUseCase) Remove(userId int64) (domain.RemoveUserResponse, error) {
-	err := ucase.repo.Remove(userId)
+// Удаляет аккаунт сразу (без grace period).
+// Публичные челленджи с другими участниками анонимизируются, остальное удаляется каскадно
+func (ucase *UsersUseCase) Remove(ctx context.Context, userId int64) (domain.RemoveUserResponse, error) {
+	exists := false
+	err := ucase.trxManager.Do(ctx, func(ctx context.Context) error {
+		var err error
+		exists, err = ucase.repo.Exist(ctx, userId)
+		if err != nil {
+			return errors.Wrap(err, "Exist")
+		}
+		if !exists {
+			return nil
+		}
+
+		anonymized, err := ucase.challengesRepo.AnonymizeOwnedShared(ctx, userId)
+		if err != nil {
+			return errors.Wrap(err, "AnonymizeOwnedShared")
+		}
+		if anonymized > 0 {
+			ucase.log.Info("User %d: %d shared challenges anonymized", userId, anonymized)
+		}
+
+		err = ucase.repo.HardDelete(ctx, userId)
+		if err != nil {
+			return errors.Wrap(err, "HardDelete")
+		}
+		return nil
+	})
 	if err != nil {
 		return domain.RemoveUserResponse{}, errors.Wrap(err, "cannot remove user")
 	}
+	if !exists {
+		return domain.RemoveUserResponse{
+			StatusCode: domain.NotFound,
+			Id:         userId,
+		}, nil
+	}
+
+	// Файлы выгрузок данных (изображения челленджей удалит DBCImagesCleanerJob)
+	err = ucase.removeBlobs(fmt.Sprintf("exports/%d/", userId))
+	if err != nil {
+		ucase.log.ErrorWrap(err, "cannot remove export files of user %d", userId)
+	}
+
 	return domain.RemoveUserResponse{
 		StatusCode: domain.Success,
+		Id:         userId,
 	}, nil
 }
+
+func (ucase *UsersUseCase) RequestDeletion(ctx context.Context, userId int64) (domain.AccountDeletionResponse, error) {
+	user, err := ucase.repo.FetchById(userId)
+	if err != nil {
+		return domain.AccountDeletionResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if user == nil {
+		return domain.AccountDeletionResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	// Повторный запрос не сдвигает дату удаления
+	if user.DeletionScheduledAt != nil {
+		return domain.AccountDeletionResponse{
+			StatusCode:          domain.Success,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		}, nil
+	}
+
+	graceDays := viper.GetInt("users.deletion.grace_days")
+	if graceDays <= 0 {
+		graceDays = defaultDeletionGraceDays
+	}
+	at := time.Now().UTC().Add(time.Duration(graceDays) * 24 * time.Hour)
+
+	err = ucase.repo.ScheduleDeletion(ctx, userId, &at)
+	if err != nil {
+		return domain.AccountDeletionResponse{}, errors.Wrap(err, "ScheduleDeletion")
+	}
+
+	return domain.AccountDeletionResponse{
+		StatusCode:          domain.Success,
+		DeletionScheduledAt: &at,
+	}, nil
+}
+
+func (ucase *UsersUseCase) CancelDeletion(ctx context.Context, userId int64) (domain.AccountDeletionResponse, error) {
+	user, err := ucase.repo.FetchById(userId)
+	if err != nil {
+		return domain.AccountDeletionResponse{}, errors.Wrap(err, "FetchById")
+	}
+	if user == nil || user.DeletionScheduledAt == nil {
+		return domain.AccountDeletionResponse{StatusCode: domain.NotFound}, nil
+	}
+
+	err = ucase.repo.ScheduleDeletion(ctx, userId, nil)
+	if err != nil {
+		return domain.AccountDeletionResponse{}, errors.Wrap(err, "ScheduleDeletion")
+	}
+
+	return domain.AccountDeletionResponse{
+		StatusCode: domain.Success,
+	}, nil
+}
+
+// Удаляет аккаунты, у которых истек grace period (job)
+func (ucase *UsersUseCase) DeleteScheduled(ctx context.Context) error {
+	ids, err := ucase.repo.FetchScheduledForDeletion(ctx, time.Now().UTC(), defaultDeletionBatchSize)
+	if err != nil {
+		return errors.Wrap(err, "FetchScheduledForDeletion")
+	}
+
+	for _, id := range ids {
+		_, err := ucase.Remove(ctx, id)
+		if err != nil {
+			ucase.log.ErrorWrap(err, "cannot delete user %d", id)
+			continue
+		}
+		ucase.log.Info("User %d was deleted", id)
+	}
+
+	return nil
+}
+
+func (ucase *UsersUseCase) removeBlobs(prefix string) error {
+	keys, err := ucase.blobStore.List(prefix)
+	if err != nil {
+		return errors.Wrap(err, "List")
+	}
+	for _, key := range keys {
+		err := ucase.blobStore.Remove(key)
+		if err != nil {
+			return errors.Wrapf(err, "Remove %s", key)
+		}
+	}
+	return nil
+}
@@ -3,9 +3,18 @@ package main
 import (
 	"log"
 	"microservice/bootstrap"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		err := bootstrap.RunCommand(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	err := bootstrap.Run()
 	if err != nil {
 		log.Fatal(err)
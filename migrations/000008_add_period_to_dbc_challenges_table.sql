@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges
+    -- Период генерации треков (every_day, week_days, month_dates)
+    ADD COLUMN IF NOT EXISTS period_type varchar(255) not null default 'every_day',
+    -- Дни недели (0-6) или дни месяца (1-31) для week_days и month_dates
+    ADD COLUMN IF NOT EXISTS period_data integer[]    not null default '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges
+    DROP COLUMN IF EXISTS period_type,
+    DROP COLUMN IF EXISTS period_data;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_exports
+(
+    id         SERIAL PRIMARY KEY NOT NULL,
+    user_id    bigint             not null,
+
+    -- json | csv
+    format     varchar(32)        not null,
+    -- pending | ready | failed
+    status     varchar(32)        not null default 'pending',
+
+    -- Ключ файла в blob хранилище
+    file       varchar(255)                default null,
+    error      varchar(1000)               default null,
+
+    created_at timestamp(0)       NOT NULL DEFAULT now(),
+    updated_at timestamp(0)       NOT NULL DEFAULT now(),
+
+    constraint fk_user_id foreign key (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_exports_status_idx ON user_exports (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_exports;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Удаление аккаунта откладывается на grace period, пока пользователь может его отменить
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamp(0) DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS users_deletion_scheduled_at_idx ON users (deletion_scheduled_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_deletion_scheduled_at_idx;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_scheduled_at;
+-- +goose StatementEnd
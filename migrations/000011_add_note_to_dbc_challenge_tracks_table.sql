@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Заметка пользователя к дню (почему пропустил, как прошло) и оценка настроения 1..5
+ALTER TABLE dbc_challenge_tracks
+    ADD COLUMN IF NOT EXISTS note varchar(2000) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS mood smallint      DEFAULT NULL CHECK (mood between 1 and 5);
+
+CREATE INDEX IF NOT EXISTS dbc_challenge_tracks_user_notes_idx
+    ON dbc_challenge_tracks (user_id, "date" desc) WHERE note IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_challenge_tracks_user_notes_idx;
+ALTER TABLE dbc_challenge_tracks
+    DROP COLUMN IF EXISTS note,
+    DROP COLUMN IF EXISTS mood;
+-- +goose StatementEnd
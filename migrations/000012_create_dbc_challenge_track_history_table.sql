@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS dbc_challenge_track_history
+(
+    id                SERIAL PRIMARY KEY NOT NULL,
+    user_id           bigint             not null,
+    challenge_user_id bigint             not null,
+
+    "date"            date               not null,
+
+    -- null - трека до изменения не было
+    old_done          bool                        default null,
+    new_done          bool               not null,
+
+    -- domain.TrackSource*: user | auto_fill | import | undo | signal
+    source            varchar(32)        not null,
+
+    -- Изменение отменено (UndoLastTrack)
+    undone_at         timestamp(0)                default null,
+
+    created_at        timestamp(0)       NOT NULL DEFAULT now(),
+
+    constraint fk_user_id foreign key (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    constraint fk_challenge_user_id foreign key (challenge_user_id) REFERENCES dbc_challenges_users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS dbc_challenge_track_history_challenge_idx
+    ON dbc_challenge_track_history (challenge_user_id, id desc);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dbc_challenge_track_history;
+-- +goose StatementEnd
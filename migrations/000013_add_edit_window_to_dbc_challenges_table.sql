@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges
+    -- Сколько точек периода назад можно менять треки (null - значение по умолчанию из конфига)
+    ADD COLUMN IF NOT EXISTS edit_window integer null check (edit_window between 1 and 31);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges
+    DROP COLUMN IF EXISTS edit_window;
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS dbc_signal_rules
+(
+    id                SERIAL PRIMARY KEY NOT NULL,
+    user_id           bigint             not null,
+    challenge_user_id bigint             not null,
+
+    -- Внешний сервис и тип события (fitness / steps, reading / pages, ...)
+    source            varchar(64)        not null,
+    event_type        varchar(64)        not null,
+
+    -- День отмечается выполненным, когда сумма значений за день >= threshold
+    threshold         double precision   not null default 1,
+
+    created_at        timestamp(0)       NOT NULL DEFAULT now(),
+
+    constraint fk_user_id foreign key (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    constraint fk_challenge_user_id foreign key (challenge_user_id) REFERENCES dbc_challenges_users (id) ON DELETE CASCADE,
+    constraint dbc_signal_rules_unique unique (challenge_user_id, source, event_type)
+);
+
+CREATE INDEX IF NOT EXISTS dbc_signal_rules_match_idx ON dbc_signal_rules (user_id, source, event_type);
+
+-- Накопленное значение правила за день
+CREATE TABLE IF NOT EXISTS dbc_signal_rule_days
+(
+    rule_id    bigint           not null,
+    "date"     date             not null,
+    value      double precision not null default 0,
+    updated_at timestamp(0)     NOT NULL DEFAULT now(),
+
+    primary key (rule_id, "date"),
+    constraint fk_rule_id foreign key (rule_id) REFERENCES dbc_signal_rules (id) ON DELETE CASCADE
+);
+
+-- Уже обработанные события (повторная доставка kafka / webhook)
+CREATE TABLE IF NOT EXISTS dbc_signal_events
+(
+    source     varchar(64)  not null,
+    event_id   varchar(128) not null,
+    created_at timestamp(0) NOT NULL DEFAULT now(),
+
+    primary key (source, event_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dbc_signal_events;
+DROP TABLE IF EXISTS dbc_signal_rule_days;
+DROP TABLE IF EXISTS dbc_signal_rules;
+-- +goose StatementEnd
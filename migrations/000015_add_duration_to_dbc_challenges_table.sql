@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges
+    -- Челлендж на срок: фиксированные даты или длительность от даты вступления
+    ADD COLUMN IF NOT EXISTS start_date    date    null,
+    ADD COLUMN IF NOT EXISTS end_date      date    null,
+    ADD COLUMN IF NOT EXISTS duration_days integer null check (duration_days > 0);
+
+ALTER TABLE dbc_challenges_users
+    -- Даты челленджа для пользователя (null - бессрочный)
+    ADD COLUMN IF NOT EXISTS start_date  date         null,
+    ADD COLUMN IF NOT EXISTS end_date    date         null,
+    -- active | completed | failed
+    ADD COLUMN IF NOT EXISTS status      varchar(32)  not null default 'active',
+    ADD COLUMN IF NOT EXISTS finished_at timestamp(0) null;
+
+CREATE INDEX IF NOT EXISTS dbc_challenges_users_status_idx ON dbc_challenges_users (status) WHERE end_date IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_challenges_users_status_idx;
+
+ALTER TABLE dbc_challenges_users
+    DROP COLUMN IF EXISTS start_date,
+    DROP COLUMN IF EXISTS end_date,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS finished_at;
+
+ALTER TABLE dbc_challenges
+    DROP COLUMN IF EXISTS start_date,
+    DROP COLUMN IF EXISTS end_date,
+    DROP COLUMN IF EXISTS duration_days;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges_users
+    -- Пользовательский порядок челленджей (новые - 0, т.е. сверху) и закрепление
+    ADD COLUMN IF NOT EXISTS position integer not null default 0,
+    ADD COLUMN IF NOT EXISTS pinned   boolean not null default false;
+
+CREATE INDEX IF NOT EXISTS dbc_challenges_users_position_idx ON dbc_challenges_users (user_id, pinned desc, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_challenges_users_position_idx;
+
+ALTER TABLE dbc_challenges_users
+    DROP COLUMN IF EXISTS position,
+    DROP COLUMN IF EXISTS pinned;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS dbc_reminders
+(
+    id                SERIAL PRIMARY KEY NOT NULL,
+    user_id           bigint             not null,
+    challenge_user_id bigint             not null,
+
+    -- Время напоминания: минута дня в часовом поясе пользователя (IANA, Europe/Moscow)
+    minute            integer            not null check (minute >= 0 and minute < 1440),
+    timezone          varchar(64)        not null default 'UTC',
+
+    created_at        timestamp(0)       NOT NULL DEFAULT now(),
+
+    constraint fk_user_id foreign key (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    constraint fk_challenge_user_id foreign key (challenge_user_id) REFERENCES dbc_challenges_users (id) ON DELETE CASCADE,
+    constraint dbc_reminders_unique unique (challenge_user_id, minute)
+);
+
+-- Отправленные напоминания (каждое срабатывает один раз за день)
+CREATE TABLE IF NOT EXISTS dbc_reminder_sends
+(
+    reminder_id bigint       not null,
+    "date"      date         not null,
+    created_at  timestamp(0) NOT NULL DEFAULT now(),
+
+    primary key (reminder_id, "date"),
+    constraint fk_reminder_id foreign key (reminder_id) REFERENCES dbc_reminders (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dbc_reminder_sends;
+DROP TABLE IF EXISTS dbc_reminders;
+-- +goose StatementEnd
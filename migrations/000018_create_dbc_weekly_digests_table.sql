@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS dbc_weekly_digests
+(
+    id         SERIAL PRIMARY KEY NOT NULL,
+    user_id    bigint             not null,
+
+    -- Понедельник недели
+    week_start date               not null,
+    -- Итоги недели (domain.WeeklyDigest)
+    data       jsonb              not null,
+
+    created_at timestamp(0)       NOT NULL DEFAULT now(),
+
+    constraint fk_user_id foreign key (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    constraint dbc_weekly_digests_unique unique (user_id, week_start)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dbc_weekly_digests;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Трек принадлежит участнику челленджа: (challenge_id, date) не позволял
+-- двум участникам общего челленджа отметить один и тот же день
+ALTER TABLE dbc_challenge_tracks DROP CONSTRAINT IF EXISTS dbc_challenge_tracks_challenge_id_date_key;
+
+-- Также покрывает выборки треков участника по диапазону дат (done - для тепловой карты без чтения таблицы)
+CREATE UNIQUE INDEX IF NOT EXISTS dbc_challenge_tracks_challenge_user_date_idx
+    ON dbc_challenge_tracks (challenge_user_id, "date") INCLUDE (done);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_challenge_tracks_challenge_user_date_idx;
+
+ALTER TABLE dbc_challenge_tracks ADD CONSTRAINT dbc_challenge_tracks_challenge_id_date_key UNIQUE (challenge_id, "date");
+-- +goose StatementEnd
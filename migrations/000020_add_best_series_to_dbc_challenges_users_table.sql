@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges_users
+    -- Рекордная серия и ее даты (last_series перезаписывается джобой)
+    ADD COLUMN IF NOT EXISTS best_series       integer   not null default 0,
+    ADD COLUMN IF NOT EXISTS best_series_start date      null,
+    ADD COLUMN IF NOT EXISTS best_series_end   date      null,
+    -- Достигнутые рубежи серии (7, 30, 100, 365)
+    ADD COLUMN IF NOT EXISTS milestones        integer[] not null default '{}';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Заполняем рекорды по уже обработанным трекам
+WITH best AS (
+    SELECT DISTINCT ON (t.challenge_user_id)
+        t.challenge_user_id,
+        t.last_series,
+        t."date" AS end_date
+    FROM dbc_challenge_tracks t
+    WHERE t.processed = true AND t.last_series > 0
+    ORDER BY t.challenge_user_id, t.last_series DESC, t."date"
+)
+UPDATE dbc_challenges_users c
+SET best_series       = b.last_series,
+    best_series_end   = b.end_date,
+    best_series_start = (SELECT max(s."date")
+                         FROM dbc_challenge_tracks s
+                         WHERE s.challenge_user_id = b.challenge_user_id
+                           AND s.last_series = 1
+                           AND s."date" <= b.end_date),
+    milestones        = ARRAY(SELECT m FROM unnest(ARRAY[7, 30, 100, 365]) m WHERE m <= b.last_series)
+FROM best b
+WHERE c.id = b.challenge_user_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE dbc_challenges_users
+    DROP COLUMN IF EXISTS best_series,
+    DROP COLUMN IF EXISTS best_series_start,
+    DROP COLUMN IF EXISTS best_series_end,
+    DROP COLUMN IF EXISTS milestones;
+-- +goose StatementEnd
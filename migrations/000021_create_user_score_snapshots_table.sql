@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_score_snapshots
+(
+    id                SERIAL PRIMARY KEY NOT NULL,
+    user_id           bigint             not null,
+    "date"            date               not null,
+
+    -- users.score на конец дня, score_daily - еще не зафиксированные очки (CalculateDailyScore)
+    score             bigint             not null default 0,
+    score_daily       bigint             not null default 0,
+    -- Изменение score относительно предыдущего снимка
+    delta             bigint             not null default 0,
+    active_challenges int                not null default 0,
+
+    created_at        timestamp(0)       NOT NULL DEFAULT now(),
+
+    constraint fk_user_id foreign key (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    constraint user_score_snapshots_unique unique (user_id, "date")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_score_snapshots;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id              BIGSERIAL PRIMARY KEY NOT NULL,
+    -- domain.OutboxEvent* (по типу выбирается топик kafka)
+    event_type      varchar(64)           not null,
+    event_key       varchar(255)          not null default '',
+    payload         jsonb                 not null,
+
+    -- pending -> published | failed (превышено число попыток)
+    status          varchar(16)           not null default 'pending',
+    attempts        int                   not null default 0,
+    last_error      text                  null,
+    next_attempt_at timestamp(0)          not null default now(),
+
+    created_at      timestamp(0)          NOT NULL DEFAULT now(),
+    published_at    timestamp(0)          null
+);
+
+-- Relay читает неотправленные события по порядку id
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS outbox_events_published_at_idx ON outbox_events (published_at) WHERE status = 'published';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox_events;
+-- +goose StatementEnd
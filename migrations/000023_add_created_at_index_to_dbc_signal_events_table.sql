@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SignalEventsCleanerJob удаляет старые события
+CREATE INDEX IF NOT EXISTS dbc_signal_events_created_at_idx ON dbc_signal_events (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_signal_events_created_at_idx;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ближайшее срабатывание напоминания (UTC): DBCRemindersJob выбирает только наступившие
+ALTER TABLE dbc_reminders
+    ADD COLUMN IF NOT EXISTS next_fire_at timestamp(0) not null default now();
+
+UPDATE dbc_reminders r
+SET next_fire_at = ((l.fire_at + case when l.fire_at <= l.local_now then interval '1 day' else interval '0' end)
+    at time zone r.timezone) at time zone 'UTC'
+FROM (select id,
+             now() at time zone timezone                                    as local_now,
+             (now() at time zone timezone)::date + minute * interval '1 minute' as fire_at
+      from dbc_reminders) l
+WHERE l.id = r.id;
+
+CREATE INDEX IF NOT EXISTS dbc_reminders_next_fire_at_idx ON dbc_reminders (next_fire_at);
+
+-- Старые отметки об отправке удаляет DBCReminderSendsCleanerJob
+CREATE INDEX IF NOT EXISTS dbc_reminder_sends_date_idx ON dbc_reminder_sends ("date");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_reminder_sends_date_idx;
+DROP INDEX IF EXISTS dbc_reminders_next_fire_at_idx;
+ALTER TABLE dbc_reminders
+    DROP COLUMN IF EXISTS next_fire_at;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Дайджест пересобирается, пока треки недели можно редактировать
+ALTER TABLE dbc_weekly_digests
+    ADD COLUMN IF NOT EXISTS updated_at timestamp(0) NOT NULL DEFAULT now();
+
+-- WeeklyDigestRefreshJob ищет недавние изменения треков
+CREATE INDEX IF NOT EXISTS dbc_challenge_track_history_created_at_idx ON dbc_challenge_track_history (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS dbc_challenge_track_history_created_at_idx;
+ALTER TABLE dbc_weekly_digests
+    DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Relay проверяет, нет ли у ключа более раннего события, ожидающего повтора
+CREATE INDEX IF NOT EXISTS outbox_events_pending_key_idx ON outbox_events (event_key, id) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS outbox_events_pending_key_idx;
+-- +goose StatementEnd
@@ -12,7 +12,7 @@ func RoundDateTimeToDay(t time.Time) time.Time {
 
 func RoundDateTimeToMonth(t time.Time) time.Time {
 	t = t.UTC()
-	return time.Date(t.Year(), t.Month(), 0, 0, 0, 0, 0, time.UTC)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
 }
 
 func IsEqualDateTimeByDay(t1, t2 time.Time) bool {
@@ -28,3 +28,10 @@ func ParseISO(dateISO string) (time.Time, error) {
 	}
 	return t, nil
 }
+
+// Понедельник недели t
+func RoundDateTimeToWeek(t time.Time) time.Time {
+	t = RoundDateTimeToDay(t)
+	weekday := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -weekday)
+}